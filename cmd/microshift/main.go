@@ -37,6 +37,14 @@ func newCommand() *cobra.Command {
 
 	cmd.AddCommand(cmds.NewRunMicroshiftCommand())
 	cmd.AddCommand(cmds.NewVersionCommand(ioStreams))
+	cmd.AddCommand(cmds.NewStatusCommand(ioStreams))
+	cmd.AddCommand(cmds.NewServicesCommand(ioStreams))
 	cmd.AddCommand(cmds.NewShowConfigCommand(ioStreams))
+	cmd.AddCommand(cmds.NewConfigCommand(ioStreams))
+	cmd.AddCommand(cmds.NewCertsCommand(ioStreams))
+	cmd.AddCommand(cmds.NewKubeconfigCommand(ioStreams))
+	cmd.AddCommand(cmds.NewBackupCommand(ioStreams))
+	cmd.AddCommand(cmds.NewEncryptionCommand(ioStreams))
+	cmd.AddCommand(cmds.NewEtcdCommand(ioStreams))
 	return cmd
 }
@@ -173,6 +173,7 @@ install -p -m644 packaging/systemd/microshift.service %{buildroot}%{_unitdir}/mi
 
 install -d -m755 %{buildroot}/%{_sysconfdir}/microshift
 install -p -m644 packaging/microshift/config.yaml %{buildroot}%{_sysconfdir}/microshift/config.yaml
+install -d -m755 %{buildroot}/%{_sysconfdir}/microshift/config.d
 
 # Memory tweaks to the OpenvSwitch services
 mkdir -p -m755 %{buildroot}%{_sysconfdir}/systemd/system/ovs-vswitchd.service.d
@@ -242,6 +243,7 @@ systemctl enable --now --quiet openvswitch || true
 %{_unitdir}/microshift.service
 %{_sysconfdir}/crio/crio.conf.d/microshift.conf
 %{_sysconfdir}/microshift/config.yaml
+%dir %{_sysconfdir}/microshift/config.d
 
 %files selinux
 
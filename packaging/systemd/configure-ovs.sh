@@ -626,14 +626,13 @@ fi
 # print initial state
 print_state
 if [ "$1" == "OVNKubernetes" ]; then
-  # Skip configuring NICs onto OVS bridge "br-ex" when disableOVSInit is true
-  MICROSHIFT_OVN_CONFIG_FILE_PATH="/etc/microshift/ovn.yaml"
-  if [ -f "$MICROSHIFT_OVN_CONFIG_FILE_PATH" ]; then
-    disableOVSInit=$(cat "$MICROSHIFT_OVN_CONFIG_FILE_PATH" | awk "/disableOVSInit:/ && ! /#.*disableOVSInit:/ {print \$2}")
-    if [ "$disableOVSInit" == "true" ]; then
-      echo "disableOVSInit is true, skipped configure-ovs.sh "
-      exit 0
-    fi
+  # Skip configuring NICs onto OVS bridge "br-ex" when disableOVSInit is true.
+  # The effective MicroShift config includes the network.ovn section of the
+  # config files, or /etc/microshift/ovn.yaml if the section is not set.
+  disableOVSInit=$(microshift show-config --mode effective 2>/dev/null | awk "/^ *disableOVSInit:/ {print \$2}")
+  if [ "$disableOVSInit" == "true" ]; then
+    echo "disableOVSInit is true, skipped configure-ovs.sh "
+    exit 0
   fi
   # Configures NICs onto OVS bridge "br-ex"
   # Configuration is either auto-detected or provided through a config file written already in Network Manager
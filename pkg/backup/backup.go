@@ -0,0 +1,582 @@
+package backup
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.etcd.io/etcd/etcdutl/v3/snapshot"
+	"go.uber.org/zap"
+	utilversion "k8s.io/apimachinery/pkg/util/version"
+	"k8s.io/klog/v2"
+
+	"github.com/openshift/microshift/pkg/version"
+)
+
+const (
+	// FormatVersion is the version of the archive format. Archives with a
+	// newer format cannot be restored.
+	FormatVersion = 1
+
+	manifestName  = "manifest.json"
+	snapshotName  = "etcd/snapshot.db"
+	archivePrefix = "microshift-backup-"
+	archiveSuffix = ".tar.gz"
+	timeFormat    = "20060102T150405Z"
+
+	// etcdDir is the directory of etcd's data in the data directory
+	etcdDir = "etcd"
+	// PreRestoreDir of the data directory keeps the data replaced by the last
+	// restore
+	PreRestoreDir = "pre-restore"
+	// etcdClusterToken is the default initial cluster token of etcd, which
+	// MicroShift's etcd uses
+	etcdClusterToken = "etcd-cluster"
+)
+
+// BackedUpDirs are the directories of the data directory that are archived
+// with the etcd snapshot.
+var BackedUpDirs = []string{"certs", "resources"}
+
+// Manifest describes the contents of a backup archive, it is the archive's
+// first file.
+type Manifest struct {
+	FormatVersion     int       `json:"formatVersion"`
+	MicroShiftVersion string    `json:"microshiftVersion"`
+	CreatedAt         time.Time `json:"createdAt"`
+	// Scheduled backups are created by the backup controller and removed
+	// when there are more than the configured number of them.
+	Scheduled bool `json:"scheduled"`
+	// Files maps the paths of the files in the archive to their SHA-256
+	// checksums.
+	Files map[string]string `json:"files"`
+}
+
+// Backup is a backup archive.
+type Backup struct {
+	Path string
+	Size int64
+	Manifest
+}
+
+// Name returns the file name of the backup.
+func (b *Backup) Name() string {
+	return filepath.Base(b.Path)
+}
+
+// Create takes a snapshot of etcd through its maintenance API and archives it
+// with the BackedUpDirs of dataDir in dir. The archive is named after the time
+// it was created at.
+func Create(ctx context.Context, maintenance clientv3.Maintenance, dataDir, dir string, scheduled bool) (*Backup, error) {
+	manifest := Manifest{
+		FormatVersion:     FormatVersion,
+		MicroShiftVersion: version.Get().String(),
+		CreatedAt:         time.Now().UTC().Truncate(time.Second),
+		Scheduled:         scheduled,
+	}
+	path := filepath.Join(dir, archivePrefix+manifest.CreatedAt.Format(timeFormat)+archiveSuffix)
+	if _, err := os.Stat(path); err == nil {
+		return nil, fmt.Errorf("backup %s already exists", path)
+	}
+
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	// the files are copied first, so that the checksums in the manifest
+	// match the archived files even if the originals change meanwhile
+	staging, err := os.MkdirTemp(dir, ".staging-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(staging)
+
+	if err := saveSnapshot(ctx, maintenance, filepath.Join(staging, snapshotName)); err != nil {
+		return nil, fmt.Errorf("failed to take etcd snapshot: %w", err)
+	}
+	for _, name := range BackedUpDirs {
+		if err := copyDir(filepath.Join(dataDir, name), filepath.Join(staging, name)); err != nil {
+			return nil, fmt.Errorf("failed to copy %s: %w", name, err)
+		}
+	}
+
+	manifest.Files, err = checksums(staging)
+	if err != nil {
+		return nil, err
+	}
+	if err := writeArchive(path, staging, &manifest); err != nil {
+		return nil, fmt.Errorf("failed to write backup %s: %w", path, err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	return &Backup{Path: path, Size: info.Size(), Manifest: manifest}, nil
+}
+
+func saveSnapshot(ctx context.Context, maintenance clientv3.Maintenance, path string) error {
+	snapshot, err := maintenance.Snapshot(ctx)
+	if err != nil {
+		return err
+	}
+	defer snapshot.Close()
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err := io.Copy(f, snapshot); err != nil {
+		return err
+	}
+	return f.Sync()
+}
+
+// copyDir copies the directories and regular files in src to dst, it does
+// nothing if src does not exist.
+func copyDir(src, dst string) error {
+	if _, err := os.Stat(src); os.IsNotExist(err) {
+		return nil
+	}
+	return filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		switch {
+		case d.IsDir():
+			return os.MkdirAll(target, info.Mode().Perm())
+		case d.Type().IsRegular():
+			return copyFile(path, target, info.Mode().Perm())
+		default:
+			klog.Warningf("Not backing up %s, it is not a regular file", path)
+			return nil
+		}
+	})
+}
+
+func copyFile(src, dst string, perm fs.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	_, err = io.Copy(out, in)
+	return err
+}
+
+// checksums returns the SHA-256 checksums of the regular files in dir by
+// their slash separated path relative to dir.
+func checksums(dir string) (map[string]string, error) {
+	sums := map[string]string{}
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		h := sha256.New()
+		if _, err := io.Copy(h, f); err != nil {
+			return err
+		}
+		sums[filepath.ToSlash(rel)] = hex.EncodeToString(h.Sum(nil))
+		return nil
+	})
+	return sums, err
+}
+
+// writeArchive writes the manifest followed by the contents of dir to a
+// gzipped tar archive at path. The archive only appears at path once it is
+// complete.
+func writeArchive(path, dir string, manifest *Manifest) error {
+	manifestJSON, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".archive-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	gz := gzip.NewWriter(tmp)
+	tw := tar.NewWriter(gz)
+	if err := tw.WriteHeader(&tar.Header{
+		Name:    manifestName,
+		Mode:    0600,
+		Size:    int64(len(manifestJSON)),
+		ModTime: manifest.CreatedAt,
+	}); err != nil {
+		return err
+	}
+	if _, err := tw.Write(manifestJSON); err != nil {
+		return err
+	}
+
+	err = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || path == dir {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		header, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(rel)
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(tw, f)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+	if err := tmp.Chmod(0600); err != nil {
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// List returns the backups in dir, newest first. Archives without a readable
+// manifest are skipped with a warning.
+func List(dir string) ([]*Backup, error) {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	backups := []*Backup{}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasPrefix(entry.Name(), archivePrefix) || !strings.HasSuffix(entry.Name(), archiveSuffix) {
+			continue
+		}
+		backup, err := Read(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			klog.Warningf("Skipping backup %s: %v", entry.Name(), err)
+			continue
+		}
+		backups = append(backups, backup)
+	}
+	sort.SliceStable(backups, func(i, j int) bool {
+		return backups[i].CreatedAt.After(backups[j].CreatedAt)
+	})
+	return backups, nil
+}
+
+// Read reads the manifest of the backup archive at path.
+func Read(path string) (*Backup, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	tr, closeArchive, err := openArchive(f)
+	if err != nil {
+		return nil, err
+	}
+	defer closeArchive()
+	manifest, err := readManifest(tr)
+	if err != nil {
+		return nil, err
+	}
+	return &Backup{Path: path, Size: info.Size(), Manifest: *manifest}, nil
+}
+
+func openArchive(r io.Reader) (*tar.Reader, func() error, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, nil, fmt.Errorf("not a backup archive: %w", err)
+	}
+	return tar.NewReader(gz), gz.Close, nil
+}
+
+func readManifest(tr *tar.Reader) (*Manifest, error) {
+	header, err := tr.Next()
+	if err != nil {
+		return nil, fmt.Errorf("not a backup archive: %w", err)
+	}
+	if header.Name != manifestName {
+		return nil, fmt.Errorf("not a backup archive: %s is missing", manifestName)
+	}
+	manifest := &Manifest{}
+	if err := json.NewDecoder(tr).Decode(manifest); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", manifestName, err)
+	}
+	if manifest.FormatVersion > FormatVersion {
+		return nil, fmt.Errorf("backup format version %d is newer than the supported version %d", manifest.FormatVersion, FormatVersion)
+	}
+	return manifest, nil
+}
+
+// Prune removes the oldest scheduled backups in dir so that at most `keep`
+// of them remain, and returns the removed ones. Backups that were not
+// scheduled are never removed.
+func Prune(dir string, keep int) ([]*Backup, error) {
+	backups, err := List(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	removed := []*Backup{}
+	scheduled := 0
+	for _, backup := range backups {
+		if !backup.Scheduled {
+			continue
+		}
+		if scheduled++; scheduled <= keep {
+			continue
+		}
+		if err := os.Remove(backup.Path); err != nil {
+			return removed, err
+		}
+		removed = append(removed, backup)
+	}
+	return removed, nil
+}
+
+// RestoreOptions configure the etcd member restored from a backup.
+type RestoreOptions struct {
+	EtcdName    string
+	EtcdPeerURL string
+	// Force restores backups created by a different MicroShift version.
+	Force bool
+}
+
+// Restore verifies the backup at path and replaces etcd's data and the
+// BackedUpDirs in dataDir with its contents. MicroShift must not be running.
+// The replaced data is moved to the pre-restore directory in dataDir, which
+// replaces the one of the previous restore.
+func Restore(path, dataDir string, opts RestoreOptions) (*Manifest, error) {
+	if err := os.MkdirAll(dataDir, 0700); err != nil {
+		return nil, err
+	}
+	staging, err := os.MkdirTemp(dataDir, ".restore-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(staging)
+
+	manifest, err := extract(path, staging)
+	if err != nil {
+		return nil, fmt.Errorf("failed to extract backup %s: %w", path, err)
+	}
+	if err := checkVersion(manifest.MicroShiftVersion, version.Get().String()); err != nil && !opts.Force {
+		return nil, err
+	}
+
+	etcdSnapshot := filepath.Join(staging, filepath.FromSlash(snapshotName))
+	if err := snapshot.NewV3(zap.NewNop()).Restore(snapshot.RestoreConfig{
+		SnapshotPath:        etcdSnapshot,
+		Name:                opts.EtcdName,
+		OutputDataDir:       filepath.Join(staging, ".etcd"),
+		PeerURLs:            []string{opts.EtcdPeerURL},
+		InitialCluster:      opts.EtcdName + "=" + opts.EtcdPeerURL,
+		InitialClusterToken: etcdClusterToken,
+	}); err != nil {
+		return nil, fmt.Errorf("failed to restore etcd snapshot: %w", err)
+	}
+	if err := os.RemoveAll(filepath.Join(staging, etcdDir)); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(filepath.Join(staging, ".etcd"), filepath.Join(staging, etcdDir)); err != nil {
+		return nil, err
+	}
+
+	previous := filepath.Join(dataDir, PreRestoreDir)
+	if err := os.RemoveAll(previous); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(previous, 0700); err != nil {
+		return nil, err
+	}
+	for _, name := range append([]string{etcdDir}, BackedUpDirs...) {
+		current := filepath.Join(dataDir, name)
+		if err := os.Rename(current, filepath.Join(previous, name)); err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to move %s to %s: %w", current, previous, err)
+		}
+		restored := filepath.Join(staging, name)
+		if _, err := os.Stat(restored); os.IsNotExist(err) {
+			continue
+		}
+		if err := os.Rename(restored, current); err != nil {
+			return nil, fmt.Errorf("failed to restore %s, the previous data is in %s: %w", current, previous, err)
+		}
+	}
+	return manifest, nil
+}
+
+// extract extracts the archive at path to dir, verifying the checksums of its
+// files against its manifest.
+func extract(path, dir string) (*Manifest, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	tr, closeArchive, err := openArchive(f)
+	if err != nil {
+		return nil, err
+	}
+	defer closeArchive()
+	manifest, err := readManifest(tr)
+	if err != nil {
+		return nil, err
+	}
+
+	extracted := map[string]bool{}
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		name := filepath.Clean(filepath.FromSlash(header.Name))
+		if filepath.IsAbs(name) || name == ".." || strings.HasPrefix(name, ".."+string(filepath.Separator)) {
+			return nil, fmt.Errorf("invalid path %q", header.Name)
+		}
+		target := filepath.Join(dir, name)
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, header.FileInfo().Mode().Perm()); err != nil {
+				return nil, err
+			}
+		case tar.TypeReg:
+			sum, ok := manifest.Files[header.Name]
+			if !ok {
+				return nil, fmt.Errorf("%s is not in the manifest", header.Name)
+			}
+			if err := extractFile(tr, target, header.FileInfo().Mode().Perm(), sum); err != nil {
+				return nil, fmt.Errorf("%s: %w", header.Name, err)
+			}
+			extracted[header.Name] = true
+		default:
+			return nil, fmt.Errorf("%s has unsupported type %c", header.Name, header.Typeflag)
+		}
+	}
+
+	for name := range manifest.Files {
+		if !extracted[name] {
+			return nil, fmt.Errorf("%s is missing", name)
+		}
+	}
+	if !extracted[snapshotName] {
+		return nil, fmt.Errorf("%s is missing", snapshotName)
+	}
+	return manifest, nil
+}
+
+func extractFile(r io.Reader, path string, perm fs.FileMode, sum string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(f, h), r); err != nil {
+		return err
+	}
+	if actual := hex.EncodeToString(h.Sum(nil)); actual != sum {
+		return fmt.Errorf("checksum mismatch, expected %s, got %s", sum, actual)
+	}
+	return nil
+}
+
+// checkVersion returns an error if the backup was created by a MicroShift
+// with a different minor version. Versions that cannot be parsed, e.g. of
+// development builds, are not checked.
+func checkVersion(backupVersion, currentVersion string) error {
+	backup, err := utilversion.ParseGeneric(backupVersion)
+	if err != nil {
+		return nil
+	}
+	current, err := utilversion.ParseGeneric(currentVersion)
+	if err != nil {
+		return nil
+	}
+	if backup.Major() != current.Major() || backup.Minor() != current.Minor() {
+		return fmt.Errorf("backup was created by MicroShift %s, restoring it with %s may break the cluster", backupVersion, currentVersion)
+	}
+	return nil
+}
@@ -0,0 +1,307 @@
+package backup
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.etcd.io/etcd/server/v3/embed"
+	"go.uber.org/zap"
+)
+
+type testEtcd struct {
+	client *clientv3.Client
+	// Stop stops etcd, it may be called more than once.
+	Stop func()
+}
+
+// startEtcd starts an etcd member named "test" with its data in dir.
+func startEtcd(t *testing.T, dir, peerURL string) *testEtcd {
+	t.Helper()
+	clientURL, err := url.Parse("http://" + freeAddress(t))
+	require.NoError(t, err)
+	peer, err := url.Parse(peerURL)
+	require.NoError(t, err)
+
+	cfg := embed.NewConfig()
+	cfg.Name = "test"
+	cfg.Dir = dir
+	cfg.Logger = "zap"
+	cfg.LogLevel = "error"
+	cfg.LCUrls, cfg.ACUrls = []url.URL{*clientURL}, []url.URL{*clientURL}
+	cfg.LPUrls, cfg.APUrls = []url.URL{*peer}, []url.URL{*peer}
+	cfg.InitialCluster = "test=" + peerURL
+	e, err := embed.StartEtcd(cfg)
+	require.NoError(t, err)
+	var once sync.Once
+	stop := func() { once.Do(e.Close) }
+	t.Cleanup(stop)
+	select {
+	case <-e.Server.ReadyNotify():
+	case <-time.After(time.Minute):
+		t.Fatal("etcd did not become ready")
+	}
+
+	client, err := clientv3.New(clientv3.Config{
+		Endpoints:   []string{clientURL.String()},
+		DialTimeout: 10 * time.Second,
+		Logger:      zap.NewNop(),
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { client.Close() })
+	return &testEtcd{client: client, Stop: stop}
+}
+
+func freeAddress(t *testing.T) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer l.Close()
+	return l.Addr().String()
+}
+
+func writeFile(t *testing.T, path, contents string) {
+	t.Helper()
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0700))
+	require.NoError(t, os.WriteFile(path, []byte(contents), 0600))
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	contents, err := os.ReadFile(path)
+	require.NoError(t, err)
+	return string(contents)
+}
+
+func TestCreateAndRestore(t *testing.T) {
+	ctx := context.Background()
+	dataDir, backupDir := t.TempDir(), t.TempDir()
+	peerURL := "http://" + freeAddress(t)
+
+	etcd := startEtcd(t, filepath.Join(dataDir, etcdDir), peerURL)
+	_, err := etcd.client.Put(ctx, "/registry/test", "backed up")
+	require.NoError(t, err)
+	writeFile(t, filepath.Join(dataDir, "certs", "ca.crt"), "ca")
+	writeFile(t, filepath.Join(dataDir, "resources", "kubeadmin", "kubeconfig"), "kubeconfig")
+
+	b, err := Create(ctx, etcd.client, dataDir, backupDir, false)
+	require.NoError(t, err)
+	assert.Equal(t, FormatVersion, b.FormatVersion)
+	assert.False(t, b.Scheduled)
+	assert.ElementsMatch(t, []string{snapshotName, "certs/ca.crt", "resources/kubeadmin/kubeconfig"}, keys(b.Files))
+
+	backups, err := List(backupDir)
+	require.NoError(t, err)
+	require.Len(t, backups, 1)
+	assert.Equal(t, b, backups[0])
+
+	// change the data after the backup, the restore must undo it
+	_, err = etcd.client.Put(ctx, "/registry/test", "changed")
+	require.NoError(t, err)
+	writeFile(t, filepath.Join(dataDir, "certs", "ca.crt"), "rotated")
+	writeFile(t, filepath.Join(dataDir, "certs", "new.crt"), "new")
+	etcd.Stop()
+
+	_, err = Restore(b.Path, dataDir, RestoreOptions{EtcdName: "test", EtcdPeerURL: peerURL})
+	require.NoError(t, err)
+	assert.Equal(t, "ca", readFile(t, filepath.Join(dataDir, "certs", "ca.crt")))
+	assert.NoFileExists(t, filepath.Join(dataDir, "certs", "new.crt"))
+	assert.Equal(t, "kubeconfig", readFile(t, filepath.Join(dataDir, "resources", "kubeadmin", "kubeconfig")))
+	assert.Equal(t, "rotated", readFile(t, filepath.Join(dataDir, PreRestoreDir, "certs", "ca.crt")))
+	assert.DirExists(t, filepath.Join(dataDir, PreRestoreDir, etcdDir, "member"))
+
+	etcd = startEtcd(t, filepath.Join(dataDir, etcdDir), peerURL)
+	resp, err := etcd.client.Get(ctx, "/registry/test")
+	require.NoError(t, err)
+	require.Len(t, resp.Kvs, 1)
+	assert.Equal(t, "backed up", string(resp.Kvs[0].Value))
+}
+
+func keys(m map[string]string) []string {
+	keys := []string{}
+	for k := range m {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// writeTestArchive writes a backup archive with the manifest and files.
+func writeTestArchive(t *testing.T, path string, manifest *Manifest, files map[string]string) {
+	t.Helper()
+	dir := t.TempDir()
+	for name, contents := range files {
+		writeFile(t, filepath.Join(dir, filepath.FromSlash(name)), contents)
+	}
+	if manifest.Files == nil {
+		var err error
+		manifest.Files, err = checksums(dir)
+		require.NoError(t, err)
+	}
+	require.NoError(t, writeArchive(path, dir, manifest))
+}
+
+func TestExtract(t *testing.T) {
+	newManifest := func() *Manifest { return &Manifest{FormatVersion: FormatVersion, CreatedAt: time.Now()} }
+	files := map[string]string{snapshotName: "snapshot", "certs/ca.crt": "ca"}
+
+	tests := []struct {
+		name    string
+		write   func(t *testing.T, path string)
+		wantErr string
+	}{
+		{
+			name: "valid",
+			write: func(t *testing.T, path string) {
+				writeTestArchive(t, path, newManifest(), files)
+			},
+		},
+		{
+			name: "checksum mismatch",
+			write: func(t *testing.T, path string) {
+				manifest := newManifest()
+				manifest.Files = map[string]string{snapshotName: "0000", "certs/ca.crt": "0000"}
+				writeTestArchive(t, path, manifest, files)
+			},
+			wantErr: "checksum mismatch",
+		},
+		{
+			name: "missing file",
+			write: func(t *testing.T, path string) {
+				manifest := newManifest()
+				manifest.Files = map[string]string{"certs/missing.crt": "0000"}
+				writeTestArchive(t, path, manifest, map[string]string{})
+			},
+			wantErr: "certs/missing.crt is missing",
+		},
+		{
+			name: "missing snapshot",
+			write: func(t *testing.T, path string) {
+				writeTestArchive(t, path, newManifest(), map[string]string{"certs/ca.crt": "ca"})
+			},
+			wantErr: snapshotName + " is missing",
+		},
+		{
+			name: "newer format",
+			write: func(t *testing.T, path string) {
+				manifest := newManifest()
+				manifest.FormatVersion = FormatVersion + 1
+				writeTestArchive(t, path, manifest, files)
+			},
+			wantErr: "is newer than the supported version",
+		},
+		{
+			name: "path outside of the archive",
+			write: func(t *testing.T, path string) {
+				f, err := os.Create(path)
+				require.NoError(t, err)
+				defer f.Close()
+				gz := gzip.NewWriter(f)
+				defer gz.Close()
+				tw := tar.NewWriter(gz)
+				defer tw.Close()
+				for _, file := range []struct{ name, contents string }{
+					{manifestName, fmt.Sprintf(`{"formatVersion": %d}`, FormatVersion)},
+					{"../escaped", "escaped"},
+				} {
+					require.NoError(t, tw.WriteHeader(&tar.Header{Name: file.name, Mode: 0600, Size: int64(len(file.contents))}))
+					_, err := io.WriteString(tw, file.contents)
+					require.NoError(t, err)
+				}
+			},
+			wantErr: `invalid path "../escaped"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), archivePrefix+"test"+archiveSuffix)
+			tt.write(t, path)
+			dir := filepath.Join(t.TempDir(), "extracted")
+
+			_, err := extract(path, dir)
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			for name, contents := range files {
+				assert.Equal(t, contents, readFile(t, filepath.Join(dir, filepath.FromSlash(name))))
+			}
+		})
+	}
+}
+
+func TestListAndPrune(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now().UTC().Truncate(time.Second)
+	for i, scheduled := range []bool{true, false, true, true, false, true} {
+		createdAt := now.Add(time.Duration(i) * time.Hour)
+		writeTestArchive(t, filepath.Join(dir, archivePrefix+createdAt.Format(timeFormat)+archiveSuffix),
+			&Manifest{FormatVersion: FormatVersion, CreatedAt: createdAt, Scheduled: scheduled},
+			map[string]string{snapshotName: "snapshot"})
+	}
+	writeFile(t, filepath.Join(dir, archivePrefix+"invalid"+archiveSuffix), "not an archive")
+	writeFile(t, filepath.Join(dir, "unrelated.tar.gz"), "not a backup")
+
+	createdAt := func(backups []*Backup) []time.Time {
+		times := []time.Time{}
+		for _, b := range backups {
+			times = append(times, b.CreatedAt)
+		}
+		return times
+	}
+	hours := func(hours ...int) []time.Time {
+		times := []time.Time{}
+		for _, h := range hours {
+			times = append(times, now.Add(time.Duration(h)*time.Hour))
+		}
+		return times
+	}
+
+	backups, err := List(dir)
+	require.NoError(t, err)
+	assert.Equal(t, hours(5, 4, 3, 2, 1, 0), createdAt(backups))
+
+	removed, err := Prune(dir, 2)
+	require.NoError(t, err)
+	assert.Equal(t, hours(2, 0), createdAt(removed))
+
+	backups, err = List(dir)
+	require.NoError(t, err)
+	assert.Equal(t, hours(5, 4, 3, 1), createdAt(backups))
+
+	backups, err = List(filepath.Join(dir, "missing"))
+	require.NoError(t, err)
+	assert.Empty(t, backups)
+}
+
+func TestCheckVersion(t *testing.T) {
+	tests := []struct {
+		backup, current string
+		wantErr         bool
+	}{
+		{backup: "4.12.0", current: "4.12.3"},
+		{backup: "4.12.0-0.microshift-2022-12-01", current: "4.12.1"},
+		{backup: "4.11.0", current: "4.12.0", wantErr: true},
+		{backup: "4.13.0", current: "4.12.0", wantErr: true},
+		{backup: "unknown", current: "4.12.0"},
+		{backup: "4.12.0", current: "unknown"},
+	}
+	for _, tt := range tests {
+		err := checkVersion(tt.backup, tt.current)
+		assert.Equal(t, tt.wantErr, err != nil, "backup %s, current %s: %v", tt.backup, tt.current, err)
+	}
+}
@@ -0,0 +1,241 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	cmdutil "k8s.io/kubectl/pkg/cmd/util"
+
+	"github.com/openshift/microshift/pkg/backup"
+	"github.com/openshift/microshift/pkg/config"
+	"github.com/openshift/microshift/pkg/controllers"
+	"github.com/openshift/microshift/pkg/servicemanager"
+	"github.com/openshift/microshift/pkg/util"
+	"github.com/openshift/microshift/pkg/util/cryptomaterial"
+)
+
+const backupTimeout = 5 * time.Minute
+
+func NewBackupCommand(ioStreams genericclioptions.IOStreams) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "backup",
+		Short: "Back up and restore MicroShift's data",
+		Long: `Back up and restore MicroShift's data.
+
+A backup is an archive of a snapshot of etcd together with the certs and
+resources directories of MicroShift's data directory. The archive contains a
+manifest with the version of MicroShift that created it and the checksums of
+its files, which are verified on restore.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			cmd.Help()
+		},
+	}
+
+	cmd.AddCommand(NewBackupCreateCommand(ioStreams))
+	cmd.AddCommand(NewBackupListCommand(ioStreams))
+	cmd.AddCommand(NewBackupRestoreCommand(ioStreams))
+	return cmd
+}
+
+// BackupOptions holds the backup directory, backup.directory of the
+// MicroShift instance unless it is overridden by --dir.
+type BackupOptions struct {
+	Config *config.MicroshiftConfig
+	Dir    string
+
+	genericclioptions.IOStreams
+}
+
+func (o *BackupOptions) addFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&o.Dir, "dir", o.Dir, "Directory of the backups. Defaults to backup.directory of the config.")
+	addRunFlags(cmd, config.NewMicroshiftConfig())
+}
+
+// Complete loads the config of the MicroShift instance configured by the
+// config files, environment and `flags`.
+func (o *BackupOptions) Complete(flags *pflag.FlagSet) error {
+	o.Config = config.NewMicroshiftConfig()
+	configFiles, err := config.GetConfigFiles()
+	if err != nil {
+		return err
+	}
+	if err := o.Config.ReadAndValidate(configFiles, flags); err != nil {
+		return err
+	}
+	if o.Dir == "" {
+		o.Dir = o.Config.Backup.Directory
+	}
+	return nil
+}
+
+type BackupCreateOptions struct {
+	BackupOptions
+}
+
+func NewBackupCreateCommand(ioStreams genericclioptions.IOStreams) *cobra.Command {
+	o := &BackupCreateOptions{BackupOptions{IOStreams: ioStreams}}
+	cmd := &cobra.Command{
+		Use:   "create",
+		Short: "Back up MicroShift",
+		Long: `Back up MicroShift.
+
+Takes a snapshot of the running MicroShift's etcd and archives it with the
+certs and resources directories. Backups created by this command are not
+removed by the scheduled backups' retention, see backup.maxBackups.`,
+		Example: `  # Back up MicroShift to the configured backup directory
+  microshift backup create
+
+  # Back up MicroShift to /mnt/backups
+  microshift backup create --dir /mnt/backups`,
+		Run: func(cmd *cobra.Command, args []string) {
+			cmdutil.CheckErr(o.Complete(cmd.Flags()))
+			cmdutil.CheckErr(o.Run())
+		},
+	}
+
+	o.addFlags(cmd)
+	return cmd
+}
+
+func (o *BackupCreateOptions) Run() error {
+	client, err := util.NewEtcdClient(cryptomaterial.CertsDirectory(microshiftDataDir))
+	if err != nil {
+		return fmt.Errorf("failed to connect to etcd, is MicroShift running? %w", err)
+	}
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), backupTimeout)
+	defer cancel()
+	b, err := backup.Create(ctx, client, microshiftDataDir, o.Dir, false)
+	if err != nil {
+		return fmt.Errorf("failed to back up MicroShift, is it running? %w", err)
+	}
+	fmt.Fprintf(o.Out, "backup written to %s\n", b.Path)
+	return nil
+}
+
+type BackupListOptions struct {
+	BackupOptions
+}
+
+func NewBackupListCommand(ioStreams genericclioptions.IOStreams) *cobra.Command {
+	o := &BackupListOptions{BackupOptions{IOStreams: ioStreams}}
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List the backups of MicroShift, newest first",
+		Run: func(cmd *cobra.Command, args []string) {
+			cmdutil.CheckErr(o.Complete(cmd.Flags()))
+			cmdutil.CheckErr(o.Run())
+		},
+	}
+
+	o.addFlags(cmd)
+	return cmd
+}
+
+func (o *BackupListOptions) Run() error {
+	backups, err := backup.List(o.Dir)
+	if err != nil {
+		return err
+	}
+	if len(backups) == 0 {
+		fmt.Fprintf(o.ErrOut, "no backups found in %s\n", o.Dir)
+		return nil
+	}
+
+	w := tabwriter.NewWriter(o.Out, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tCREATED\tVERSION\tSCHEDULED\tSIZE")
+	for _, b := range backups {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%t\t%s\n", b.Name(), b.CreatedAt.Format(time.RFC3339), b.MicroShiftVersion,
+			b.Scheduled, resource.NewQuantity(b.Size, resource.BinarySI))
+	}
+	return w.Flush()
+}
+
+type BackupRestoreOptions struct {
+	Path       string
+	Force      bool
+	SocketPath string
+
+	BackupOptions
+}
+
+func NewBackupRestoreCommand(ioStreams genericclioptions.IOStreams) *cobra.Command {
+	o := &BackupRestoreOptions{
+		SocketPath:    servicemanager.StatusSocketPath,
+		BackupOptions: BackupOptions{IOStreams: ioStreams},
+	}
+	cmd := &cobra.Command{
+		Use:   "restore NAME|PATH",
+		Short: "Restore MicroShift from a backup",
+		Long: `Restore MicroShift from a backup.
+
+Verifies the checksums of the backup's files and restores etcd's data from its
+snapshot, together with the certs and resources directories. The data that is
+replaced is moved to the pre-restore directory of the data directory, which
+replaces the one of the previous restore. MicroShift must be stopped while
+restoring a backup.
+
+Backups created by a different minor version of MicroShift are only restored
+with --force.`,
+		Example: `  # Restore the backup microshift-backup-20221201T020000Z.tar.gz of the backup directory
+  microshift backup restore microshift-backup-20221201T020000Z.tar.gz
+
+  # Restore a backup copied from another host
+  microshift backup restore /tmp/microshift-backup-20221201T020000Z.tar.gz`,
+		Run: func(cmd *cobra.Command, args []string) {
+			cmdutil.CheckErr(o.Validate(args))
+			cmdutil.CheckErr(o.Complete(args, cmd.Flags()))
+			cmdutil.CheckErr(o.Run())
+		},
+	}
+
+	cmd.Flags().BoolVar(&o.Force, "force", o.Force, "Restore a backup created by a different version of MicroShift.")
+	o.addFlags(cmd)
+	return cmd
+}
+
+func (o *BackupRestoreOptions) Validate(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("exactly one backup is required")
+	}
+	if _, err := servicemanager.GetStatus(o.SocketPath); err == nil {
+		return fmt.Errorf("MicroShift is running, stop it before restoring a backup")
+	}
+	return nil
+}
+
+// Complete resolves backup names relative to the backup directory.
+func (o *BackupRestoreOptions) Complete(args []string, flags *pflag.FlagSet) error {
+	if err := o.BackupOptions.Complete(flags); err != nil {
+		return err
+	}
+	o.Path = args[0]
+	if !strings.ContainsRune(o.Path, os.PathSeparator) {
+		o.Path = filepath.Join(o.Dir, o.Path)
+	}
+	return nil
+}
+
+func (o *BackupRestoreOptions) Run() error {
+	manifest, err := backup.Restore(o.Path, microshiftDataDir, backup.RestoreOptions{
+		EtcdName:    o.Config.NodeName,
+		EtcdPeerURL: controllers.EtcdPeerURL(o.Config),
+		Force:       o.Force,
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(o.Out, "restored the backup of MicroShift %s created at %s, the previous data is in %s\n",
+		manifest.MicroShiftVersion, manifest.CreatedAt.Format(time.RFC3339), filepath.Join(microshiftDataDir, backup.PreRestoreDir))
+	return nil
+}
@@ -0,0 +1,349 @@
+package cmd
+
+import (
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	cmdutil "k8s.io/kubectl/pkg/cmd/util"
+
+	"github.com/openshift/microshift/pkg/config"
+	"github.com/openshift/microshift/pkg/servicemanager"
+	"github.com/openshift/microshift/pkg/util/cryptomaterial"
+	"github.com/openshift/microshift/pkg/util/cryptomaterial/certchains"
+)
+
+func NewCertsCommand(ioStreams genericclioptions.IOStreams) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "certs",
+		Short: "Inspect and rotate MicroShift's certificates",
+		Long: `Inspect and rotate MicroShift's certificates.
+
+Certificates are identified by their path in the certificate chains, i.e. the
+names of their signers and their own name joined by "/", e.g.
+kube-control-plane-signer/kube-scheduler. The commands read MicroShift's
+configuration to determine the certificates it uses, so flags MicroShift is run
+with must be passed to them as well.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			cmd.Help()
+		},
+	}
+
+	cmd.AddCommand(NewCertsListCommand(ioStreams))
+	cmd.AddCommand(NewCertsCheckCommand(ioStreams))
+	cmd.AddCommand(NewCertsRotateCommand(ioStreams))
+	return cmd
+}
+
+// CertsOptions are shared by the certs commands.
+type CertsOptions struct {
+	Config     *config.MicroshiftConfig
+	CertChains *certchains.CertificateChains
+
+	genericclioptions.IOStreams
+}
+
+// Complete loads the certificate chains of the MicroShift instance configured
+// by the config files, environment and `flags`. The chains are only read, so
+// that they can be inspected while MicroShift runs.
+func (o *CertsOptions) Complete(flags *pflag.FlagSet) error {
+	if err := o.loadConfig(flags); err != nil {
+		return err
+	}
+
+	var err error
+	o.CertChains, err = loadCerts(o.Config)
+	return err
+}
+
+func (o *CertsOptions) loadConfig(flags *pflag.FlagSet) error {
+	certsDir := cryptomaterial.CertsDirectory(microshiftDataDir)
+	if _, err := os.Stat(certsDir); errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("no certificates found in %s, MicroShift has not been started yet", certsDir)
+	} else if err != nil {
+		return err
+	}
+
+	o.Config = config.NewMicroshiftConfig()
+	configFiles, err := config.GetConfigFiles()
+	if err != nil {
+		return err
+	}
+	return o.Config.ReadAndValidate(configFiles, flags)
+}
+
+type certInfo struct {
+	Path            string    `json:"path"`
+	Subject         string    `json:"subject"`
+	Issuer          string    `json:"issuer"`
+	SubjectAltNames []string  `json:"subjectAltNames,omitempty"`
+	NotBefore       time.Time `json:"notBefore"`
+	NotAfter        time.Time `json:"notAfter"`
+	RotateAt        time.Time `json:"rotateAt"`
+
+	name  string
+	depth int
+}
+
+// listCerts returns the certificates in the chains, each signer followed by
+// its sub-CAs and certificates.
+func listCerts(cs *certchains.CertificateChains) ([]certInfo, error) {
+	certs := []certInfo{}
+	err := cs.WalkChains(nil, func(certPath []string, c x509.Certificate) error {
+		// library-go adds IP addresses to the DNS names as well
+		sans := append([]string{}, c.DNSNames...)
+		for _, ip := range c.IPAddresses {
+			if !config.StringInList(ip.String(), sans) {
+				sans = append(sans, ip.String())
+			}
+		}
+		certs = append(certs, certInfo{
+			Path:            strings.Join(certPath, "/"),
+			Subject:         c.Subject.String(),
+			Issuer:          c.Issuer.String(),
+			SubjectAltNames: sans,
+			NotBefore:       c.NotBefore.UTC(),
+			NotAfter:        c.NotAfter.UTC(),
+			RotateAt:        certchains.WhenToRotate(&c).UTC(),
+			name:            certPath[len(certPath)-1],
+			depth:           len(certPath) - 1,
+		})
+		return nil
+	})
+	return certs, err
+}
+
+type CertsListOptions struct {
+	Output string
+
+	CertsOptions
+}
+
+func NewCertsListCommand(ioStreams genericclioptions.IOStreams) *cobra.Command {
+	o := &CertsListOptions{
+		Output:       "text",
+		CertsOptions: CertsOptions{IOStreams: ioStreams},
+	}
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List MicroShift's certificates",
+		Long: `List MicroShift's certificates.
+
+Prints the tree of signers, their sub-CAs and the certificates they signed with
+their subject, subject alternative names, validity and the date MicroShift
+rotates them at.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			cmdutil.CheckErr(o.Validate())
+			cmdutil.CheckErr(o.Complete(cmd.Flags()))
+			cmdutil.CheckErr(o.Run())
+		},
+	}
+
+	cmd.Flags().StringVarP(&o.Output, "output", "o", o.Output, "One of 'text' or 'json'.")
+	addRunFlags(cmd, config.NewMicroshiftConfig())
+
+	return cmd
+}
+
+func (o *CertsListOptions) Validate() error {
+	switch o.Output {
+	case "text", "json":
+		return nil
+	default:
+		return fmt.Errorf("invalid output format %q, must be one of 'text' or 'json'", o.Output)
+	}
+}
+
+func (o *CertsListOptions) Run() error {
+	certs, err := listCerts(o.CertChains)
+	if err != nil {
+		return err
+	}
+
+	switch o.Output {
+	case "text":
+		w := tabwriter.NewWriter(o.Out, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "NAME\tSUBJECT\tNOT BEFORE\tNOT AFTER\tROTATE AT\tSUBJECT ALT NAMES")
+		for _, c := range certs {
+			fmt.Fprintf(w, "%s%s\t%s\t%s\t%s\t%s\t%s\n",
+				strings.Repeat("  ", c.depth), c.name, c.Subject,
+				c.NotBefore.Format(time.RFC3339), c.NotAfter.Format(time.RFC3339), c.RotateAt.Format(time.RFC3339),
+				strings.Join(c.SubjectAltNames, ","))
+		}
+		return w.Flush()
+	case "json":
+		marshalled, err := json.MarshalIndent(certs, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(o.Out, string(marshalled))
+	default:
+		// There is a bug in the program if we hit this case.
+		// However, we follow a policy of never panicking.
+		return fmt.Errorf("CertsListOptions were not validated: --output=%q should have been rejected", o.Output)
+	}
+
+	return nil
+}
+
+type CertsCheckOptions struct {
+	Threshold time.Duration
+
+	CertsOptions
+}
+
+func NewCertsCheckCommand(ioStreams genericclioptions.IOStreams) *cobra.Command {
+	o := &CertsCheckOptions{
+		Threshold:    90 * 24 * time.Hour,
+		CertsOptions: CertsOptions{IOStreams: ioStreams},
+	}
+	cmd := &cobra.Command{
+		Use:   "check",
+		Short: "Check MicroShift's certificates for expiry",
+		Long: `Check MicroShift's certificates for expiry.
+
+Reports all certificates that are not valid yet, expired or expire within the
+threshold and exits with a non-zero code if there are any. MicroShift rotates
+certificates 4 to 12 months before they expire, so certificates reported with
+the default threshold were not rotated in time.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			cmdutil.CheckErr(o.Validate())
+			cmdutil.CheckErr(o.Complete(cmd.Flags()))
+			cmdutil.CheckErr(o.Run())
+		},
+	}
+
+	cmd.Flags().DurationVar(&o.Threshold, "threshold", o.Threshold, "Report certificates that expire within this duration.")
+	addRunFlags(cmd, config.NewMicroshiftConfig())
+
+	return cmd
+}
+
+func (o *CertsCheckOptions) Validate() error {
+	if o.Threshold < 0 {
+		return fmt.Errorf("--threshold must not be negative")
+	}
+	return nil
+}
+
+func (o *CertsCheckOptions) Run() error {
+	certs, err := listCerts(o.CertChains)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	failed := 0
+	for _, c := range certs {
+		switch {
+		case now.Before(c.NotBefore):
+			fmt.Fprintf(o.Out, "%s: not valid before %s\n", c.Path, c.NotBefore.Format(time.RFC3339))
+		case now.After(c.NotAfter):
+			fmt.Fprintf(o.Out, "%s: expired at %s\n", c.Path, c.NotAfter.Format(time.RFC3339))
+		case c.NotAfter.Sub(now) < o.Threshold:
+			fmt.Fprintf(o.Out, "%s: expires at %s, in %s\n", c.Path, c.NotAfter.Format(time.RFC3339), c.NotAfter.Sub(now).Round(time.Minute))
+		default:
+			continue
+		}
+		failed++
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d certificates are invalid or expire within %s", failed, len(certs), o.Threshold)
+	}
+	fmt.Fprintf(o.Out, "all %d certificates are valid for more than %s\n", len(certs), o.Threshold)
+	return nil
+}
+
+type CertsRotateOptions struct {
+	CertPaths  [][]string
+	SocketPath string
+
+	CertsOptions
+}
+
+func NewCertsRotateCommand(ioStreams genericclioptions.IOStreams) *cobra.Command {
+	o := &CertsRotateOptions{
+		SocketPath:   servicemanager.StatusSocketPath,
+		CertsOptions: CertsOptions{IOStreams: ioStreams},
+	}
+	cmd := &cobra.Command{
+		Use:   "rotate <path>...",
+		Short: "Rotate MicroShift's certificates",
+		Long: `Rotate MicroShift's certificates.
+
+Regenerates the certificates at the given paths. Rotating a signer regenerates
+it together with all certificates it signed, and updates the CA bundles it is
+part of. The kubeconfigs of MicroShift's components are updated with the new
+certificates. MicroShift must be stopped while rotating certificates.`,
+		Example: `  # Rotate the kube-scheduler client certificate
+  microshift certs rotate kube-control-plane-signer/kube-scheduler
+
+  # Rotate the kubelet signer and all certificates it signed
+  microshift certs rotate kubelet-signer`,
+		Run: func(cmd *cobra.Command, args []string) {
+			cmdutil.CheckErr(o.Validate(args))
+			cmdutil.CheckErr(o.Complete(args, cmd.Flags()))
+			cmdutil.CheckErr(o.Run())
+		},
+	}
+
+	addRunFlags(cmd, config.NewMicroshiftConfig())
+
+	return cmd
+}
+
+// Validate makes sure MicroShift is stopped, as the certificate chains are
+// completed and modified while it uses them.
+func (o *CertsRotateOptions) Validate(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("at least one certificate path is required")
+	}
+	if _, err := servicemanager.GetStatus(o.SocketPath); err == nil {
+		return fmt.Errorf("MicroShift is running, stop it before rotating certificates")
+	}
+	return nil
+}
+
+func (o *CertsRotateOptions) Complete(args []string, flags *pflag.FlagSet) error {
+	for _, arg := range args {
+		o.CertPaths = append(o.CertPaths, strings.Split(strings.Trim(arg, "/"), "/"))
+	}
+	if err := o.loadConfig(flags); err != nil {
+		return err
+	}
+
+	var err error
+	o.CertChains, err = certSetup(o.Config)
+	return err
+}
+
+func (o *CertsRotateOptions) Run() error {
+	// check all paths first to not leave the certificates partially rotated
+	// because of a typo
+	for _, certPath := range o.CertPaths {
+		if err := o.CertChains.WalkChains(certPath, func([]string, x509.Certificate) error { return nil }); err != nil {
+			return fmt.Errorf("invalid certificate path %q: %w", strings.Join(certPath, "/"), err)
+		}
+	}
+
+	for _, certPath := range o.CertPaths {
+		if err := o.CertChains.Regenerate(certPath...); err != nil {
+			return fmt.Errorf("failed to rotate %q: %w", strings.Join(certPath, "/"), err)
+		}
+		fmt.Fprintf(o.Out, "%s rotated\n", strings.Join(certPath, "/"))
+	}
+
+	if err := initKubeconfigs(o.Config, o.CertChains); err != nil {
+		return fmt.Errorf("failed to update the kubeconfigs: %w", err)
+	}
+	return nil
+}
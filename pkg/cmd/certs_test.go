@@ -0,0 +1,104 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+
+	"github.com/openshift/microshift/pkg/servicemanager"
+	"github.com/openshift/microshift/pkg/util/cryptomaterial/certchains"
+)
+
+func testCertsOptions(t *testing.T) (CertsOptions, *bytes.Buffer) {
+	chains := mustComplete(t, certchains.NewCertificateChains(
+		certchains.NewCertificateSigner("signer", t.TempDir(), 365).
+			WithClientCertificates(&certchains.ClientCertificateSigningRequestInfo{
+				CSRMeta:  certchains.CSRMeta{Name: "client", ValidityDays: 30},
+				UserInfo: &user.DefaultInfo{Name: "client"},
+			}).
+			WithServingCertificates(&certchains.ServingCertificateSigningRequestInfo{
+				CSRMeta:   certchains.CSRMeta{Name: "server", ValidityDays: 200},
+				Hostnames: []string{"localhost", "127.0.0.1"},
+			}),
+	))
+	ioStreams, _, out, _ := genericclioptions.NewTestIOStreams()
+	return CertsOptions{CertChains: chains, IOStreams: ioStreams}, out
+}
+
+func TestCertsList(t *testing.T) {
+	certsOptions, out := testCertsOptions(t)
+	o := &CertsListOptions{Output: "json", CertsOptions: certsOptions}
+	require.NoError(t, o.Validate())
+	require.NoError(t, o.Run())
+
+	certs := []certInfo{}
+	require.NoError(t, json.Unmarshal(out.Bytes(), &certs))
+	paths := []string{}
+	for _, c := range certs {
+		paths = append(paths, c.Path)
+	}
+	require.Equal(t, []string{"signer", "signer/client", "signer/server"}, paths)
+	require.Equal(t, "CN=signer", certs[2].Issuer)
+	require.Equal(t, []string{"localhost", "127.0.0.1"}, certs[2].SubjectAltNames)
+	require.Equal(t, certs[2].NotAfter.Add(-4*30*24*time.Hour), certs[2].RotateAt)
+
+	out.Reset()
+	o.Output = "text"
+	require.NoError(t, o.Run())
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	require.Len(t, lines, 4)
+	require.True(t, strings.HasPrefix(lines[1], "signer "), lines[1])
+	require.True(t, strings.HasPrefix(lines[2], "  client "), lines[2])
+}
+
+func TestCertsCheck(t *testing.T) {
+	certsOptions, out := testCertsOptions(t)
+	o := &CertsCheckOptions{Threshold: 7 * 24 * time.Hour, CertsOptions: certsOptions}
+	require.NoError(t, o.Run())
+	require.Contains(t, out.String(), "all 3 certificates are valid")
+
+	out.Reset()
+	o.Threshold = 60 * 24 * time.Hour
+	require.EqualError(t, o.Run(), "1 of 3 certificates are invalid or expire within 1440h0m0s")
+	require.True(t, strings.HasPrefix(out.String(), "signer/client: expires at "), out.String())
+}
+
+func TestCertsRotateValidate(t *testing.T) {
+	socketPath := filepath.Join(t.TempDir(), "status.sock")
+	o := &CertsRotateOptions{SocketPath: socketPath}
+	require.Error(t, o.Validate(nil))
+	require.NoError(t, o.Validate([]string{"signer/client"}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go servicemanager.NewServiceManager().ServeStatus(ctx, socketPath)
+	require.Eventually(t, func() bool {
+		_, err := servicemanager.GetStatus(socketPath)
+		return err == nil
+	}, 5*time.Second, 10*time.Millisecond)
+	require.EqualError(t, o.Validate([]string{"signer/client"}), "MicroShift is running, stop it before rotating certificates")
+}
+
+func TestCertsRotateInvalidPath(t *testing.T) {
+	certsOptions, _ := testCertsOptions(t)
+	before, _, err := certsOptions.CertChains.GetCertKey("signer", "client")
+	require.NoError(t, err)
+
+	o := &CertsRotateOptions{
+		CertPaths:    [][]string{{"signer", "client"}, {"signer", "nosuchcert"}},
+		CertsOptions: certsOptions,
+	}
+	require.Error(t, o.Run())
+
+	after, _, err := certsOptions.CertChains.GetCertKey("signer", "client")
+	require.NoError(t, err)
+	require.Equal(t, before, after, "no certificate must be rotated if a path is invalid")
+}
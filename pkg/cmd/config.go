@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/openshift/microshift/pkg/config"
+	"github.com/spf13/cobra"
+
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	cmdutil "k8s.io/kubectl/pkg/cmd/util"
+)
+
+func NewConfigCommand(ioStreams genericclioptions.IOStreams) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "config",
+		Short: "Manage MicroShift's configuration",
+		Run: func(cmd *cobra.Command, args []string) {
+			cmd.Help()
+		},
+	}
+
+	cmd.AddCommand(NewConfigValidateCommand(ioStreams))
+	return cmd
+}
+
+type ConfigValidateOptions struct {
+	ConfigFiles []string
+
+	genericclioptions.IOStreams
+}
+
+func NewConfigValidateCommand(ioStreams genericclioptions.IOStreams) *cobra.Command {
+	o := &ConfigValidateOptions{IOStreams: ioStreams}
+	cmd := &cobra.Command{
+		Use:   "validate [file...]",
+		Short: "Validate MicroShift config files",
+		Long: `Validate MicroShift config files.
+
+The files are merged in the given order, the same way MicroShift merges its
+config file with the drop-in config files. Reports all unknown fields and
+invalid values found together with the file and line they were set at and
+exits with a non-zero code if there are any. Validates the config files
+MicroShift would use if no file is given.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			cmdutil.CheckErr(o.Complete(args))
+			cmdutil.CheckErr(o.Run())
+		},
+	}
+	return cmd
+}
+
+func (o *ConfigValidateOptions) Complete(args []string) error {
+	if len(args) > 0 {
+		o.ConfigFiles = args
+		return nil
+	}
+	configFiles, err := config.GetConfigFiles()
+	if err != nil {
+		return err
+	}
+	if len(configFiles) == 0 {
+		return fmt.Errorf("no config files found, specify the files to validate")
+	}
+	o.ConfigFiles = configFiles
+	return nil
+}
+
+func (o *ConfigValidateOptions) Run() error {
+	err := config.ValidateConfigFiles(o.ConfigFiles)
+	if err == nil {
+		fmt.Fprintf(o.Out, "configuration in %s is valid\n", strings.Join(o.ConfigFiles, ", "))
+		return nil
+	}
+
+	var configErrs *config.ConfigErrors
+	if !errors.As(err, &configErrs) {
+		return err
+	}
+	for _, e := range configErrs.Errors {
+		fmt.Fprintln(o.ErrOut, e.Error())
+	}
+	return fmt.Errorf("configuration is invalid: found %d errors", len(configErrs.Errors))
+}
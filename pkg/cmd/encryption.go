@@ -0,0 +1,208 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	cmdutil "k8s.io/kubectl/pkg/cmd/util"
+
+	"github.com/openshift/microshift/pkg/config"
+	"github.com/openshift/microshift/pkg/encryption"
+	"github.com/openshift/microshift/pkg/servicemanager"
+	"github.com/openshift/microshift/pkg/util"
+	"github.com/openshift/microshift/pkg/util/cryptomaterial"
+)
+
+// etcdResourcePrefix is the prefix of the keys kube-apiserver stores
+// resources under in etcd.
+const etcdResourcePrefix = "/kubernetes.io/"
+
+func NewEncryptionCommand(ioStreams genericclioptions.IOStreams) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "encryption",
+		Short: "Manage the encryption of secrets and configmaps in etcd",
+		Run: func(cmd *cobra.Command, args []string) {
+			cmd.Help()
+		},
+	}
+
+	cmd.AddCommand(NewEncryptionRotateKeyCommand(ioStreams))
+	cmd.AddCommand(NewEncryptionStatusCommand(ioStreams))
+	return cmd
+}
+
+// EncryptionOptions holds the config and the encryption state of the
+// MicroShift instance.
+type EncryptionOptions struct {
+	Config     *config.MicroshiftConfig
+	State      *encryption.State
+	SocketPath string
+
+	genericclioptions.IOStreams
+}
+
+// Complete loads the config of the MicroShift instance configured by the
+// config files, environment and `flags`, and its encryption state.
+func (o *EncryptionOptions) Complete(flags *pflag.FlagSet) error {
+	o.Config = config.NewMicroshiftConfig()
+	configFiles, err := config.GetConfigFiles()
+	if err != nil {
+		return err
+	}
+	if err := o.Config.ReadAndValidate(configFiles, flags); err != nil {
+		return err
+	}
+	o.State, err = encryption.Load(encryption.Dir(microshiftDataDir))
+	return err
+}
+
+func NewEncryptionRotateKeyCommand(ioStreams genericclioptions.IOStreams) *cobra.Command {
+	o := &EncryptionOptions{
+		SocketPath: servicemanager.StatusSocketPath,
+		IOStreams:  ioStreams,
+	}
+	cmd := &cobra.Command{
+		Use:   "rotate-key",
+		Short: "Rotate the key secrets and configmaps are encrypted with",
+		Long: `Rotate the key secrets and configmaps are encrypted with.
+
+Adds a new key of the type configured in apiServer.encryption.type, which the
+API server encrypts with on the next start of MicroShift. MicroShift then
+rewrites all secrets and configmaps to encrypt them with the new key, and
+removes the previous keys on the following start. MicroShift must be stopped
+while rotating the key.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			cmdutil.CheckErr(o.ValidateStopped())
+			cmdutil.CheckErr(o.Complete(cmd.Flags()))
+			cmdutil.CheckErr(o.RunRotateKey())
+		},
+	}
+
+	addRunFlags(cmd, config.NewMicroshiftConfig())
+	return cmd
+}
+
+// ValidateStopped makes sure MicroShift is stopped, as the API server only
+// reads the encryption configuration on start.
+func (o *EncryptionOptions) ValidateStopped() error {
+	if _, err := servicemanager.GetStatus(o.SocketPath); err == nil {
+		return fmt.Errorf("MicroShift is running, stop it before rotating the encryption key")
+	}
+	return nil
+}
+
+func (o *EncryptionOptions) RunRotateKey() error {
+	encryptionType := o.Config.APIServer.Encryption.ProviderType()
+	if encryptionType == encryption.Identity {
+		return fmt.Errorf("encryption is disabled, set apiServer.encryption.type to enable it")
+	}
+	key, err := o.State.Rotate(encryptionType)
+	if err != nil {
+		return err
+	}
+	if err := o.State.Write(); err != nil {
+		return err
+	}
+	fmt.Fprintf(o.Out, "encryption key %s added, start MicroShift to encrypt the secrets and configmaps with it\n", key.ID())
+	return nil
+}
+
+func NewEncryptionStatusCommand(ioStreams genericclioptions.IOStreams) *cobra.Command {
+	o := &EncryptionOptions{
+		SocketPath: servicemanager.StatusSocketPath,
+		IOStreams:  ioStreams,
+	}
+	cmd := &cobra.Command{
+		Use:   "status",
+		Short: "Show the encryption of secrets and configmaps",
+		Long: `Show the encryption of secrets and configmaps.
+
+Shows the key the API server encrypts with, the keys it can still decrypt and
+whether all secrets and configmaps were rewritten with the current key. If
+MicroShift is running, the number of stored secrets and configmaps is listed
+by the key they are actually encrypted with in etcd.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			cmdutil.CheckErr(o.Complete(cmd.Flags()))
+			cmdutil.CheckErr(o.RunStatus())
+		},
+	}
+
+	addRunFlags(cmd, config.NewMicroshiftConfig())
+	return cmd
+}
+
+func (o *EncryptionOptions) RunStatus() error {
+	readKeys := []string{}
+	for _, key := range o.State.Keys[1:] {
+		readKeys = append(readKeys, key.ID())
+	}
+	if len(readKeys) == 0 {
+		readKeys = append(readKeys, "none")
+	}
+	migration := "complete"
+	if o.State.MigrationPending() {
+		migration = "pending, secrets and configmaps are rewritten on the next start of MicroShift"
+	}
+
+	w := tabwriter.NewWriter(o.Out, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(w, "Configured type:\t%s\n", o.Config.APIServer.Encryption.ProviderType())
+	fmt.Fprintf(w, "Write key:\t%s\n", o.State.WriteKey().ID())
+	fmt.Fprintf(w, "Read keys:\t%s\n", strings.Join(readKeys, ", "))
+	fmt.Fprintf(w, "Re-encryption:\t%s\n", migration)
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if _, err := servicemanager.GetStatus(o.SocketPath); err != nil {
+		fmt.Fprintln(o.ErrOut, "MicroShift is not running, the stored resources cannot be inspected")
+		return nil
+	}
+	client, err := util.NewEtcdClient(cryptomaterial.CertsDirectory(microshiftDataDir))
+	if err != nil {
+		return fmt.Errorf("failed to connect to etcd: %w", err)
+	}
+	defer client.Close()
+
+	fmt.Fprintln(o.Out)
+	w = tabwriter.NewWriter(o.Out, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "RESOURCE\tKEY\tCOUNT")
+	for _, resource := range encryption.Resources {
+		counts, err := countKeys(client, resource)
+		if err != nil {
+			return fmt.Errorf("failed to read the %s from etcd: %w", resource, err)
+		}
+		keys := make([]string, 0, len(counts))
+		for key := range counts {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			fmt.Fprintf(w, "%s\t%s\t%d\n", resource, key, counts[key])
+		}
+	}
+	return w.Flush()
+}
+
+// countKeys returns the number of objects of the resource in etcd by the ID
+// of the key they are encrypted with.
+func countKeys(client *clientv3.Client, resource string) (map[string]int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	resp, err := client.Get(ctx, etcdResourcePrefix+resource+"/", clientv3.WithPrefix())
+	if err != nil {
+		return nil, err
+	}
+	counts := map[string]int{}
+	for _, kv := range resp.Kvs {
+		counts[encryption.KeyOf(kv.Value)]++
+	}
+	return counts, nil
+}
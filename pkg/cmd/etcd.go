@@ -0,0 +1,463 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os/signal"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	cmdutil "k8s.io/kubectl/pkg/cmd/util"
+
+	"github.com/openshift/microshift/pkg/config"
+	"github.com/openshift/microshift/pkg/controllers"
+	"github.com/openshift/microshift/pkg/util"
+	"github.com/openshift/microshift/pkg/util/cryptomaterial"
+)
+
+const (
+	etcdCommandTimeout     = 30 * time.Second
+	etcdMaintenanceTimeout = 5 * time.Minute
+)
+
+// The latencies etcd's disk should stay below, see
+// https://etcd.io/docs/v3.5/faq/#what-does-the-etcd-warning-failed-to-send-out-heartbeat-on-time-mean
+const (
+	walFsyncMetric          = "etcd_disk_wal_fsync_duration_seconds"
+	walFsyncThreshold       = 10 * time.Millisecond
+	backendCommitMetric     = "etcd_disk_backend_commit_duration_seconds"
+	backendCommitThreshold  = 25 * time.Millisecond
+	diskLatencyQuantile     = 0.99
+	diskLatencyQuantileName = "p99"
+)
+
+func NewEtcdCommand(ioStreams genericclioptions.IOStreams) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "etcd",
+		Short: "Run and maintain MicroShift's etcd",
+		Long: `Run and maintain MicroShift's etcd.
+
+The commands connect to the etcd of the running MicroShift with the client
+certificate MicroShift manages for the API server, so they must be run as root.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			cmd.Help()
+		},
+	}
+
+	cmd.AddCommand(NewEtcdRunCommand(ioStreams))
+	cmd.AddCommand(NewEtcdStatusCommand(ioStreams))
+	cmd.AddCommand(NewEtcdDefragCommand(ioStreams))
+	cmd.AddCommand(NewEtcdCompactCommand(ioStreams))
+	cmd.AddCommand(NewEtcdCheckPerfCommand(ioStreams))
+	cmd.AddCommand(NewEtcdAlarmCommand(ioStreams))
+	return cmd
+}
+
+// EtcdOptions holds the config of the MicroShift instance whose etcd is
+// managed, and the clients of the etcd.
+type EtcdOptions struct {
+	Config *config.MicroshiftConfig
+
+	// Client of the etcd at Endpoint, which serves its metrics and health
+	// at MetricsEndpoint.
+	Client          *clientv3.Client
+	Endpoint        string
+	HTTPClient      *http.Client
+	MetricsEndpoint string
+
+	genericclioptions.IOStreams
+}
+
+func newEtcdOptions(ioStreams genericclioptions.IOStreams) EtcdOptions {
+	return EtcdOptions{
+		Endpoint:        util.EtcdEndpoint,
+		MetricsEndpoint: util.EtcdMetricsEndpoint,
+		IOStreams:       ioStreams,
+	}
+}
+
+// Complete loads the config of the MicroShift instance configured by the
+// config files, environment and `flags`.
+func (o *EtcdOptions) Complete(flags *pflag.FlagSet) error {
+	o.Config = config.NewMicroshiftConfig()
+	configFiles, err := config.GetConfigFiles()
+	if err != nil {
+		return err
+	}
+	return o.Config.ReadAndValidate(configFiles, flags)
+}
+
+// Connect creates the clients of MicroShift's etcd with the API server's
+// etcd client certificate.
+func (o *EtcdOptions) Connect() error {
+	certsDir := cryptomaterial.CertsDirectory(microshiftDataDir)
+	tlsConfig, err := util.EtcdClientTLSConfig(certsDir)
+	if err != nil {
+		return fmt.Errorf("failed to load the etcd client certificate: %w", err)
+	}
+	o.HTTPClient = &http.Client{
+		Transport: &http.Transport{TLSClientConfig: tlsConfig},
+		Timeout:   etcdCommandTimeout,
+	}
+	o.Client, err = util.NewEtcdClient(certsDir)
+	if err != nil {
+		return fmt.Errorf("failed to connect to etcd, is MicroShift running? %w", err)
+	}
+	return nil
+}
+
+// newEtcdCommand returns a command of the etcd group that connects to etcd
+// before calling run.
+func newEtcdCommand(o *EtcdOptions, cmd *cobra.Command, run func() error) *cobra.Command {
+	cmd.Run = func(cmd *cobra.Command, args []string) {
+		cmdutil.CheckErr(o.Complete(cmd.Flags()))
+		cmdutil.CheckErr(o.Connect())
+		defer o.Client.Close()
+		cmdutil.CheckErr(run())
+	}
+	addRunFlags(cmd, config.NewMicroshiftConfig())
+	return cmd
+}
+
+func NewEtcdRunCommand(ioStreams genericclioptions.IOStreams) *cobra.Command {
+	o := newEtcdOptions(ioStreams)
+	cmd := &cobra.Command{
+		Use:   "run",
+		Short: "Run etcd in the foreground",
+		Long: `Run etcd in the foreground until it receives SIGTERM or SIGINT.
+
+MicroShift starts etcd with this command when etcd.mode is "process", it is
+not meant to be run directly.`,
+		Hidden: true,
+		Run: func(cmd *cobra.Command, args []string) {
+			cmdutil.CheckErr(o.Complete(cmd.Flags()))
+			cmdutil.CheckErr(o.RunEtcd())
+		},
+	}
+
+	addRunFlags(cmd, config.NewMicroshiftConfig())
+	return cmd
+}
+
+func (o *EtcdOptions) RunEtcd() error {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+	defer stop()
+
+	err := controllers.NewEtcd(o.Config).RunEmbedded(ctx, make(chan struct{}))
+	if errors.Is(err, context.Canceled) {
+		return nil
+	}
+	return err
+}
+
+func NewEtcdStatusCommand(ioStreams genericclioptions.IOStreams) *cobra.Command {
+	o := newEtcdOptions(ioStreams)
+	return newEtcdCommand(&o, &cobra.Command{
+		Use:   "status",
+		Short: "Show the status and health of etcd",
+		Long: `Show the status and health of etcd.
+
+Shows etcd's version, revision, the size of its database relative to
+etcd.quotaBackendSize, the space that defragmentation would free, its alarms,
+and the 99th percentile of the latencies of its disk since it started. etcd
+needs WAL fsyncs below 10ms and backend commits below 25ms to stay healthy.`,
+	}, o.RunStatus)
+}
+
+func (o *EtcdOptions) RunStatus() error {
+	ctx, cancel := context.WithTimeout(context.Background(), etcdCommandTimeout)
+	defer cancel()
+	status, err := o.Client.Status(ctx, o.Endpoint)
+	if err != nil {
+		return fmt.Errorf("failed to get the status of etcd, is MicroShift running? %w", err)
+	}
+	members, err := o.Client.MemberList(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list the members of etcd: %w", err)
+	}
+	alarms, err := o.Client.AlarmList(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list the alarms of etcd: %w", err)
+	}
+
+	member := fmt.Sprintf("%x", status.Header.MemberId)
+	for _, m := range members.Members {
+		if m.ID == status.Header.MemberId {
+			member = fmt.Sprintf("%s (%x)", m.Name, m.ID)
+		}
+	}
+	alarmList := []string{}
+	for _, alarm := range alarms.Alarms {
+		alarmList = append(alarmList, alarm.Alarm.String())
+	}
+	if len(alarmList) == 0 {
+		alarmList = append(alarmList, "none")
+	}
+	quota := o.Config.Etcd.QuotaBackendBytes()
+	fragmented := int64(0)
+	if status.DbSize > 0 {
+		fragmented = (status.DbSize - status.DbSizeInUse) * 100 / status.DbSize
+	}
+
+	w := tabwriter.NewWriter(o.Out, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(w, "Endpoint:\t%s\n", o.Endpoint)
+	fmt.Fprintf(w, "Member:\t%s\n", member)
+	fmt.Fprintf(w, "Health:\t%s\n", o.health(ctx))
+	fmt.Fprintf(w, "Version:\t%s\n", status.Version)
+	fmt.Fprintf(w, "Leader:\t%t\n", status.Leader == status.Header.MemberId)
+	fmt.Fprintf(w, "Revision:\t%d\n", status.Header.Revision)
+	fmt.Fprintf(w, "Raft term/index:\t%d/%d\n", status.RaftTerm, status.RaftIndex)
+	fmt.Fprintf(w, "Database size:\t%s of %s quota (%d%%)\n", resource.NewQuantity(status.DbSize, resource.BinarySI),
+		resource.NewQuantity(quota, resource.BinarySI), status.DbSize*100/quota)
+	fmt.Fprintf(w, "Database size in use:\t%s (%d%% fragmented)\n", resource.NewQuantity(status.DbSizeInUse, resource.BinarySI), fragmented)
+	fmt.Fprintf(w, "Alarms:\t%s\n", strings.Join(alarmList, ", "))
+
+	metrics, err := o.metrics(ctx)
+	if err != nil {
+		fmt.Fprintf(w, "Disk latencies:\tunavailable: %v\n", err)
+		return w.Flush()
+	}
+	fmt.Fprintf(w, "WAL fsync %s:\t%s\n", diskLatencyQuantileName, diskLatency(metrics, walFsyncMetric, walFsyncThreshold))
+	fmt.Fprintf(w, "Backend commit %s:\t%s\n", diskLatencyQuantileName, diskLatency(metrics, backendCommitMetric, backendCommitThreshold))
+	return w.Flush()
+}
+
+// health returns etcd's own assessment of its health.
+func (o *EtcdOptions) health(ctx context.Context) string {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, o.MetricsEndpoint+"/health", nil)
+	if err != nil {
+		return "unknown: " + err.Error()
+	}
+	resp, err := o.HTTPClient.Do(req)
+	if err != nil {
+		return "unknown: " + err.Error()
+	}
+	defer resp.Body.Close()
+
+	health := struct {
+		Health string `json:"health"`
+		Reason string `json:"reason"`
+	}{}
+	if err := json.NewDecoder(resp.Body).Decode(&health); err != nil {
+		return "unknown: " + err.Error()
+	}
+	if health.Health != "true" {
+		return "unhealthy: " + health.Reason
+	}
+	return "healthy"
+}
+
+func (o *EtcdOptions) metrics(ctx context.Context) (map[string]*dto.MetricFamily, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, o.MetricsEndpoint+"/metrics", nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := o.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s", resp.Status)
+	}
+	parser := expfmt.TextParser{}
+	return parser.TextToMetricFamilies(resp.Body)
+}
+
+// diskLatency describes the quantile of the latency histogram, warning if it
+// exceeds threshold.
+func diskLatency(metrics map[string]*dto.MetricFamily, name string, threshold time.Duration) string {
+	family, ok := metrics[name]
+	if !ok || len(family.Metric) == 0 || family.Metric[0].Histogram == nil {
+		return "unavailable"
+	}
+	latency, ok := histogramQuantile(family.Metric[0].Histogram, diskLatencyQuantile)
+	if !ok {
+		return "no samples"
+	}
+	if latency > threshold {
+		return fmt.Sprintf("<= %s, slower than the recommended %s", latency, threshold)
+	}
+	return fmt.Sprintf("<= %s", latency)
+}
+
+// histogramQuantile returns the upper bound of the bucket the quantile q of
+// the histogram falls in, false if the histogram has no samples.
+func histogramQuantile(h *dto.Histogram, q float64) (time.Duration, bool) {
+	count := h.GetSampleCount()
+	if count == 0 {
+		return 0, false
+	}
+	rank := q * float64(count)
+	for _, bucket := range h.Bucket {
+		if float64(bucket.GetCumulativeCount()) >= rank {
+			return time.Duration(bucket.GetUpperBound() * float64(time.Second)), true
+		}
+	}
+	// the quantile falls in the +Inf bucket, the largest bound is all that
+	// is known about it
+	if len(h.Bucket) == 0 {
+		return 0, false
+	}
+	return time.Duration(h.Bucket[len(h.Bucket)-1].GetUpperBound() * float64(time.Second)), true
+}
+
+func NewEtcdDefragCommand(ioStreams genericclioptions.IOStreams) *cobra.Command {
+	o := newEtcdOptions(ioStreams)
+	return newEtcdCommand(&o, &cobra.Command{
+		Use:   "defrag",
+		Short: "Defragment etcd's database",
+		Long: `Defragment etcd's database.
+
+Releases the space freed by compactions to the file system. etcd does not
+serve requests while it is defragmenting, which may take a while for large
+databases. MicroShift defragments the database automatically as configured in
+etcd.defragmentation.`,
+	}, o.RunDefrag)
+}
+
+func (o *EtcdOptions) RunDefrag() error {
+	ctx, cancel := context.WithTimeout(context.Background(), etcdMaintenanceTimeout)
+	defer cancel()
+	before, err := o.Client.Status(ctx, o.Endpoint)
+	if err != nil {
+		return fmt.Errorf("failed to get the status of etcd, is MicroShift running? %w", err)
+	}
+	if _, err := o.Client.Defragment(ctx, o.Endpoint); err != nil {
+		return fmt.Errorf("failed to defragment etcd: %w", err)
+	}
+	after, err := o.Client.Status(ctx, o.Endpoint)
+	if err != nil {
+		return fmt.Errorf("failed to get the status of etcd: %w", err)
+	}
+	fmt.Fprintf(o.Out, "defragmented etcd's database from %s to %s\n",
+		resource.NewQuantity(before.DbSize, resource.BinarySI), resource.NewQuantity(after.DbSize, resource.BinarySI))
+	return nil
+}
+
+type EtcdCompactOptions struct {
+	Revision int64
+
+	EtcdOptions
+}
+
+func NewEtcdCompactCommand(ioStreams genericclioptions.IOStreams) *cobra.Command {
+	o := &EtcdCompactOptions{EtcdOptions: newEtcdOptions(ioStreams)}
+	cmd := newEtcdCommand(&o.EtcdOptions, &cobra.Command{
+		Use:   "compact",
+		Short: "Compact etcd's history",
+		Long: `Compact etcd's history.
+
+Discards all revisions before the given one, by default all but the current
+one. The space they occupied is reused by etcd, run "microshift etcd defrag"
+to release it to the file system. etcd compacts its history automatically as
+configured in etcd.autoCompactionMode and etcd.autoCompactionRetention.`,
+		Example: `  # Discard all revisions but the current one
+  microshift etcd compact
+
+  # Discard the revisions before 12345
+  microshift etcd compact --revision 12345`,
+	}, o.Run)
+	cmd.Flags().Int64Var(&o.Revision, "revision", o.Revision, "Revision to compact the history to. Defaults to the current revision.")
+	return cmd
+}
+
+func (o *EtcdCompactOptions) Run() error {
+	ctx, cancel := context.WithTimeout(context.Background(), etcdMaintenanceTimeout)
+	defer cancel()
+	revision := o.Revision
+	if revision <= 0 {
+		status, err := o.Client.Status(ctx, o.Endpoint)
+		if err != nil {
+			return fmt.Errorf("failed to get the status of etcd, is MicroShift running? %w", err)
+		}
+		revision = status.Header.Revision
+	}
+	if _, err := o.Client.Compact(ctx, revision, clientv3.WithCompactPhysical()); err != nil {
+		return fmt.Errorf("failed to compact etcd to revision %d: %w", revision, err)
+	}
+	fmt.Fprintf(o.Out, "compacted etcd's history to revision %d\n", revision)
+	return nil
+}
+
+func NewEtcdAlarmCommand(ioStreams genericclioptions.IOStreams) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "alarm",
+		Short: "List and disarm the alarms of etcd",
+		Long: `List and disarm the alarms of etcd.
+
+etcd raises the NOSPACE alarm when its database exceeds etcd.quotaBackendSize
+and then only accepts reads and deletes, and the CORRUPT alarm when it detected
+a corruption of its data.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			cmd.Help()
+		},
+	}
+
+	listOptions := newEtcdOptions(ioStreams)
+	cmd.AddCommand(newEtcdCommand(&listOptions, &cobra.Command{
+		Use:   "list",
+		Short: "List the alarms of etcd",
+	}, listOptions.RunAlarmList))
+	disarmOptions := newEtcdOptions(ioStreams)
+	cmd.AddCommand(newEtcdCommand(&disarmOptions, &cobra.Command{
+		Use:   "disarm",
+		Short: "Disarm the alarms of etcd",
+		Long: `Disarm the alarms of etcd.
+
+etcd raises the NOSPACE alarm again if its database still exceeds its quota,
+compact and defragment it first. MicroShift disarms the NOSPACE alarm
+automatically once the database fits in its quota.`,
+	}, disarmOptions.RunAlarmDisarm))
+	return cmd
+}
+
+func (o *EtcdOptions) RunAlarmList() error {
+	ctx, cancel := context.WithTimeout(context.Background(), etcdCommandTimeout)
+	defer cancel()
+	resp, err := o.Client.AlarmList(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list the alarms of etcd, is MicroShift running? %w", err)
+	}
+	if len(resp.Alarms) == 0 {
+		fmt.Fprintln(o.ErrOut, "no alarms raised")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(o.Out, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "MEMBER\tALARM")
+	for _, alarm := range resp.Alarms {
+		fmt.Fprintf(w, "%x\t%s\n", alarm.MemberID, alarm.Alarm)
+	}
+	return w.Flush()
+}
+
+func (o *EtcdOptions) RunAlarmDisarm() error {
+	ctx, cancel := context.WithTimeout(context.Background(), etcdCommandTimeout)
+	defer cancel()
+	// an empty member disarms all alarms
+	resp, err := o.Client.AlarmDisarm(ctx, &clientv3.AlarmMember{})
+	if err != nil {
+		return fmt.Errorf("failed to disarm the alarms of etcd, is MicroShift running? %w", err)
+	}
+	if len(resp.Alarms) == 0 {
+		fmt.Fprintln(o.ErrOut, "no alarms raised")
+		return nil
+	}
+	for _, alarm := range resp.Alarms {
+		fmt.Fprintf(o.Out, "disarmed %s of member %x\n", alarm.Alarm, alarm.MemberID)
+	}
+	return nil
+}
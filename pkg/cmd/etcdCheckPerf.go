@@ -0,0 +1,248 @@
+package cmd
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"golang.org/x/time/rate"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+)
+
+// etcdPerfLoad is a write load check-perf puts on etcd, the loads are those
+// of `etcdctl check perf`.
+type etcdPerfLoad struct {
+	// Rate is the number of writes per second, spread over Clients
+	// concurrent writers.
+	Rate    int
+	Clients int
+}
+
+var etcdPerfLoads = map[string]etcdPerfLoad{
+	"s": {Rate: 150, Clients: 50},
+	"m": {Rate: 1000, Clients: 200},
+	"l": {Rate: 8000, Clients: 500},
+}
+
+const (
+	// etcdPerfPrefix is the prefix of the keys check-perf writes.
+	etcdPerfPrefix    = "/microshift-check-perf/"
+	etcdPerfValueSize = 256
+
+	// A load passes if at least etcdPerfMinThroughput percent of its rate
+	// were written without errors, none of the writes took longer than
+	// etcdPerfMaxLatency and the latencies' standard deviation is at most
+	// etcdPerfMaxStdDev.
+	etcdPerfMinThroughput = 90
+	etcdPerfMaxLatency    = 500 * time.Millisecond
+	etcdPerfMaxStdDev     = 100 * time.Millisecond
+)
+
+type EtcdCheckPerfOptions struct {
+	Load     string
+	Duration time.Duration
+
+	EtcdOptions
+}
+
+func NewEtcdCheckPerfCommand(ioStreams genericclioptions.IOStreams) *cobra.Command {
+	o := &EtcdCheckPerfOptions{Load: "s", Duration: time.Minute, EtcdOptions: newEtcdOptions(ioStreams)}
+	cmd := newEtcdCommand(&o.EtcdOptions, &cobra.Command{
+		Use:   "check-perf",
+		Short: "Check whether etcd keeps up with a write load",
+		Long: `Check whether etcd keeps up with a write load.
+
+Writes keys under /microshift-check-perf/ at the rate of the load for the given
+duration and checks the throughput and latencies of the writes. The small load
+writes 150 keys per second with 50 clients, the medium load 1000 keys with 200
+clients and the large load 8000 keys with 500 clients. The checks pass when at
+least 90% of the rate was written, no write took longer than 500ms and the
+standard deviation of the latencies is at most 100ms.
+
+The load slows down the running MicroShift while the check runs. The keys are
+deleted afterwards, the space they used is reclaimed by the next compaction
+and defragmentation.`,
+		Example: `  # Check etcd with the small load for a minute
+  microshift etcd check-perf
+
+  # Check etcd with the medium load for 5 minutes
+  microshift etcd check-perf --load m --duration 5m`,
+	}, o.Run)
+	cmd.Flags().StringVar(&o.Load, "load", o.Load, "Write load, one of s, m or l.")
+	cmd.Flags().DurationVar(&o.Duration, "duration", o.Duration, "Time to write the load for.")
+	return cmd
+}
+
+func (o *EtcdCheckPerfOptions) Validate() error {
+	if _, ok := etcdPerfLoads[o.Load]; !ok {
+		names := []string{}
+		for name := range etcdPerfLoads {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return fmt.Errorf("unknown load %q, must be one of %s", o.Load, strings.Join(names, ", "))
+	}
+	if o.Duration <= 0 {
+		return fmt.Errorf("duration must be positive")
+	}
+	return nil
+}
+
+func (o *EtcdCheckPerfOptions) Run() error {
+	if err := o.Validate(); err != nil {
+		return err
+	}
+	load := etcdPerfLoads[o.Load]
+
+	ctx, cancel := context.WithTimeout(context.Background(), etcdCommandTimeout)
+	defer cancel()
+	resp, err := o.Client.Get(ctx, etcdPerfPrefix, clientv3.WithPrefix(), clientv3.WithCountOnly())
+	if err != nil {
+		return fmt.Errorf("failed to read etcd, is MicroShift running? %w", err)
+	}
+	if resp.Count > 0 {
+		return fmt.Errorf("%d keys under %s exist already, a check is running or was interrupted", resp.Count, etcdPerfPrefix)
+	}
+
+	fmt.Fprintf(o.Out, "writing %d keys per second with %d clients for %s\n", load.Rate, load.Clients, o.Duration)
+	result := checkEtcdPerf(context.Background(), o.Client, load, o.Duration)
+
+	ctx, cancel = context.WithTimeout(context.Background(), etcdMaintenanceTimeout)
+	defer cancel()
+	if _, err := o.Client.Delete(ctx, etcdPerfPrefix, clientv3.WithPrefix()); err != nil {
+		fmt.Fprintf(o.ErrOut, "failed to delete the keys under %s: %v\n", etcdPerfPrefix, err)
+	}
+
+	passed := true
+	for _, check := range result.checks(load) {
+		if check.passed {
+			fmt.Fprintf(o.Out, "PASS: %s\n", check.message)
+		} else {
+			fmt.Fprintf(o.Out, "FAIL: %s\n", check.message)
+			passed = false
+		}
+	}
+	if !passed {
+		return fmt.Errorf("etcd does not keep up with the %s load", o.Load)
+	}
+	return nil
+}
+
+// etcdPerfResult holds the latencies of the successful writes of a load
+// written for Duration.
+type etcdPerfResult struct {
+	Latencies []time.Duration
+	Errors    int
+	Duration  time.Duration
+}
+
+// checkEtcdPerf writes the load to etcd for duration.
+func checkEtcdPerf(ctx context.Context, client *clientv3.Client, load etcdPerfLoad, duration time.Duration) etcdPerfResult {
+	value := make([]byte, etcdPerfValueSize)
+	_, _ = rand.Read(value)
+	limiter := rate.NewLimiter(rate.Limit(load.Rate), 1)
+
+	ctx, cancel := context.WithTimeout(ctx, duration)
+	defer cancel()
+	result := etcdPerfResult{Duration: duration}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for i := 0; i < load.Clients; i++ {
+		wg.Add(1)
+		go func(writer int) {
+			defer wg.Done()
+			for n := 0; ; n++ {
+				if err := limiter.Wait(ctx); err != nil {
+					return
+				}
+				begin := time.Now()
+				_, err := client.Put(ctx, fmt.Sprintf("%s%d/%d", etcdPerfPrefix, writer, n), string(value))
+				latency := time.Since(begin)
+				if ctx.Err() != nil {
+					// writes canceled at the end of the load don't count
+					return
+				}
+				mu.Lock()
+				if err != nil {
+					result.Errors++
+				} else {
+					result.Latencies = append(result.Latencies, latency)
+				}
+				mu.Unlock()
+			}
+		}(i)
+	}
+	wg.Wait()
+	return result
+}
+
+type etcdPerfCheck struct {
+	passed  bool
+	message string
+}
+
+// checks evaluates the result against the criteria of the load.
+func (r etcdPerfResult) checks(load etcdPerfLoad) []etcdPerfCheck {
+	checks := []etcdPerfCheck{}
+
+	throughput := 0
+	if r.Duration > 0 {
+		throughput = int(float64(len(r.Latencies)) / r.Duration.Seconds())
+	}
+	minThroughput := load.Rate * etcdPerfMinThroughput / 100
+	if throughput >= minThroughput {
+		checks = append(checks, etcdPerfCheck{true, fmt.Sprintf("Throughput is %d writes/s", throughput)})
+	} else {
+		checks = append(checks, etcdPerfCheck{false, fmt.Sprintf("Throughput is %d writes/s, expected at least %d writes/s", throughput, minThroughput)})
+	}
+
+	if r.Errors == 0 {
+		checks = append(checks, etcdPerfCheck{true, "All writes succeeded"})
+	} else {
+		checks = append(checks, etcdPerfCheck{false, fmt.Sprintf("%d writes failed", r.Errors)})
+	}
+
+	if len(r.Latencies) == 0 {
+		return append(checks, etcdPerfCheck{false, "No write succeeded"})
+	}
+	slowest := time.Duration(0)
+	for _, latency := range r.Latencies {
+		if latency > slowest {
+			slowest = latency
+		}
+	}
+	if slowest <= etcdPerfMaxLatency {
+		checks = append(checks, etcdPerfCheck{true, fmt.Sprintf("Slowest write took %s", slowest)})
+	} else {
+		checks = append(checks, etcdPerfCheck{false, fmt.Sprintf("Slowest write took %s, expected at most %s", slowest, etcdPerfMaxLatency)})
+	}
+
+	stdDev := stdDeviation(r.Latencies)
+	if stdDev <= etcdPerfMaxStdDev {
+		checks = append(checks, etcdPerfCheck{true, fmt.Sprintf("Standard deviation of the latencies is %s", stdDev)})
+	} else {
+		checks = append(checks, etcdPerfCheck{false, fmt.Sprintf("Standard deviation of the latencies is %s, expected at most %s", stdDev, etcdPerfMaxStdDev)})
+	}
+	return checks
+}
+
+func stdDeviation(durations []time.Duration) time.Duration {
+	mean := 0.0
+	for _, d := range durations {
+		mean += float64(d)
+	}
+	mean /= float64(len(durations))
+	variance := 0.0
+	for _, d := range durations {
+		variance += (float64(d) - mean) * (float64(d) - mean)
+	}
+	variance /= float64(len(durations))
+	return time.Duration(math.Sqrt(variance))
+}
@@ -0,0 +1,216 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.etcd.io/etcd/api/v3/v3rpc/rpctypes"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.etcd.io/etcd/server/v3/embed"
+	"go.uber.org/zap"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/utils/pointer"
+
+	"github.com/openshift/microshift/pkg/config"
+)
+
+func freeURL(t *testing.T) *url.URL {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer l.Close()
+	u, err := url.Parse("http://" + l.Addr().String())
+	require.NoError(t, err)
+	return u
+}
+
+// newTestEtcdOptions starts an etcd with the quota and returns the options
+// of the etcd commands connected to it, and the buffers of their output and
+// errors.
+func newTestEtcdOptions(t *testing.T, quota int64) (*EtcdOptions, *bytes.Buffer, *bytes.Buffer) {
+	t.Helper()
+	var clientURL, metricsURL *url.URL
+	var e *embed.Etcd
+	var err error
+	// another process may take one of the free ports before etcd listens on
+	// it, start etcd on other ports then
+	for attempt := 0; attempt < 3; attempt++ {
+		var peerURL *url.URL
+		clientURL, peerURL, metricsURL = freeURL(t), freeURL(t), freeURL(t)
+		cfg := embed.NewConfig()
+		cfg.Name = "test"
+		cfg.Dir = t.TempDir()
+		cfg.Logger = "zap"
+		cfg.LogLevel = "error"
+		cfg.LCUrls, cfg.ACUrls = []url.URL{*clientURL}, []url.URL{*clientURL}
+		cfg.LPUrls, cfg.APUrls = []url.URL{*peerURL}, []url.URL{*peerURL}
+		cfg.ListenMetricsUrls = []url.URL{*metricsURL}
+		cfg.InitialCluster = "test=" + peerURL.String()
+		cfg.QuotaBackendBytes = quota
+		if e, err = embed.StartEtcd(cfg); !errors.Is(err, syscall.EADDRINUSE) {
+			break
+		}
+	}
+	require.NoError(t, err)
+	var once sync.Once
+	t.Cleanup(func() { once.Do(e.Close) })
+	select {
+	case <-e.Server.ReadyNotify():
+	case <-time.After(time.Minute):
+		t.Fatal("etcd did not become ready")
+	}
+
+	client, err := clientv3.New(clientv3.Config{
+		Endpoints:   []string{clientURL.String()},
+		DialTimeout: 10 * time.Second,
+		Logger:      zap.NewNop(),
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { client.Close() })
+
+	ioStreams, _, out, errOut := genericclioptions.NewTestIOStreams()
+	o := newEtcdOptions(ioStreams)
+	o.Config = config.NewMicroshiftConfig()
+	o.Config.Etcd.QuotaBackendSize = "2Gi"
+	o.Client = client
+	o.Endpoint = clientURL.String()
+	o.HTTPClient = http.DefaultClient
+	o.MetricsEndpoint = metricsURL.String()
+	return &o, out, errOut
+}
+
+func TestEtcdStatus(t *testing.T) {
+	o, out, _ := newTestEtcdOptions(t, 0)
+	require.NoError(t, o.RunStatus())
+
+	status := out.String()
+	assert.Regexp(t, `Member:\s+test \([0-9a-f]+\)`, status)
+	assert.Regexp(t, `Health:\s+healthy\n`, status)
+	assert.Regexp(t, `Leader:\s+true\n`, status)
+	assert.Regexp(t, `Database size:\s+\S+ of 2Gi quota \(0%\)\n`, status)
+	assert.Regexp(t, `Alarms:\s+none\n`, status)
+	assert.Regexp(t, `WAL fsync p99:\s+(<= \S+|no samples)`, status)
+	assert.Regexp(t, `Backend commit p99:\s+(<= \S+|no samples)`, status)
+}
+
+func TestEtcdCompactAndDefrag(t *testing.T) {
+	o, out, _ := newTestEtcdOptions(t, 0)
+	ctx := context.Background()
+	for i := 0; i < 10; i++ {
+		_, err := o.Client.Put(ctx, "key", strings.Repeat("x", 1024))
+		require.NoError(t, err)
+	}
+	status, err := o.Client.Status(ctx, o.Endpoint)
+	require.NoError(t, err)
+
+	compact := &EtcdCompactOptions{EtcdOptions: *o}
+	require.NoError(t, compact.Run())
+	assert.Contains(t, out.String(), "compacted etcd's history to revision 11\n")
+	_, err = o.Client.Get(ctx, "key", clientv3.WithRev(status.Header.Revision-1))
+	assert.ErrorIs(t, err, rpctypes.ErrCompacted)
+
+	require.NoError(t, o.RunDefrag())
+	assert.Contains(t, out.String(), "defragmented etcd's database from ")
+}
+
+func TestEtcdAlarm(t *testing.T) {
+	o, out, errOut := newTestEtcdOptions(t, 256*1024)
+	require.NoError(t, o.RunAlarmList())
+	assert.Equal(t, "no alarms raised\n", errOut.String())
+
+	// exceed the quota to raise the NOSPACE alarm, the size of the database
+	// is updated when etcd commits its writes periodically
+	ctx := context.Background()
+	deadline := time.Now().Add(30 * time.Second)
+	for i := 0; ; i++ {
+		_, err := o.Client.Put(ctx, fmt.Sprintf("key-%d", i), strings.Repeat("x", 64*1024))
+		if errors.Is(err, rpctypes.ErrNoSpace) {
+			break
+		}
+		require.NoError(t, err)
+		require.True(t, time.Now().Before(deadline), "etcd did not raise the NOSPACE alarm")
+	}
+
+	require.NoError(t, o.RunAlarmList())
+	assert.Regexp(t, `MEMBER\s+ALARM\n[0-9a-f]+\s+NOSPACE\n`, out.String())
+	require.NoError(t, o.RunStatus())
+	assert.Regexp(t, `Health:\s+unhealthy: .*NOSPACE`, out.String())
+	assert.Regexp(t, `Alarms:\s+NOSPACE\n`, out.String())
+
+	out.Reset()
+	errOut.Reset()
+	require.NoError(t, o.RunAlarmDisarm())
+	assert.Regexp(t, `^disarmed NOSPACE of member [0-9a-f]+\n$`, out.String())
+	require.NoError(t, o.RunAlarmList())
+	assert.Equal(t, "no alarms raised\n", errOut.String())
+}
+
+func TestCheckEtcdPerf(t *testing.T) {
+	o, _, _ := newTestEtcdOptions(t, 0)
+	result := checkEtcdPerf(context.Background(), o.Client, etcdPerfLoad{Rate: 20, Clients: 2}, time.Second)
+	assert.NotEmpty(t, result.Latencies)
+	assert.Zero(t, result.Errors)
+	assert.Equal(t, time.Second, result.Duration)
+}
+
+func TestEtcdPerfChecks(t *testing.T) {
+	load := etcdPerfLoad{Rate: 10, Clients: 2}
+	result := etcdPerfResult{Duration: time.Second}
+	for i := 0; i < 10; i++ {
+		result.Latencies = append(result.Latencies, 10*time.Millisecond)
+	}
+	assert.Equal(t, []etcdPerfCheck{
+		{true, "Throughput is 10 writes/s"},
+		{true, "All writes succeeded"},
+		{true, "Slowest write took 10ms"},
+		{true, "Standard deviation of the latencies is 0s"},
+	}, result.checks(load))
+
+	result = etcdPerfResult{
+		Duration:  time.Second,
+		Latencies: []time.Duration{0, 0, 0, 0, 0, 0, 0, time.Second},
+		Errors:    1,
+	}
+	assert.Equal(t, []etcdPerfCheck{
+		{false, "Throughput is 8 writes/s, expected at least 9 writes/s"},
+		{false, "1 writes failed"},
+		{false, "Slowest write took 1s, expected at most 500ms"},
+		{false, "Standard deviation of the latencies is 330.718913ms, expected at most 100ms"},
+	}, result.checks(load))
+}
+
+func TestHistogramQuantile(t *testing.T) {
+	bucket := func(upperBound float64, count uint64) *dto.Bucket {
+		return &dto.Bucket{UpperBound: pointer.Float64(upperBound), CumulativeCount: pointer.Uint64(count)}
+	}
+	h := &dto.Histogram{
+		SampleCount: pointer.Uint64(100),
+		Bucket:      []*dto.Bucket{bucket(0.001, 50), bucket(0.002, 98), bucket(0.004, 99), bucket(0.008, 99)},
+	}
+	latency, ok := histogramQuantile(h, 0.5)
+	assert.True(t, ok)
+	assert.Equal(t, time.Millisecond, latency)
+	latency, ok = histogramQuantile(h, 0.99)
+	assert.True(t, ok)
+	assert.Equal(t, 4*time.Millisecond, latency)
+	// the largest sample exceeds all bounds
+	latency, ok = histogramQuantile(h, 1)
+	assert.True(t, ok)
+	assert.Equal(t, 8*time.Millisecond, latency)
+
+	_, ok = histogramQuantile(&dto.Histogram{SampleCount: pointer.Uint64(0)}, 0.99)
+	assert.False(t, ok)
+}
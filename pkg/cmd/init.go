@@ -21,9 +21,12 @@ import (
 	"net"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
+	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/klog/v2"
 	ctrl "k8s.io/kubernetes/pkg/controlplane"
 
 	"github.com/openshift/microshift/pkg/config"
@@ -54,10 +57,96 @@ func initCerts(cfg *config.MicroshiftConfig) (*certchains.CertificateChains, err
 		}
 	}
 
-	return certChains, err
+	// library-go regenerates serving certificates that lack some of the
+	// requested names, but keeps the ones with names that were removed from
+	// apiServer.subjectAltNames
+	externalServingCert := []string{"kube-apiserver-external-signer", "kube-external-serving"}
+	changed, err := servingCertNamesChanged(certChains, externalServingHostnames(cfg), externalServingCert...)
+	if err != nil {
+		return nil, err
+	}
+	if changed {
+		klog.Infof("Subject alternative names of the API server changed, regenerating %s", strings.Join(externalServingCert, "/"))
+		if err := certChains.Regenerate(externalServingCert...); err != nil {
+			return nil, err
+		}
+	}
+
+	return certChains, nil
+}
+
+// externalServingHostnames returns the names the API server's serving
+// certificate for external clients is valid for.
+func externalServingHostnames(cfg *config.MicroshiftConfig) []string {
+	return append([]string{cfg.NodeName}, cfg.APIServer.SubjectAltNames...)
+}
+
+// servingCertNamesChanged returns whether the serving certificate at the path
+// is valid for other names than `hostnames`.
+func servingCertNamesChanged(cs *certchains.CertificateChains, hostnames []string, certPath ...string) (bool, error) {
+	normalize := func(name string) string {
+		if ip := net.ParseIP(name); ip != nil {
+			return ip.String()
+		}
+		return name
+	}
+
+	wanted := sets.NewString()
+	for _, name := range hostnames {
+		wanted.Insert(normalize(name))
+	}
+
+	changed := false
+	err := cs.WalkChains(certPath, func(_ []string, c x509.Certificate) error {
+		// library-go adds IP addresses to the DNS names as well
+		names := sets.NewString()
+		for _, name := range c.DNSNames {
+			names.Insert(normalize(name))
+		}
+		for _, ip := range c.IPAddresses {
+			names.Insert(ip.String())
+		}
+		changed = !names.Equal(wanted)
+		return nil
+	})
+	return changed, err
 }
 
 func certSetup(cfg *config.MicroshiftConfig) (*certchains.CertificateChains, error) {
+	builder, err := newCertChains(cfg)
+	if err != nil {
+		return nil, err
+	}
+	certChains, err := builder.Complete()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := util.EnsureKeys(filepath.Join(microshiftDataDir, "/resources/kube-apiserver/secrets/service-account-key"),
+		"service-account.crt", "service-account.key", cfg.Certificates.ServiceAccountKeyAlgorithm); err != nil {
+		return nil, err
+	}
+
+	cfg.Ingress.ServingCertificate, cfg.Ingress.ServingKey, err = certChains.GetCertKey("ingress-ca", "router-default-serving")
+	if err != nil {
+		return nil, err
+	}
+
+	return certChains, nil
+}
+
+// loadCerts loads the certificate chains of MicroShift without generating or
+// writing any certificates, so they can be inspected while MicroShift runs.
+func loadCerts(cfg *config.MicroshiftConfig) (*certchains.CertificateChains, error) {
+	builder, err := newCertChains(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return builder.Load()
+}
+
+// newCertChains returns the builder of MicroShift's certificate chains.
+func newCertChains(cfg *config.MicroshiftConfig) (certchains.CertificateChainsBuilder, error) {
 	_, svcNet, err := net.ParseCIDR(cfg.Cluster.ServiceCIDR)
 	if err != nil {
 		return nil, err
@@ -70,7 +159,12 @@ func certSetup(cfg *config.MicroshiftConfig) (*certchains.CertificateChains, err
 
 	certsDir := cryptomaterial.CertsDirectory(microshiftDataDir)
 
-	certChains, err := certchains.NewCertificateChains(
+	keyStore, err := newKeyStore(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return certchains.NewCertificateChains(
 		// ------------------------------
 		// CLIENT CERTIFICATE SIGNERS
 		// ------------------------------
@@ -111,7 +205,7 @@ func certSetup(cfg *config.MicroshiftConfig) (*certchains.CertificateChains, err
 			}),
 
 		// admin-kubeconfig-signer
-		certchains.NewCertificateSigner(
+		withCustomCA(cfg, certchains.NewCertificateSigner(
 			"admin-kubeconfig-signer",
 			cryptomaterial.AdminKubeconfigSignerDir(certsDir),
 			cryptomaterial.LongLivedCertificateValidityDays,
@@ -122,7 +216,15 @@ func certSetup(cfg *config.MicroshiftConfig) (*certchains.CertificateChains, err
 					ValidityDays: cryptomaterial.LongLivedCertificateValidityDays,
 				},
 				UserInfo: &user.DefaultInfo{Name: "system:admin", Groups: []string{"system:masters"}},
-			}),
+			})),
+
+		// user-client-signer signs the client certificates of the
+		// kubeconfigs created with `microshift kubeconfig create`
+		certchains.NewCertificateSigner(
+			"user-client-signer",
+			cryptomaterial.UserClientSignerDir(certsDir),
+			cryptomaterial.LongLivedCertificateValidityDays,
+		),
 
 		// kubelet + CSR signing chain
 		certchains.NewCertificateSigner(
@@ -170,11 +272,13 @@ func certSetup(cfg *config.MicroshiftConfig) (*certchains.CertificateChains, err
 		//------------------------------
 		// SERVING CERTIFICATE SIGNERS
 		//------------------------------
+		// the service-ca controller signs with library-go, which only
+		// supports RSA keys
 		certchains.NewCertificateSigner(
 			"service-ca",
 			cryptomaterial.ServiceCADir(certsDir),
 			cryptomaterial.LongLivedCertificateValidityDays,
-		).WithServingCertificates(
+		).WithKeyAlgorithm(cryptomaterial.RSA2048).WithServingCertificates(
 			&certchains.ServingCertificateSigningRequestInfo{
 				CSRMeta: certchains.CSRMeta{
 					Name:         "route-controller-manager-serving",
@@ -205,7 +309,7 @@ func certSetup(cfg *config.MicroshiftConfig) (*certchains.CertificateChains, err
 
 		// this signer replaces the loadbalancer signers of OCP, we don't need those
 		// in Microshift
-		certchains.NewCertificateSigner(
+		withCustomCA(cfg, certchains.NewCertificateSigner(
 			"kube-apiserver-external-signer",
 			cryptomaterial.KubeAPIServerExternalSigner(certsDir),
 			cryptomaterial.LongLivedCertificateValidityDays,
@@ -215,11 +319,9 @@ func certSetup(cfg *config.MicroshiftConfig) (*certchains.CertificateChains, err
 					Name:         "kube-external-serving",
 					ValidityDays: cryptomaterial.ShortLivedCertificateValidityDays,
 				},
-				Hostnames: []string{
-					cfg.NodeName,
-				},
+				Hostnames: externalServingHostnames(cfg),
 			},
-		),
+		)),
 
 		certchains.NewCertificateSigner(
 			"kube-apiserver-localhost-signer",
@@ -295,11 +397,28 @@ func certSetup(cfg *config.MicroshiftConfig) (*certchains.CertificateChains, err
 				Hostnames: []string{"localhost", "127.0.0.1", cfg.NodeIP, cfg.NodeName},
 			},
 		),
+	).WithKeyAlgorithm(
+		cfg.Certificates.KeyAlgorithm,
+	).WithKeyStore(
+		keyStore,
+		// the service-ca controller reads the key of its CA from a file
+		"kube-control-plane-signer",
+		"kube-apiserver-to-kubelet-signer",
+		"admin-kubeconfig-signer",
+		"user-client-signer",
+		"kubelet-signer",
+		"aggregator-signer",
+		"ingress-ca",
+		"kube-apiserver-external-signer",
+		"kube-apiserver-localhost-signer",
+		"kube-apiserver-service-network-signer",
+		"etcd-signer",
 	).WithCABundle(
 		cryptomaterial.TotalClientCABundlePath(certsDir),
 		[]string{"kube-control-plane-signer"},
 		[]string{"kube-apiserver-to-kubelet-signer"},
 		[]string{"admin-kubeconfig-signer"},
+		[]string{"user-client-signer"},
 		[]string{"kubelet-signer"},
 		[]string{"kubelet-signer", "kube-csr-signer"},
 	).WithCABundle(
@@ -314,23 +433,35 @@ func certSetup(cfg *config.MicroshiftConfig) (*certchains.CertificateChains, err
 		[]string{"kube-apiserver-external-signer"},
 		[]string{"kube-apiserver-localhost-signer"},
 		[]string{"kube-apiserver-service-network-signer"},
-	).Complete()
+	), nil
+}
 
-	if err != nil {
-		return nil, err
+// newKeyStore opens the PKCS#11 token the keys of the root CAs are kept on,
+// or returns nil if none is configured. The token stays open for the lifetime
+// of the process, as the signers sign with its keys.
+func newKeyStore(cfg *config.MicroshiftConfig) (cryptomaterial.KeyStore, error) {
+	pkcs11 := cfg.Certificates.PKCS11
+	if !pkcs11.IsEnabled() {
+		return nil, nil
 	}
 
-	if err := util.GenKeys(filepath.Join(microshiftDataDir, "/resources/kube-apiserver/secrets/service-account-key"),
-		"service-account.crt", "service-account.key"); err != nil {
-		return nil, err
+	pin, err := os.ReadFile(pkcs11.PINFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the PKCS#11 PIN: %v", err)
 	}
-
-	cfg.Ingress.ServingCertificate, cfg.Ingress.ServingKey, err = certChains.GetCertKey("ingress-ca", "router-default-serving")
+	keyStore, err := cryptomaterial.NewPKCS11KeyStore(pkcs11.ModulePath, pkcs11.TokenLabel, strings.TrimSpace(string(pin)))
 	if err != nil {
 		return nil, err
 	}
+	return keyStore, nil
+}
 
-	return certChains, nil
+// withCustomCA makes the signer use the custom CA configured for it, if any.
+func withCustomCA(cfg *config.MicroshiftConfig, signer certchains.CertificateSignerBuilder) certchains.CertificateSignerBuilder {
+	if customSigner, ok := cfg.Certificates.CustomSigners[signer.Name()]; ok {
+		return signer.WithCustomCA(customSigner.CertPath, customSigner.KeyPath)
+	}
+	return signer
 }
 
 func initKubeconfigs(
@@ -356,44 +487,23 @@ func initKubeconfigs(
 		return err
 	}
 
-	kcmCertPEM, kcmKeyPEM, err := certChains.GetCertKey("kube-control-plane-signer", "kube-controller-manager")
-	if err != nil {
-		return err
-	}
-	if err := util.KubeConfigWithClientCerts(
-		cfg.KubeConfigPath(config.KubeControllerManager),
-		cfg.Cluster.URL,
-		inClusterTrustBundlePEM,
-		kcmCertPEM,
-		kcmKeyPEM,
-	); err != nil {
-		return err
-	}
-
-	schedulerCertPEM, schedulerKeyPEM, err := certChains.GetCertKey("kube-control-plane-signer", "kube-scheduler")
-	if err != nil {
-		return err
-	}
-	if err := util.KubeConfigWithClientCerts(
-		cfg.KubeConfigPath(config.KubeScheduler),
-		cfg.Cluster.URL,
-		inClusterTrustBundlePEM,
-		schedulerCertPEM, schedulerKeyPEM,
-	); err != nil {
-		return err
-	}
-
-	kubeletCertPEM, kubeletKeyPEM, err := certChains.GetCertKey("kubelet-signer", "kube-csr-signer", "kubelet-client")
-	if err != nil {
-		return err
-	}
-	if err := util.KubeConfigWithClientCerts(
-		cfg.KubeConfigPath(config.Kubelet),
-		cfg.Cluster.URL,
-		inClusterTrustBundlePEM,
-		kubeletCertPEM, kubeletKeyPEM,
-	); err != nil {
-		return err
+	// the short-lived client certificates of the components are rotated in
+	// place, reference them so they are reloaded
+	certsDir := cryptomaterial.CertsDirectory(microshiftDataDir)
+	for id, certDir := range map[config.KubeConfigID]string{
+		config.KubeControllerManager: cryptomaterial.KubeControllerManagerClientCertDir(certsDir),
+		config.KubeScheduler:         cryptomaterial.KubeSchedulerClientCertDir(certsDir),
+		config.Kubelet:               cryptomaterial.KubeletClientCertDir(certsDir),
+	} {
+		if err := util.KubeConfigWithClientCertFiles(
+			cfg.KubeConfigPath(id),
+			cfg.Cluster.URL,
+			inClusterTrustBundlePEM,
+			cryptomaterial.ClientCertPath(certDir),
+			cryptomaterial.ClientKeyPath(certDir),
+		); err != nil {
+			return err
+		}
 	}
 	return nil
 }
@@ -403,6 +513,14 @@ func initKubeconfigs(
 func certsToRegenerate(cs *certchains.CertificateChains) ([][]string, error) {
 	regenCerts := [][]string{}
 	err := cs.WalkChains(nil, func(certPath []string, c x509.Certificate) error {
+		if cs.IsCustomSigner(certPath...) {
+			// custom CAs are replaced by the user, the certificates they
+			// signed are checked separately
+			if time.Now().After(c.NotAfter) {
+				klog.Warningf("Custom CA %s expired at %s, replace it", strings.Join(certPath, "/"), c.NotAfter)
+			}
+			return nil
+		}
 		if now := time.Now(); now.Before(c.NotBefore) || now.After(c.NotAfter) {
 			regenCerts = append(regenCerts, certPath)
 		}
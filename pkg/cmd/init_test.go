@@ -131,3 +131,26 @@ func mustComplete(t *testing.T, cs certchains.CertificateChainsBuilder) *certcha
 	require.NoError(t, err)
 	return ret
 }
+
+func Test_servingCertNamesChanged(t *testing.T) {
+	chains := mustComplete(t, certchains.NewCertificateChains(
+		certchains.NewCertificateSigner("signer", t.TempDir(), 365).
+			WithServingCertificates(&certchains.ServingCertificateSigningRequestInfo{
+				CSRMeta:   certchains.CSRMeta{Name: "server", ValidityDays: 30},
+				Hostnames: []string{"node1", "api.example.com", "2001:db8::1"},
+			}),
+	))
+
+	for _, tt := range []struct {
+		hostnames []string
+		want      bool
+	}{
+		{hostnames: []string{"api.example.com", "node1", "2001:DB8:0::1"}, want: false},
+		{hostnames: []string{"node1"}, want: true},
+		{hostnames: []string{"node1", "api.example.com", "2001:db8::1", "10.0.0.1"}, want: true},
+	} {
+		got, err := servingCertNamesChanged(chains, tt.hostnames, "signer", "server")
+		require.NoError(t, err)
+		require.Equal(t, tt.want, got, "hostnames %v", tt.hostnames)
+	}
+}
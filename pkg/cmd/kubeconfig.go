@@ -0,0 +1,232 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	cmdutil "k8s.io/kubectl/pkg/cmd/util"
+
+	"github.com/openshift/microshift/pkg/config"
+	"github.com/openshift/microshift/pkg/util"
+	"github.com/openshift/microshift/pkg/util/cryptomaterial"
+)
+
+func NewKubeconfigCommand(ioStreams genericclioptions.IOStreams) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "kubeconfig",
+		Short: "Manage kubeconfigs for accessing MicroShift",
+		Run: func(cmd *cobra.Command, args []string) {
+			cmd.Help()
+		},
+	}
+
+	cmd.AddCommand(NewKubeconfigCreateCommand(ioStreams))
+	return cmd
+}
+
+type KubeconfigCreateOptions struct {
+	User      string
+	Groups    []string
+	Validity  time.Duration
+	Servers   []string
+	Output    string
+	OutputDir string
+
+	// TrustBundlePath is the CA bundle clients verify the API server with
+	TrustBundlePath string
+
+	CertsOptions
+}
+
+func NewKubeconfigCreateCommand(ioStreams genericclioptions.IOStreams) *cobra.Command {
+	o := &KubeconfigCreateOptions{
+		Validity:     365 * 24 * time.Hour,
+		Output:       "-",
+		CertsOptions: CertsOptions{IOStreams: ioStreams},
+	}
+	cmd := &cobra.Command{
+		Use:   "create --user NAME",
+		Short: "Create a kubeconfig for a user",
+		Long: `Create a kubeconfig for a user.
+
+Signs a client certificate for the user and groups with the user-client-signer
+and writes a kubeconfig authenticating with it. The user has no permissions
+until they are granted by RBAC role bindings for the user or its groups. Users
+and groups with the "system:" prefix are reserved for MicroShift's components
+and cannot be used.
+
+The certificate cannot be revoked, it stays valid until it expires or the
+user-client-signer is rotated, which invalidates all kubeconfigs it signed.
+
+The kubeconfig is printed unless --output is given. With several --server URLs,
+one kubeconfig per server is written to <output-dir>/<host>/kubeconfig. The API
+server's certificate must be valid for the host of each server, see
+apiServer.subjectAltNames.`,
+		Example: `  # Create a kubeconfig for user alice in the developers group
+  microshift kubeconfig create --user alice --group developers -o alice.kubeconfig
+
+  # Create kubeconfigs for remote access by host name and IP address
+  microshift kubeconfig create --user alice --validity 720h --output-dir alice \
+    --server https://microshift.example.com:6443 --server https://192.168.1.10:6443`,
+		Run: func(cmd *cobra.Command, args []string) {
+			cmdutil.CheckErr(o.Validate())
+			cmdutil.CheckErr(o.Complete(cmd.Flags()))
+			cmdutil.CheckErr(o.Run())
+		},
+	}
+
+	cmd.Flags().StringVar(&o.User, "user", o.User, "Name of the user the certificate is issued for.")
+	cmd.Flags().StringSliceVar(&o.Groups, "group", o.Groups, "Group of the user, may be repeated.")
+	cmd.Flags().DurationVar(&o.Validity, "validity", o.Validity, "Time the client certificate is valid for.")
+	cmd.Flags().StringSliceVar(&o.Servers, "server", o.Servers, "URL of the API server, may be repeated. Defaults to the cluster URL.")
+	cmd.Flags().StringVarP(&o.Output, "output", "o", o.Output, "File to write the kubeconfig to, '-' for stdout.")
+	cmd.Flags().StringVar(&o.OutputDir, "output-dir", o.OutputDir, "Directory to write one kubeconfig per server to.")
+	addRunFlags(cmd, config.NewMicroshiftConfig())
+
+	return cmd
+}
+
+func (o *KubeconfigCreateOptions) Validate() error {
+	if o.User == "" {
+		return fmt.Errorf("--user is required")
+	}
+	if strings.HasPrefix(o.User, "system:") {
+		return fmt.Errorf("invalid user %q, the system: prefix is reserved", o.User)
+	}
+	for _, group := range o.Groups {
+		if strings.HasPrefix(group, "system:") {
+			return fmt.Errorf("invalid group %q, the system: prefix is reserved", group)
+		}
+	}
+	if o.Validity <= 0 {
+		return fmt.Errorf("--validity must be positive")
+	}
+	for _, server := range o.Servers {
+		if _, err := serverHost(server); err != nil {
+			return err
+		}
+	}
+	if len(o.OutputDir) == 0 && len(o.Servers) > 1 {
+		return fmt.Errorf("--output-dir is required for more than one --server")
+	}
+	if len(o.OutputDir) > 0 && o.Output != "-" {
+		return fmt.Errorf("--output and --output-dir are mutually exclusive")
+	}
+	return nil
+}
+
+func (o *KubeconfigCreateOptions) Complete(flags *pflag.FlagSet) error {
+	if err := o.loadConfig(flags); err != nil {
+		return err
+	}
+	// MicroShift creates the signer when it starts, the API server trusts
+	// it from then on
+	signerDir := cryptomaterial.UserClientSignerDir(cryptomaterial.CertsDirectory(microshiftDataDir))
+	if _, err := os.Stat(cryptomaterial.CACertPath(signerDir)); errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("no user-client-signer found in %s, restart MicroShift once to create it", signerDir)
+	} else if err != nil {
+		return err
+	}
+
+	var err error
+	o.CertChains, err = loadCerts(o.Config)
+	if err != nil {
+		return err
+	}
+	if len(o.Servers) == 0 {
+		o.Servers = []string{o.Config.Cluster.URL}
+	}
+	o.TrustBundlePath = cryptomaterial.ServiceAccountTokenCABundlePath(cryptomaterial.CertsDirectory(microshiftDataDir))
+	return nil
+}
+
+func (o *KubeconfigCreateOptions) Run() error {
+	trustBundlePEM, err := os.ReadFile(o.TrustBundlePath)
+	if err != nil {
+		return fmt.Errorf("failed to load the API server's CA bundle: %v", err)
+	}
+
+	signer := o.CertChains.GetSigner("user-client-signer")
+	if signer == nil {
+		return fmt.Errorf("no user-client-signer found")
+	}
+	certPEM, keyPEM, err := signer.MakeClientCertificate(&user.DefaultInfo{Name: o.User, Groups: o.Groups}, o.Validity)
+	if err != nil {
+		return err
+	}
+
+	for _, server := range o.Servers {
+		host, err := serverHost(server)
+		if err != nil {
+			return err
+		}
+		if !o.servedFor(host) {
+			fmt.Fprintf(o.ErrOut, "warning: the API server's certificate is not valid for %s, add it to apiServer.subjectAltNames\n", host)
+		}
+
+		kubeconfig, err := util.KubeConfigWithClientCertsData(server, trustBundlePEM, certPEM, keyPEM)
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case len(o.OutputDir) > 0:
+			path := filepath.Join(o.OutputDir, host, "kubeconfig")
+			if err := writeKubeconfigFile(path, kubeconfig); err != nil {
+				return err
+			}
+			fmt.Fprintf(o.ErrOut, "kubeconfig for %s written to %s\n", server, path)
+		case o.Output == "-":
+			fmt.Fprint(o.Out, string(kubeconfig))
+		default:
+			if err := writeKubeconfigFile(o.Output, kubeconfig); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// servedFor returns whether MicroShift's serving certificates are valid for
+// the host. Named certificates configured by the user are not inspected, the
+// host is assumed to be valid for them.
+func (o *KubeconfigCreateOptions) servedFor(host string) bool {
+	if len(o.Config.APIServer.NamedCertificates) > 0 {
+		return true
+	}
+	hostnames := append(externalServingHostnames(o.Config), "localhost", "127.0.0.1", o.Config.NodeIP)
+	return config.StringInList(host, hostnames)
+}
+
+// serverHost returns the host of the API server URL, without brackets for
+// IPv6 addresses.
+func serverHost(server string) (string, error) {
+	u, err := url.Parse(server)
+	if err != nil {
+		return "", fmt.Errorf("invalid server URL %q: %v", server, err)
+	}
+	if u.Scheme != "https" || u.Hostname() == "" {
+		return "", fmt.Errorf("invalid server URL %q, must be https://<host>[:<port>]", server)
+	}
+	if ip := net.ParseIP(u.Hostname()); ip != nil {
+		return ip.String(), nil
+	}
+	return u.Hostname(), nil
+}
+
+func writeKubeconfigFile(path string, kubeconfig []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	return os.WriteFile(path, kubeconfig, 0600)
+}
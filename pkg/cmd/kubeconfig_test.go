@@ -0,0 +1,110 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/require"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/client-go/tools/clientcmd"
+
+	"github.com/openshift/library-go/pkg/crypto"
+	"github.com/openshift/microshift/pkg/config"
+	"github.com/openshift/microshift/pkg/util/cryptomaterial"
+	"github.com/openshift/microshift/pkg/util/cryptomaterial/certchains"
+)
+
+func TestKubeconfigCreateValidate(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		o       KubeconfigCreateOptions
+		wantErr string
+	}{
+		{name: "valid", o: KubeconfigCreateOptions{User: "alice", Groups: []string{"dev"}, Validity: time.Hour, Output: "-"}},
+		{name: "no user", o: KubeconfigCreateOptions{Validity: time.Hour, Output: "-"}, wantErr: "--user is required"},
+		{name: "system user", o: KubeconfigCreateOptions{User: "system:admin", Validity: time.Hour, Output: "-"}, wantErr: `invalid user "system:admin", the system: prefix is reserved`},
+		{name: "system group", o: KubeconfigCreateOptions{User: "alice", Groups: []string{"system:masters"}, Validity: time.Hour, Output: "-"}, wantErr: `invalid group "system:masters", the system: prefix is reserved`},
+		{name: "http server", o: KubeconfigCreateOptions{User: "alice", Validity: time.Hour, Servers: []string{"http://node1:6443"}, Output: "-"}, wantErr: `invalid server URL "http://node1:6443", must be https://<host>[:<port>]`},
+		{name: "servers without dir", o: KubeconfigCreateOptions{User: "alice", Validity: time.Hour, Servers: []string{"https://a:6443", "https://b:6443"}, Output: "-"}, wantErr: "--output-dir is required for more than one --server"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.o.Validate()
+			if tt.wantErr == "" {
+				require.NoError(t, err)
+			} else {
+				require.EqualError(t, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestKubeconfigCreate(t *testing.T) {
+	tmpDir := t.TempDir()
+	chains := mustComplete(t, certchains.NewCertificateChains(
+		certchains.NewCertificateSigner("user-client-signer", filepath.Join(tmpDir, "signer"), 365),
+	))
+	trustBundlePath := filepath.Join(tmpDir, "ca-bundle.crt")
+	require.NoError(t, os.WriteFile(trustBundlePath, []byte("trust bundle"), 0600))
+
+	cfg := config.NewMicroshiftConfig()
+	cfg.NodeName = "node1"
+	cfg.APIServer.SubjectAltNames = []string{"2001:db8::1"}
+	ioStreams, _, _, errOut := genericclioptions.NewTestIOStreams()
+	o := &KubeconfigCreateOptions{
+		User:            "alice",
+		Groups:          []string{"dev", "ops"},
+		Validity:        24 * time.Hour,
+		Servers:         []string{"https://node1:6443", "https://[2001:db8::1]:6443", "https://other.example.com:6443"},
+		Output:          "-",
+		OutputDir:       filepath.Join(tmpDir, "out"),
+		TrustBundlePath: trustBundlePath,
+		CertsOptions:    CertsOptions{Config: cfg, CertChains: chains, IOStreams: ioStreams},
+	}
+	require.NoError(t, o.Validate())
+	require.NoError(t, o.Run())
+	require.Contains(t, errOut.String(), "warning: the API server's certificate is not valid for other.example.com")
+	require.NotContains(t, errOut.String(), "not valid for node1")
+
+	signerCertPEM, err := chains.GetSigner("user-client-signer").GetSignerCertPEM()
+	require.NoError(t, err)
+	signerCerts, err := crypto.CertsFromPEM(signerCertPEM)
+	require.NoError(t, err)
+
+	for host, server := range map[string]string{
+		"node1":             "https://node1:6443",
+		"2001:db8::1":       "https://[2001:db8::1]:6443",
+		"other.example.com": "https://other.example.com:6443",
+	} {
+		kubeconfig, err := clientcmd.LoadFromFile(filepath.Join(o.OutputDir, host, "kubeconfig"))
+		require.NoError(t, err)
+		require.Equal(t, server, kubeconfig.Clusters["microshift"].Server)
+		require.Equal(t, []byte("trust bundle"), kubeconfig.Clusters["microshift"].CertificateAuthorityData)
+
+		certs, err := crypto.CertsFromPEM(kubeconfig.AuthInfos["user"].ClientCertificateData)
+		require.NoError(t, err)
+		require.Equal(t, "alice", certs[0].Subject.CommonName)
+		require.ElementsMatch(t, []string{"dev", "ops"}, certs[0].Subject.Organization)
+		require.NoError(t, certs[0].CheckSignatureFrom(signerCerts[0]))
+		require.WithinDuration(t, time.Now().Add(24*time.Hour), certs[0].NotAfter, time.Minute)
+	}
+
+	// the certificate must not outlive its signer
+	o.Validity = 2 * 365 * 24 * time.Hour
+	err = o.Run()
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "the certificate would expire after its signer")
+}
+
+func TestKubeconfigCreateWithoutSigner(t *testing.T) {
+	defer func(dir string) { microshiftDataDir = dir }(microshiftDataDir)
+	microshiftDataDir = t.TempDir()
+	require.NoError(t, os.MkdirAll(cryptomaterial.CertsDirectory(microshiftDataDir), 0700))
+
+	o := &KubeconfigCreateOptions{User: "alice"}
+	err := o.Complete(pflag.NewFlagSet("kubeconfig", pflag.ContinueOnError))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "restart MicroShift once to create it")
+}
@@ -2,13 +2,17 @@ package cmd
 
 import (
 	"context"
+	"fmt"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"syscall"
 	"time"
 
 	"github.com/coreos/go-systemd/daemon"
+	"github.com/openshift/microshift/pkg/components"
 	"github.com/openshift/microshift/pkg/config"
+	"github.com/openshift/microshift/pkg/configwatch"
 	"github.com/openshift/microshift/pkg/controllers"
 	"github.com/openshift/microshift/pkg/kustomize"
 	"github.com/openshift/microshift/pkg/mdns"
@@ -24,7 +28,9 @@ import (
 )
 
 const (
-	gracefulShutdownTimeout = 60
+	// restartRequestFile records why MicroShift was last restarted on request,
+	// relative to the data dir.
+	restartRequestFile = "restart-request.json"
 )
 
 func addRunFlags(cmd *cobra.Command, cfg *config.MicroshiftConfig) {
@@ -55,8 +61,61 @@ func NewRunMicroshiftCommand() *cobra.Command {
 	return cmd
 }
 
+// newServiceManager registers MicroShift's services with a new
+// ServiceManager. Constructing the services must not have side effects, as
+// this is also used to inspect the services without running them: files and
+// other OS resources are set up by the services' Run.
+func newServiceManager(cfg *config.MicroshiftConfig, flags *pflag.FlagSet, certChains *certchains.CertificateChains) *servicemanager.ServiceManager {
+	m := servicemanager.NewServiceManager()
+	util.Must(m.AddService(controllers.NewEtcd(cfg)))
+	util.Must(m.AddService(sysconfwatch.NewSysConfWatchController(cfg, m)))
+	util.Must(m.AddService(controllers.NewKubeAPIServer(cfg)))
+	util.Must(m.AddService(controllers.NewKubeScheduler(cfg)))
+	util.Must(m.AddService(controllers.NewKubeControllerManager(cfg)))
+	util.Must(m.AddService(controllers.NewOpenShiftCRDManager(cfg)))
+	util.Must(m.AddService(controllers.NewRouteControllerManager(cfg)))
+	util.Must(m.AddService(controllers.NewClusterPolicyController(cfg)))
+	util.Must(m.AddService(controllers.NewOpenShiftDefaultSCCManager(cfg)))
+	util.Must(m.AddService(mdns.NewMicroShiftmDNSController(cfg)))
+	util.Must(m.AddService(controllers.NewInfrastructureServices(cfg)))
+	util.Must(m.AddService((controllers.NewVersionManager((cfg)))))
+	util.Must(m.AddService(kustomize.NewKustomizer(cfg)))
+	util.Must(m.AddService(node.NewKubeletServer(cfg)))
+	util.Must(m.AddService(configwatch.NewConfigWatchController(cfg, flags)))
+	util.Must(m.AddService(controllers.NewCertRotationController(cfg, certChains,
+		func() error { return initKubeconfigs(cfg, certChains) }, m)))
+	util.Must(m.AddService(controllers.NewEtcdDefragController(cfg)))
+	util.Must(m.AddService(controllers.NewEncryptionController(cfg)))
+	util.Must(m.AddService(controllers.NewBackupController(cfg)))
+
+	// embedded components are applied by the infrastructure services manager
+	for _, name := range cfg.Components.Disabled {
+		if !components.IsComponent(name) {
+			m.DisableServices(name)
+		}
+	}
+
+	startupTimeouts := map[string]time.Duration{}
+	for name, timeout := range cfg.Components.StartupTimeouts {
+		startupTimeouts[name] = timeout.Duration
+	}
+	m.SetStartupTimeouts(startupTimeouts)
+
+	stopTimeouts := map[string]time.Duration{}
+	for name, timeout := range cfg.Components.StopTimeouts {
+		stopTimeouts[name] = timeout.Duration
+	}
+	m.SetStopTimeouts(stopTimeouts)
+
+	return m
+}
+
 func RunMicroshift(cfg *config.MicroshiftConfig, flags *pflag.FlagSet) error {
-	if err := cfg.ReadAndValidate(config.GetConfigFile(), flags); err != nil {
+	configFiles, err := config.GetConfigFiles()
+	if err != nil {
+		klog.Fatalf("Failed to find config files: %v", err)
+	}
+	if err := cfg.ReadAndValidate(configFiles, flags); err != nil {
 		klog.Fatalf("Error in reading and validating flags: %v", err)
 	}
 
@@ -92,21 +151,13 @@ func RunMicroshift(cfg *config.MicroshiftConfig, flags *pflag.FlagSet) error {
 		klog.Fatalf("failed to create the necessary kubeconfigs for internal components: %v", err)
 	}
 
-	m := servicemanager.NewServiceManager()
-	util.Must(m.AddService(controllers.NewEtcd(cfg)))
-	util.Must(m.AddService(sysconfwatch.NewSysConfWatchController(cfg)))
-	util.Must(m.AddService(controllers.NewKubeAPIServer(cfg)))
-	util.Must(m.AddService(controllers.NewKubeScheduler(cfg)))
-	util.Must(m.AddService(controllers.NewKubeControllerManager(cfg)))
-	util.Must(m.AddService(controllers.NewOpenShiftCRDManager(cfg)))
-	util.Must(m.AddService(controllers.NewRouteControllerManager(cfg)))
-	util.Must(m.AddService(controllers.NewClusterPolicyController(cfg)))
-	util.Must(m.AddService(controllers.NewOpenShiftDefaultSCCManager(cfg)))
-	util.Must(m.AddService(mdns.NewMicroShiftmDNSController(cfg)))
-	util.Must(m.AddService(controllers.NewInfrastructureServices(cfg)))
-	util.Must(m.AddService((controllers.NewVersionManager((cfg)))))
-	util.Must(m.AddService(kustomize.NewKustomizer(cfg)))
-	util.Must(m.AddService(node.NewKubeletServer(cfg)))
+	m := newServiceManager(cfg, flags, certChains)
+	if err := m.Validate(); err != nil {
+		klog.Fatalf("invalid service configuration: %v", err)
+	}
+	if err := components.Validate(cfg); err != nil {
+		klog.Fatalf("invalid component configuration: %v", err)
+	}
 
 	// Storing and clearing the env, so other components don't send the READY=1 until MicroShift is fully ready
 	notifySocket := os.Getenv("NOTIFY_SOCKET")
@@ -114,14 +165,20 @@ func RunMicroshift(cfg *config.MicroshiftConfig, flags *pflag.FlagSet) error {
 
 	klog.Infof("Starting MicroShift")
 
-	_, rotationDate, err := certchains.WhenToRotateAtEarliest(certChains)
-	if err != nil {
-		klog.Fatalf("failed to determine when to rotate certificates: %v", err)
+	restartRequestPath := filepath.Join(microshiftDataDir, restartRequestFile)
+	if previous, err := servicemanager.ConsumeRestartRequest(restartRequestPath); err != nil {
+		klog.Warningf("Failed to read why MicroShift was restarted: %v", err)
+	} else if previous != nil {
+		klog.InfoS("MicroShift was restarted on request", "reason", previous.Reason, "message", previous.Message, "requested", previous.Time)
 	}
 
-	// TODO: figure out a way to tell the user why the service restarted
-	ctx, cancel := context.WithDeadline(context.Background(), rotationDate)
+	ctx, cancel := context.WithCancel(context.Background())
 	ready, stopped := make(chan struct{}), make(chan struct{})
+	go func() {
+		if err := m.ServeStatus(ctx, servicemanager.StatusSocketPath); err != nil {
+			klog.Warningf("Failed to serve service status: %v", err)
+		}
+	}()
 	go func() {
 		klog.Infof("Started %s", m.Name())
 		if err := m.Run(ctx, ready, stopped); err != nil {
@@ -134,7 +191,11 @@ func RunMicroshift(cfg *config.MicroshiftConfig, flags *pflag.FlagSet) error {
 
 	sigTerm := make(chan os.Signal, 1)
 	signal.Notify(sigTerm, os.Interrupt, syscall.SIGTERM)
+	// SIGHUP reloads the config once the config watch controller runs, it
+	// must not terminate MicroShift before that or if it is disabled
+	signal.Notify(make(chan os.Signal, 1), syscall.SIGHUP)
 
+	var restart *servicemanager.RestartRequest
 	select {
 	case <-ready:
 		klog.Infof("MicroShift is ready")
@@ -147,19 +208,38 @@ func RunMicroshift(cfg *config.MicroshiftConfig, flags *pflag.FlagSet) error {
 			klog.Info("service does not support sd_notify readiness messages")
 		}
 
-		<-sigTerm
+		select {
+		case <-sigTerm:
+		case request := <-m.RestartRequested():
+			restart = &request
+		}
 	case <-sigTerm:
+	case request := <-m.RestartRequested():
+		restart = &request
+	}
+
+	if restart != nil {
+		klog.InfoS("Restarting MicroShift. Stopping services", "reason", restart.Reason, "message", restart.Message)
+		if err := servicemanager.WriteRestartRequest(restartRequestPath, *restart); err != nil {
+			klog.Warningf("Failed to persist why MicroShift is restarted: %v", err)
+		}
+	} else {
+		klog.Infof("Interrupt received. Stopping services")
 	}
-	klog.Infof("Interrupt received. Stopping services")
 	cancel()
 
+	// services are stopped in reverse dependency order, each within its
+	// stop timeout
 	select {
 	case <-stopped:
 	case <-sigTerm:
 		klog.Infof("Another interrupt received. Force terminating services")
-	case <-time.After(time.Duration(gracefulShutdownTimeout) * time.Second):
-		klog.Infof("Timed out waiting for services to stop")
 	}
 	klog.Infof("MicroShift stopped")
+
+	if restart != nil {
+		// exit with an error so that systemd starts MicroShift again
+		return fmt.Errorf("restart requested: %s", restart.Message)
+	}
 	return nil
 }
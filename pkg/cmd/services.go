@@ -0,0 +1,106 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/openshift/microshift/pkg/config"
+	"github.com/openshift/microshift/pkg/servicemanager"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	cmdutil "k8s.io/kubectl/pkg/cmd/util"
+)
+
+func NewServicesCommand(ioStreams genericclioptions.IOStreams) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "services",
+		Short: "Inspect MicroShift's services",
+		Run: func(cmd *cobra.Command, args []string) {
+			cmd.Help()
+		},
+	}
+
+	cmd.AddCommand(NewServicesGraphCommand(ioStreams))
+	return cmd
+}
+
+type ServicesGraphOptions struct {
+	Output     string
+	SocketPath string
+
+	genericclioptions.IOStreams
+}
+
+func NewServicesGraphOptions(ioStreams genericclioptions.IOStreams) *ServicesGraphOptions {
+	return &ServicesGraphOptions{
+		Output:     "dot",
+		SocketPath: servicemanager.StatusSocketPath,
+		IOStreams:  ioStreams,
+	}
+}
+
+func NewServicesGraphCommand(ioStreams genericclioptions.IOStreams) *cobra.Command {
+	o := NewServicesGraphOptions(ioStreams)
+	cmd := &cobra.Command{
+		Use:   "graph",
+		Short: "Print the dependency graph of MicroShift's services",
+		Long: `Print the dependency graph of MicroShift's services.
+
+If MicroShift is running, the services are annotated with their current state.
+Otherwise the graph is built from the services MicroShift would run.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			cmdutil.CheckErr(o.Validate())
+			cmdutil.CheckErr(o.Run())
+		},
+	}
+
+	cmd.Flags().StringVarP(&o.Output, "output", "o", o.Output, "One of 'dot' or 'json'.")
+
+	return cmd
+}
+
+func (o *ServicesGraphOptions) Validate() error {
+	switch o.Output {
+	case "dot", "json":
+		return nil
+	default:
+		return fmt.Errorf("invalid output format %q, must be one of 'dot' or 'json'", o.Output)
+	}
+}
+
+func (o *ServicesGraphOptions) Run() error {
+	var graph *servicemanager.Graph
+	if statuses, err := servicemanager.GetStatus(o.SocketPath); err == nil {
+		graph = servicemanager.NewGraph(statuses, true)
+	} else {
+		fmt.Fprintf(o.ErrOut, "Could not query the running MicroShift instance, printing the graph without state: %v\n", err)
+		cfg := config.NewMicroshiftConfig()
+		configFiles, err := config.GetConfigFiles()
+		if err != nil {
+			return err
+		}
+		if err := cfg.ReadFromConfigFiles(configFiles); err != nil {
+			return err
+		}
+		graph = newServiceManager(cfg, pflag.NewFlagSet("run", pflag.ContinueOnError), nil).Graph()
+	}
+
+	switch o.Output {
+	case "dot":
+		return graph.WriteDOT(o.Out)
+	case "json":
+		marshalled, err := json.MarshalIndent(graph, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(o.Out, string(marshalled))
+	default:
+		// There is a bug in the program if we hit this case.
+		// However, we follow a policy of never panicking.
+		return fmt.Errorf("ServicesGraphOptions were not validated: --output=%q should have been rejected", o.Output)
+	}
+
+	return nil
+}
@@ -0,0 +1,35 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	"github.com/openshift/microshift/pkg/config"
+	"github.com/spf13/pflag"
+)
+
+const servicesGraphFile = "testdata/services.dot"
+
+// tests that the dependencies between MicroShift's services only change
+// deliberately. Regenerate the graph with `microshift services graph` on a
+// stopped instance after changing them.
+func TestServicesGraph(t *testing.T) {
+	m := newServiceManager(config.NewMicroshiftConfig(), pflag.NewFlagSet("run", pflag.ContinueOnError), nil)
+	if err := m.Validate(); err != nil {
+		t.Fatalf("invalid services: %v", err)
+	}
+
+	var got bytes.Buffer
+	if err := m.Graph().WriteDOT(&got); err != nil {
+		t.Fatalf("failed to write graph: %v", err)
+	}
+
+	want, err := os.ReadFile(servicesGraphFile)
+	if err != nil {
+		t.Fatalf("failed to read %s: %v", servicesGraphFile, err)
+	}
+	if !bytes.Equal(got.Bytes(), want) {
+		t.Errorf("the services graph does not match %s, got:\n%s", servicesGraphFile, got.String())
+	}
+}
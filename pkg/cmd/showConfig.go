@@ -12,7 +12,8 @@ import (
 )
 
 type showConfigOptions struct {
-	Mode string
+	Mode     string
+	Annotate bool
 	genericclioptions.IOStreams
 }
 
@@ -34,14 +35,22 @@ func NewShowConfigCommand(ioStreams genericclioptions.IOStreams) *cobra.Command
 				cfg.NodeName = ""
 			case "effective":
 				// Load the current configuration
-				if err := cfg.ReadAndValidate(config.GetConfigFile(), cmd.Flags()); err != nil {
+				configFiles, err := config.GetConfigFiles()
+				cmdutil.CheckErr(err)
+				if err := cfg.ReadAndValidate(configFiles, cmd.Flags()); err != nil {
 					cmdutil.CheckErr(err)
 				}
 			default:
 				cmdutil.CheckErr(fmt.Errorf("Unknown mode %q", opts.Mode))
 			}
 
-			marshalled, err := yaml.Marshal(cfg)
+			var marshalled []byte
+			var err error
+			if opts.Annotate {
+				marshalled, err = cfg.MarshalAnnotated()
+			} else {
+				marshalled, err = yaml.Marshal(cfg)
+			}
 			cmdutil.CheckErr(err)
 
 			fmt.Fprintf(ioStreams.Out, "%s\n", string(marshalled))
@@ -50,6 +59,7 @@ func NewShowConfigCommand(ioStreams genericclioptions.IOStreams) *cobra.Command
 
 	flags := cmd.Flags()
 	flags.StringVarP(&opts.Mode, "mode", "m", opts.Mode, "One of 'default' or 'effective'.")
+	flags.BoolVar(&opts.Annotate, "annotate", opts.Annotate, "Annotate each value with the config file, environment variable or command line it was set by.")
 	addRunFlags(cmd, cfg)
 
 	return cmd
@@ -0,0 +1,101 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/openshift/microshift/pkg/servicemanager"
+	"github.com/spf13/cobra"
+	"sigs.k8s.io/yaml"
+
+	"k8s.io/apimachinery/pkg/util/duration"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	cmdutil "k8s.io/kubectl/pkg/cmd/util"
+)
+
+type StatusOptions struct {
+	Output     string
+	SocketPath string
+
+	genericclioptions.IOStreams
+}
+
+func NewStatusOptions(ioStreams genericclioptions.IOStreams) *StatusOptions {
+	return &StatusOptions{
+		SocketPath: servicemanager.StatusSocketPath,
+		IOStreams:  ioStreams,
+	}
+}
+
+func NewStatusCommand(ioStreams genericclioptions.IOStreams) *cobra.Command {
+	o := NewStatusOptions(ioStreams)
+	cmd := &cobra.Command{
+		Use:   "status",
+		Short: "Print the status of MicroShift's services",
+		Run: func(cmd *cobra.Command, args []string) {
+			cmdutil.CheckErr(o.Validate())
+			cmdutil.CheckErr(o.Run())
+		},
+	}
+
+	cmd.Flags().StringVarP(&o.Output, "output", "o", o.Output, "One of 'yaml' or 'json'.")
+
+	return cmd
+}
+
+func (o *StatusOptions) Validate() error {
+	switch o.Output {
+	case "", "yaml", "json":
+		return nil
+	default:
+		return fmt.Errorf("invalid output format %q, must be one of 'yaml' or 'json'", o.Output)
+	}
+}
+
+func (o *StatusOptions) Run() error {
+	statuses, err := servicemanager.GetStatus(o.SocketPath)
+	if err != nil {
+		return err
+	}
+
+	switch o.Output {
+	case "":
+		return printStatusTable(o, statuses)
+	case "yaml":
+		marshalled, err := yaml.Marshal(statuses)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(o.Out, string(marshalled))
+	case "json":
+		marshalled, err := json.MarshalIndent(statuses, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(o.Out, string(marshalled))
+	default:
+		// There is a bug in the program if we hit this case.
+		// However, we follow a policy of never panicking.
+		return fmt.Errorf("StatusOptions were not validated: --output=%q should have been rejected", o.Output)
+	}
+
+	return nil
+}
+
+func printStatusTable(o *StatusOptions, statuses []servicemanager.ServiceStatus) error {
+	w := tabwriter.NewWriter(o.Out, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "SERVICE\tSTATE\tSINCE\tRESTARTS\tLAST ERROR")
+	for _, s := range statuses {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n",
+			s.Name,
+			s.State,
+			duration.HumanDuration(time.Since(s.Since)),
+			strconv.Itoa(s.Restarts),
+			s.LastError,
+		)
+	}
+	return w.Flush()
+}
@@ -1,32 +1,89 @@
 package components
 
 import (
+	"fmt"
+
 	"github.com/openshift/microshift/pkg/config"
 	"k8s.io/klog/v2"
 )
 
 var microshiftDataDir = config.GetDataDir()
 
+// Names of the embedded components, as used to disable them in the config.
+const (
+	ServiceCA     = "service-ca"
+	CSI           = "csi"
+	IngressRouter = "ingress-router"
+	DNS           = "dns"
+)
+
+// componentDependencies lists the embedded components each component needs
+// to work, e.g. the router and DNS consume certificates from the service-ca.
+var componentDependencies = map[string][]string{
+	ServiceCA:     {},
+	CSI:           {},
+	IngressRouter: {ServiceCA},
+	DNS:           {ServiceCA},
+}
+
+// IsComponent returns whether `name` is the name of an embedded component.
+func IsComponent(name string) bool {
+	_, exists := componentDependencies[name]
+	return exists
+}
+
+// Validate checks that no enabled embedded component depends on a disabled one.
+func Validate(cfg *config.MicroshiftConfig) error {
+	for _, name := range []string{ServiceCA, CSI, IngressRouter, DNS} {
+		if !cfg.Components.IsEnabled(name) {
+			continue
+		}
+		for _, dependency := range componentDependencies[name] {
+			if !cfg.Components.IsEnabled(dependency) {
+				return fmt.Errorf("component '%s' depends on disabled component '%s'", name, dependency)
+			}
+		}
+	}
+	return nil
+}
+
 func StartComponents(cfg *config.MicroshiftConfig) error {
 	kubeAdminConfig := cfg.KubeConfigPath(config.KubeAdmin)
 
-	if err := startServiceCAController(cfg, kubeAdminConfig); err != nil {
-		klog.Warningf("Failed to start service-ca controller: %v", err)
-		return err
+	if cfg.Components.IsEnabled(ServiceCA) {
+		if err := startServiceCAController(cfg, kubeAdminConfig); err != nil {
+			klog.Warningf("Failed to start service-ca controller: %v", err)
+			return err
+		}
+	} else {
+		klog.Infof("Not starting service-ca controller, it is disabled")
 	}
 
-	if err := startCSIPlugin(cfg, cfg.KubeConfigPath(config.KubeAdmin)); err != nil {
-		klog.Warningf("Failed to start csi plugin: %v", err)
-		return err
+	if cfg.Components.IsEnabled(CSI) {
+		if err := startCSIPlugin(cfg, cfg.KubeConfigPath(config.KubeAdmin)); err != nil {
+			klog.Warningf("Failed to start csi plugin: %v", err)
+			return err
+		}
+	} else {
+		klog.Infof("Not starting csi plugin, it is disabled")
 	}
 
-	if err := startIngressController(cfg, kubeAdminConfig); err != nil {
-		klog.Warningf("Failed to start ingress router controller: %v", err)
-		return err
+	if cfg.Components.IsEnabled(IngressRouter) {
+		if err := startIngressController(cfg, kubeAdminConfig); err != nil {
+			klog.Warningf("Failed to start ingress router controller: %v", err)
+			return err
+		}
+	} else {
+		klog.Infof("Not starting ingress router controller, it is disabled")
 	}
-	if err := startDNSController(cfg, kubeAdminConfig); err != nil {
-		klog.Warningf("Failed to start DNS controller: %v", err)
-		return err
+
+	if cfg.Components.IsEnabled(DNS) {
+		if err := startDNSController(cfg, kubeAdminConfig); err != nil {
+			klog.Warningf("Failed to start DNS controller: %v", err)
+			return err
+		}
+	} else {
+		klog.Infof("Not starting DNS controller, it is disabled")
 	}
 
 	if err := startCNIPlugin(cfg, kubeAdminConfig); err != nil {
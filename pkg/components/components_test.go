@@ -0,0 +1,27 @@
+package components
+
+import (
+	"testing"
+
+	"github.com/openshift/microshift/pkg/config"
+)
+
+func TestValidate(t *testing.T) {
+	var ttests = []struct {
+		disabled []string
+		wantErr  bool
+	}{
+		{nil, false},
+		{[]string{CSI}, false},
+		{[]string{IngressRouter, DNS, ServiceCA}, false},
+		{[]string{ServiceCA}, true},
+		{[]string{ServiceCA, DNS}, true},
+	}
+
+	for _, tt := range ttests {
+		cfg := &config.MicroshiftConfig{Components: config.ComponentsConfig{Disabled: tt.disabled}}
+		if err := Validate(cfg); (err != nil) != tt.wantErr {
+			t.Errorf("Validate() with disabled components %v returned error %v, wantErr %v", tt.disabled, err, tt.wantErr)
+		}
+	}
+}
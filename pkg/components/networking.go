@@ -6,7 +6,6 @@ import (
 
 	"github.com/openshift/microshift/pkg/assets"
 	"github.com/openshift/microshift/pkg/config"
-	"github.com/openshift/microshift/pkg/config/ovn"
 	"github.com/openshift/microshift/pkg/util"
 	"k8s.io/klog/v2"
 )
@@ -41,11 +40,7 @@ func startCNIPlugin(cfg *config.MicroshiftConfig, kubeconfigPath string) error {
 		}
 	)
 
-	ovnConfig, err := ovn.NewOVNKubernetesConfigFromFileOrDefault(filepath.Join(filepath.Dir(config.GetConfigFile()), ovn.ConfigFileName))
-	if err != nil {
-		return err
-	}
-
+	ovnConfig := &cfg.Network.OVN
 	if ovnConfig.DisableOVSInit {
 		if err := ovnConfig.ValidateOVSBridge(util.OVNGatewayInterface); err != nil {
 			return fmt.Errorf("failed to find ovn-kubernetes gateway bridge %s: %v", util.OVNGatewayInterface, err)
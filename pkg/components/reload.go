@@ -0,0 +1,120 @@
+package components
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"k8s.io/klog/v2"
+
+	"github.com/openshift/microshift/pkg/assets"
+	"github.com/openshift/microshift/pkg/config"
+)
+
+// ReloadCSIPluginConfig applies the lvmd ConfigMap rendered from the current
+// config. The topolvm-node DaemonSet is annotated with the checksum of the
+// lvmd config, so it is rolled out with the new config.
+func ReloadCSIPluginConfig(cfg *config.MicroshiftConfig) error {
+	if !cfg.Components.IsEnabled(CSI) {
+		klog.Infof("Not reloading csi plugin config, it is disabled")
+		return nil
+	}
+	var (
+		cm = []string{
+			"components/odf-lvm/topolvm-lvmd-config_configmap_v1.yaml",
+		}
+		ds = []string{
+			"components/odf-lvm/topolvm-node_daemonset.yaml",
+		}
+	)
+	kubeconfigPath := cfg.KubeConfigPath(config.KubeAdmin)
+
+	lvmdRenderParams, err := renderLvmdParams(&cfg.Storage.Lvmd)
+	if err != nil {
+		return fmt.Errorf("rendering lvmd params: %v", err)
+	}
+	if err := assets.ApplyConfigMaps(cm, renderTemplate, lvmdRenderParams, kubeconfigPath); err != nil {
+		klog.Warningf("Failed to apply configMap %v: %v", cm, err)
+		return err
+	}
+	if err := assets.ApplyDaemonSets(ds, renderTemplate, renderParamsFromConfig(cfg, lvmdRenderParams), kubeconfigPath); err != nil {
+		klog.Warningf("Failed to apply daemonsets %v: %v", ds, err)
+		return err
+	}
+	return nil
+}
+
+// ReloadCNIPluginConfig applies the ovnkube ConfigMap rendered from the
+// current config. The ovnkube DaemonSets are annotated with the MTU, so they
+// are rolled out when it changes. Existing pods keep their MTU until they are
+// recreated.
+func ReloadCNIPluginConfig(cfg *config.MicroshiftConfig) error {
+	var (
+		cm = []string{
+			"components/ovn/configmap.yaml",
+		}
+		apps = []string{
+			"components/ovn/master/daemonset.yaml",
+			"components/ovn/node/daemonset.yaml",
+		}
+	)
+	kubeconfigPath := cfg.KubeConfigPath(config.KubeAdmin)
+
+	extraParams := assets.RenderParams{
+		"OVNConfig":      &cfg.Network.OVN,
+		"KubeconfigPath": kubeconfigPath,
+		"KubeconfigDir":  filepath.Join(microshiftDataDir, "/resources/kubeadmin"),
+	}
+	if err := assets.ApplyConfigMaps(cm, renderTemplate, renderParamsFromConfig(cfg, extraParams), kubeconfigPath); err != nil {
+		klog.Warningf("Failed to apply configMap %v %v", cm, err)
+		return err
+	}
+	if err := assets.ApplyDaemonSets(apps, renderTemplate, renderParamsFromConfig(cfg, extraParams), kubeconfigPath); err != nil {
+		klog.Warningf("Failed to apply apps %v %v", apps, err)
+		return err
+	}
+	return nil
+}
+
+// ReloadIngressRouterConfig applies the router Deployment rendered from the
+// current config, which rolls out the router with the new settings.
+func ReloadIngressRouterConfig(cfg *config.MicroshiftConfig) error {
+	if !cfg.Components.IsEnabled(IngressRouter) {
+		klog.Infof("Not reloading ingress router config, it is disabled")
+		return nil
+	}
+	apps := []string{
+		"components/openshift-router/deployment.yaml",
+	}
+	kubeconfigPath := cfg.KubeConfigPath(config.KubeAdmin)
+
+	if err := assets.ApplyDeployments(apps, renderTemplate, renderParamsFromConfig(cfg, renderRouterParams(cfg)), kubeconfigPath); err != nil {
+		klog.Warningf("Failed to apply apps %v: %v", apps, err)
+		return err
+	}
+	return nil
+}
+
+// ReloadIngressRouterServingCertificate applies the router's default serving
+// certificate secret with the given certificate and key. The kubelet updates
+// the certificate mounted into the router pods.
+func ReloadIngressRouterServingCertificate(cfg *config.MicroshiftConfig, certPEM, keyPEM []byte) error {
+	if !cfg.Components.IsEnabled(IngressRouter) {
+		klog.Infof("Not reloading ingress router serving certificate, it is disabled")
+		return nil
+	}
+	servingKeypairSecret := "components/openshift-router/serving-certificate.yaml"
+	kubeconfigPath := cfg.KubeConfigPath(config.KubeAdmin)
+
+	if err := assets.ApplySecretWithData(
+		servingKeypairSecret,
+		map[string][]byte{
+			"tls.crt": certPEM,
+			"tls.key": keyPEM,
+		},
+		kubeconfigPath,
+	); err != nil {
+		klog.Warningf("failed to apply secret %q: %v", servingKeypairSecret, err)
+		return err
+	}
+	return nil
+}
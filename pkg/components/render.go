@@ -5,6 +5,7 @@ import (
 	"crypto/sha256"
 	"fmt"
 	"path/filepath"
+	"strconv"
 	"text/template"
 
 	"sigs.k8s.io/yaml"
@@ -48,6 +49,13 @@ func renderTemplate(tb []byte, data assets.RenderParams) ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
+func renderRouterParams(cfg *config.MicroshiftConfig) assets.RenderParams {
+	interNamespaceAllowed := cfg.Ingress.RouteAdmissionPolicy.NamespaceOwnership == config.InterNamespaceAllowedOwnershipCheck
+	return assets.RenderParams{
+		"RouterDisableNamespaceOwnershipCheck": strconv.FormatBool(interNamespaceAllowed),
+	}
+}
+
 func renderLvmdParams(l *lvmd.Lvmd) (assets.RenderParams, error) {
 	r := make(assets.RenderParams)
 	b, err := yaml.Marshal(l)
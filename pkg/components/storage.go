@@ -2,13 +2,11 @@ package components
 
 import (
 	"fmt"
-	"path/filepath"
 
 	"k8s.io/klog/v2"
 
 	"github.com/openshift/microshift/pkg/assets"
 	"github.com/openshift/microshift/pkg/config"
-	"github.com/openshift/microshift/pkg/config/lvmd"
 )
 
 func startCSIPlugin(cfg *config.MicroshiftConfig, kubeconfigPath string) error {
@@ -64,13 +62,7 @@ func startCSIPlugin(cfg *config.MicroshiftConfig, kubeconfigPath string) error {
 		}
 	)
 
-	// the lvmd file should be located in the same directory as the microshift config to minimize coupling with the
-	// csi plugin.
-	lvmdCfg, err := lvmd.NewLvmdConfigFromFileOrDefault(filepath.Join(filepath.Dir(config.GetConfigFile()), "lvmd.yaml"))
-	if err != nil {
-		return err
-	}
-	lvmdRenderParams, err := renderLvmdParams(lvmdCfg)
+	lvmdRenderParams, err := renderLvmdParams(&cfg.Storage.Lvmd)
 	if err != nil {
 		return fmt.Errorf("rendering lvmd params: %v", err)
 	}
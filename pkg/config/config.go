@@ -8,32 +8,44 @@ import (
 	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/apparentlymart/go-cidr/cidr"
 	"github.com/kelseyhightower/envconfig"
 	"github.com/mitchellh/go-homedir"
 	"github.com/spf13/pflag"
 
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/component-base/logs"
 	"k8s.io/klog/v2"
-	"sigs.k8s.io/yaml"
 
+	"github.com/openshift/microshift/pkg/config/lvmd"
+	"github.com/openshift/microshift/pkg/config/ovn"
+	"github.com/openshift/microshift/pkg/encryption"
 	"github.com/openshift/microshift/pkg/util"
+	"github.com/openshift/microshift/pkg/util/cryptomaterial"
 )
 
 const (
-	defaultUserConfigFile   = "~/.microshift/config.yaml"
-	defaultUserDataDir      = "~/.microshift/data"
-	defaultGlobalConfigFile = "/etc/microshift/config.yaml"
-	defaultGlobalDataDir    = "/var/lib/microshift"
+	defaultUserConfigDir   = "~/.microshift"
+	defaultUserDataDir     = "~/.microshift/data"
+	defaultGlobalConfigDir = "/etc/microshift"
+	defaultGlobalDataDir   = "/var/lib/microshift"
+	configFileName         = "config.yaml"
+	configDropInDirName    = "config.d"
 	// for files managed via management system in /etc, i.e. user applications
 	defaultManifestDirEtc = "/etc/microshift/manifests"
 	// for files embedded in ostree. i.e. cni/other component customizations
 	defaultManifestDirLib = "/usr/lib/microshift/manifests"
+	// outside of the data directory, so that backups survive its removal
+	defaultBackupDir = "/var/lib/microshift-backups"
 )
 
 var (
+	configDir    = findConfigDir()
 	configFile   = findConfigFile()
 	dataDir      = findDataDir()
 	manifestsDir = findManifestsDir()
@@ -50,8 +62,234 @@ type ClusterConfig struct {
 }
 
 type IngressConfig struct {
-	ServingCertificate []byte
-	ServingKey         []byte
+	RouteAdmissionPolicy RouteAdmissionPolicy `json:"routeAdmissionPolicy"`
+
+	ServingCertificate []byte `json:"-"`
+	ServingKey         []byte `json:"-"`
+}
+
+// NamespaceOwnershipCheck is the policy for admitting routes claiming a host
+// that is already claimed by a route in another namespace.
+type NamespaceOwnershipCheck string
+
+const (
+	// StrictNamespaceOwnershipCheck does not allow routes in different
+	// namespaces to claim the same host.
+	StrictNamespaceOwnershipCheck NamespaceOwnershipCheck = "Strict"
+	// InterNamespaceAllowedOwnershipCheck allows routes in different
+	// namespaces to claim different paths of the same host.
+	InterNamespaceAllowedOwnershipCheck NamespaceOwnershipCheck = "InterNamespaceAllowed"
+)
+
+// RouteAdmissionPolicy configures how the router admits routes.
+type RouteAdmissionPolicy struct {
+	NamespaceOwnership NamespaceOwnershipCheck `json:"namespaceOwnership"`
+}
+
+// ManifestsConfig configures the manifests MicroShift applies on startup.
+type ManifestsConfig struct {
+	// KustomizePaths are the directories with a kustomization.yaml to apply,
+	// in order.
+	KustomizePaths []string `json:"kustomizePaths"`
+}
+
+type ComponentsConfig struct {
+	// Disabled lists the services and embedded components that MicroShift
+	// should not run, e.g. "microshift-mdns-controller" or "ingress-router".
+	Disabled []string `json:"disabled,omitempty"`
+	// StartupTimeouts overrides how long a service may take to become ready
+	// after being started, keyed by service name. A zero duration disables
+	// the deadline.
+	StartupTimeouts map[string]metav1.Duration `json:"startupTimeouts,omitempty"`
+	// StopTimeouts overrides how long MicroShift waits for a service to stop
+	// before stopping its dependencies anyway, keyed by service name. A zero
+	// duration disables the deadline.
+	StopTimeouts map[string]metav1.Duration `json:"stopTimeouts,omitempty"`
+}
+
+// StorageConfig holds the settings of the TopoLVM CSI plugin.
+type StorageConfig struct {
+	// Lvmd is the configuration of lvmd, in the format of lvmd.yaml.
+	Lvmd lvmd.Lvmd `json:"lvmd"`
+}
+
+// NetworkConfig holds the settings of the ovn-kubernetes CNI plugin.
+type NetworkConfig struct {
+	// OVN is the configuration of ovn-kubernetes, in the format of ovn.yaml.
+	OVN ovn.OVNKubernetesConfig `json:"ovn"`
+}
+
+// APIServerConfig holds the settings of kube-apiserver.
+type APIServerConfig struct {
+	// SubjectAltNames are added to the API server's serving certificate for
+	// the node name, e.g. DNS aliases or IP addresses clients connect to.
+	SubjectAltNames []string `json:"subjectAltNames,omitempty"`
+
+	// NamedCertificates are served in addition to the certificates MicroShift
+	// generates, for the SNI names they list or, if none, the names in the
+	// certificate. They take precedence over the generated certificates.
+	NamedCertificates []NamedCertificate `json:"namedCertificates,omitempty"`
+
+	// Encryption of secrets and configmaps in etcd.
+	Encryption EncryptionConfig `json:"encryption"`
+}
+
+// EncryptionConfig selects how resources are encrypted in etcd.
+type EncryptionConfig struct {
+	// Type of the encryption, one of identity, aescbc, aesgcm or secretbox.
+	// Resources are not encrypted if it is empty or identity.
+	Type string `json:"type,omitempty"`
+}
+
+// ProviderType returns the type of the provider resources are written with.
+func (c *EncryptionConfig) ProviderType() encryption.Type {
+	if c.Type == "" {
+		return encryption.Identity
+	}
+	return encryption.Type(c.Type)
+}
+
+// NamedCertificate is a serving certificate and key supplied by the user.
+type NamedCertificate struct {
+	Names    []string `json:"names,omitempty"`
+	CertPath string   `json:"certPath"`
+	KeyPath  string   `json:"keyPath"`
+}
+
+// CertificatesConfig configures the certificates MicroShift generates.
+type CertificatesConfig struct {
+	// CustomSigners are CAs supplied by the user, keyed by the name of the
+	// signer they replace, see CustomizableSigners.
+	CustomSigners map[string]CustomSigner `json:"customSigners,omitempty"`
+
+	// KeyAlgorithm of the keys of the generated signers and certificates,
+	// RSA-2048 if empty.
+	KeyAlgorithm cryptomaterial.KeyAlgorithm `json:"keyAlgorithm,omitempty"`
+	// ServiceAccountKeyAlgorithm of the key service account tokens are
+	// signed with, RSA-2048 if empty.
+	ServiceAccountKeyAlgorithm cryptomaterial.KeyAlgorithm `json:"serviceAccountKeyAlgorithm,omitempty"`
+
+	// PKCS11 keeps the keys of the generated root CAs on a PKCS#11 token
+	// instead of files if it is enabled.
+	PKCS11 PKCS11Config `json:"pkcs11"`
+}
+
+// PKCS11Config selects the PKCS#11 token keys are kept on.
+type PKCS11Config struct {
+	// ModulePath is the PKCS#11 module (shared library) of the token.
+	ModulePath string `json:"modulePath,omitempty"`
+	// TokenLabel is the label of the token.
+	TokenLabel string `json:"tokenLabel,omitempty"`
+	// PINFile contains the PIN of the token's user.
+	PINFile string `json:"pinFile,omitempty"`
+}
+
+// IsEnabled returns whether any of the token's settings is set.
+func (c *PKCS11Config) IsEnabled() bool {
+	return *c != PKCS11Config{}
+}
+
+// CustomSigner is a CA certificate and key supplied by the user. The
+// certificate file may contain the chain up to the root CA after the CA
+// certificate.
+type CustomSigner struct {
+	CertPath string `json:"certPath"`
+	KeyPath  string `json:"keyPath"`
+}
+
+// CustomizableSigners are the signers that can be replaced by a custom CA.
+var CustomizableSigners = []string{"admin-kubeconfig-signer", "kube-apiserver-external-signer"}
+
+// EtcdConfig holds the settings of MicroShift's etcd.
+type EtcdConfig struct {
+	// QuotaBackendSize is the size of etcd's database at which it raises a
+	// NOSPACE alarm and only accepts reads and deletes, e.g. "2Gi".
+	QuotaBackendSize string `json:"quotaBackendSize"`
+	// AutoCompactionMode is "periodic" to compact the history older than
+	// AutoCompactionRetention, e.g. "10m", or "revision" to keep the
+	// AutoCompactionRetention latest revisions, e.g. "10000".
+	AutoCompactionMode      string `json:"autoCompactionMode"`
+	AutoCompactionRetention string `json:"autoCompactionRetention"`
+
+	Defragmentation DefragmentationConfig `json:"defragmentation"`
+
+	// Mode is "embedded" to run etcd within the MicroShift process or
+	// "process" to run it in a child process supervised by MicroShift.
+	Mode string `json:"mode"`
+	// MemoryLimit is the memory the etcd process may use before the kernel
+	// reclaims and eventually kills it, e.g. "1Gi". It is unlimited if empty
+	// and requires the process mode.
+	MemoryLimit string `json:"memoryLimit,omitempty"`
+	// GoMemoryLimit is the soft memory limit of the Go runtime of the etcd
+	// process (GOMEMLIMIT), at which it collects garbage more aggressively.
+	// It defaults to 90% of MemoryLimit. Binaries built with Go older than
+	// 1.19 ignore it.
+	GoMemoryLimit string `json:"goMemoryLimit,omitempty"`
+}
+
+const (
+	EtcdModeEmbedded = "embedded"
+	EtcdModeProcess  = "process"
+)
+
+// QuotaBackendBytes returns the quota of etcd's database in bytes.
+func (c *EtcdConfig) QuotaBackendBytes() int64 {
+	quota, err := resource.ParseQuantity(c.QuotaBackendSize)
+	if err != nil {
+		return 0
+	}
+	return quota.Value()
+}
+
+// MemoryLimitBytes returns the memory limit of the etcd process in bytes,
+// zero if it is unlimited.
+func (c *EtcdConfig) MemoryLimitBytes() int64 {
+	limit, err := resource.ParseQuantity(c.MemoryLimit)
+	if err != nil {
+		return 0
+	}
+	return limit.Value()
+}
+
+// GoMemoryLimitBytes returns the soft memory limit of the Go runtime of the
+// etcd process in bytes, zero if it is unlimited.
+func (c *EtcdConfig) GoMemoryLimitBytes() int64 {
+	if c.GoMemoryLimit == "" {
+		return c.MemoryLimitBytes() * 9 / 10
+	}
+	limit, err := resource.ParseQuantity(c.GoMemoryLimit)
+	if err != nil {
+		return 0
+	}
+	return limit.Value()
+}
+
+// DefragmentationConfig configures when etcd's database is defragmented to
+// release the space freed by compactions.
+type DefragmentationConfig struct {
+	// CheckInterval is the time between checks of the fragmentation, it is
+	// never defragmented if it is zero.
+	CheckInterval metav1.Duration `json:"checkInterval"`
+	// FragmentationThreshold is the percentage of the database's size that
+	// is unused above which it is defragmented.
+	FragmentationThreshold int `json:"fragmentationThreshold"`
+}
+
+// BackupConfig configures where backups are stored and how often they are
+// taken.
+type BackupConfig struct {
+	// Directory the backups are stored in.
+	Directory string `json:"directory"`
+	// Interval between scheduled backups, none are taken if it is zero.
+	Interval metav1.Duration `json:"interval"`
+	// MaxBackups is the number of scheduled backups that are kept, older ones
+	// are removed. Backups taken with "microshift backup create" are kept.
+	MaxBackups int `json:"maxBackups"`
+}
+
+// IsEnabled returns whether the service or embedded component is enabled.
+func (c *ComponentsConfig) IsEnabled(name string) bool {
+	return !StringInList(name, c.Disabled)
 }
 
 type MicroshiftConfig struct {
@@ -62,13 +300,55 @@ type MicroshiftConfig struct {
 
 	Cluster ClusterConfig `json:"cluster"`
 
-	Ingress IngressConfig `json:"-"`
+	Components ComponentsConfig `json:"components"`
+
+	Storage StorageConfig `json:"storage"`
+	Network NetworkConfig `json:"network"`
+
+	Ingress IngressConfig `json:"ingress"`
+
+	Manifests ManifestsConfig `json:"manifests"`
+
+	APIServer    APIServerConfig    `json:"apiServer"`
+	Certificates CertificatesConfig `json:"certificates"`
+
+	Etcd   EtcdConfig   `json:"etcd"`
+	Backup BackupConfig `json:"backup"`
+
+	// sources records where the values that were not defaulted were set.
+	sources map[string]ValueSource
 }
 
 func GetConfigFile() string {
 	return configFile
 }
 
+// GetConfigDir returns the directory with the config file and the fallback
+// lvmd.yaml and ovn.yaml files.
+func GetConfigDir() string {
+	return configDir
+}
+
+// GetConfigDropInDir returns the directory with the drop-in config files that
+// are merged on top of the config file.
+func GetConfigDropInDir() string {
+	return filepath.Join(configDir, configDropInDirName)
+}
+
+// GetConfigFiles returns the config file, if it exists, followed by the
+// drop-in config files in lexical order.
+func GetConfigFiles() ([]string, error) {
+	configFiles := []string{}
+	if configFile != "" {
+		configFiles = append(configFiles, configFile)
+	}
+	dropIns, err := filepath.Glob(filepath.Join(GetConfigDropInDir(), "*.yaml"))
+	if err != nil {
+		return nil, err
+	}
+	return append(configFiles, dropIns...), nil
+}
+
 func GetDataDir() string {
 	return dataDir
 }
@@ -93,10 +373,13 @@ func (cfg *MicroshiftConfig) KubeConfigPath(id KubeConfigID) string {
 }
 
 func NewMicroshiftConfig() *MicroshiftConfig {
-	nodeName, err := os.Hostname()
+	hostname, err := os.Hostname()
 	if err != nil {
 		klog.Fatalf("Failed to get hostname %v", err)
 	}
+	// Default the node name like the kubelet does, node names must be
+	// lowercase.
+	nodeName := strings.ToLower(strings.TrimSpace(hostname))
 	nodeIP, err := util.GetHostIP()
 	if err != nil {
 		klog.Fatalf("failed to get host IP: %v", err)
@@ -113,6 +396,34 @@ func NewMicroshiftConfig() *MicroshiftConfig {
 			ServiceNodePortRange: "30000-32767",
 			Domain:               "cluster.local",
 		},
+		Storage: StorageConfig{
+			Lvmd: *lvmd.NewDefaultLvmdConfig(),
+		},
+		Network: NetworkConfig{
+			OVN: *ovn.NewDefaultOVNKubernetesConfig(),
+		},
+		Ingress: IngressConfig{
+			RouteAdmissionPolicy: RouteAdmissionPolicy{
+				NamespaceOwnership: StrictNamespaceOwnershipCheck,
+			},
+		},
+		Manifests: ManifestsConfig{
+			KustomizePaths: GetManifestsDir(),
+		},
+		Etcd: EtcdConfig{
+			QuotaBackendSize:        "2Gi",
+			AutoCompactionMode:      "periodic",
+			AutoCompactionRetention: "10m",
+			Defragmentation: DefragmentationConfig{
+				CheckInterval:          metav1.Duration{Duration: 10 * time.Minute},
+				FragmentationThreshold: 45,
+			},
+			Mode: EtcdModeEmbedded,
+		},
+		Backup: BackupConfig{
+			Directory:  defaultBackupDir,
+			MaxBackups: 7,
+		},
 	}
 }
 
@@ -137,19 +448,26 @@ func (c *ClusterConfig) ApiServerPort() (int, error) {
 	return portNum, nil
 }
 
-// Returns the default user config file if that exists, else the default global
-// config file, else the empty string.
-func findConfigFile() string {
-	userConfigFile, _ := homedir.Expand(defaultUserConfigFile)
-	if _, err := os.Stat(userConfigFile); errors.Is(err, os.ErrNotExist) {
-		if _, err := os.Stat(defaultGlobalConfigFile); errors.Is(err, os.ErrNotExist) {
-			return ""
-		} else {
-			return defaultGlobalConfigFile
+// Returns the default user config dir if it contains a config file or a
+// drop-in config dir, else the default global config dir.
+func findConfigDir() string {
+	userConfigDir, _ := homedir.Expand(defaultUserConfigDir)
+	for _, name := range []string{configFileName, configDropInDirName} {
+		if _, err := os.Stat(filepath.Join(userConfigDir, name)); err == nil {
+			return userConfigDir
 		}
-	} else {
-		return userConfigFile
 	}
+	return defaultGlobalConfigDir
+}
+
+// Returns the config file in the config dir if that exists, else the empty
+// string.
+func findConfigFile() string {
+	configFile := filepath.Join(configDir, configFileName)
+	if _, err := os.Stat(configFile); errors.Is(err, os.ErrNotExist) {
+		return ""
+	}
+	return configFile
 }
 
 // Returns the default user data dir if it exists or the user is non-root.
@@ -188,10 +506,72 @@ func (c *MicroshiftConfig) ReadFromConfigFile(configFile string) error {
 		return fmt.Errorf("reading config file %q: %v", configFile, err)
 	}
 
-	if err := yaml.Unmarshal(contents, c); err != nil {
-		return fmt.Errorf("decoding config file %q: %v", configFile, err)
+	return c.decode(configFile, contents)
+}
+
+// readFallbackConfigFiles reads the storage and network settings from the
+// lvmd.yaml and ovn.yaml files in `dir` if they are not set in the config
+// files. Those files predate the storage and network sections.
+func (c *MicroshiftConfig) readFallbackConfigFiles(dir string) error {
+	lvmdConfigFile := filepath.Join(dir, lvmd.ConfigFileName)
+	if exists, err := fallbackConfigFileExists(lvmdConfigFile, c.isSet("storage")); err != nil {
+		return err
+	} else if exists {
+		l, err := lvmd.NewLvmdConfigFromFileOrDefault(lvmdConfigFile)
+		if err != nil {
+			return err
+		}
+		c.Storage.Lvmd = *l
+		c.setSource("storage.lvmd", ValueSource{File: lvmdConfigFile})
+	}
+
+	ovnConfigFile := filepath.Join(dir, ovn.ConfigFileName)
+	if exists, err := fallbackConfigFileExists(ovnConfigFile, c.isSet("network")); err != nil {
+		return err
+	} else if exists {
+		o, err := ovn.NewOVNKubernetesConfigFromFileOrDefault(ovnConfigFile)
+		if err != nil {
+			return err
+		}
+		c.Network.OVN = *o
+		c.setSource("network.ovn", ValueSource{File: ovnConfigFile})
 	}
+	return nil
+}
 
+// fallbackConfigFileExists returns whether the fallback config file exists
+// and should be read, i.e. its section is not set in the config files.
+func fallbackConfigFileExists(path string, sectionSet bool) (bool, error) {
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	if sectionSet {
+		klog.Warningf("Ignoring %s, its settings are set in the config files", path)
+		return false, nil
+	}
+	return true, nil
+}
+
+// ReadFromConfigFiles reads the config files in order, with values set in
+// later files overriding those of earlier ones. Maps are merged, lists are
+// replaced. Unknown fields in all of the files are reported together.
+func (c *MicroshiftConfig) ReadFromConfigFiles(configFiles []string) error {
+	configErrs := &ConfigErrors{}
+	for _, configFile := range configFiles {
+		err := c.ReadFromConfigFile(configFile)
+		if unknown, ok := err.(*ConfigErrors); ok {
+			configErrs.Errors = append(configErrs.Errors, unknown.Errors...)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if len(configErrs.Errors) > 0 {
+		return configErrs
+	}
 	return nil
 }
 
@@ -231,25 +611,35 @@ func (c *MicroshiftConfig) ReadFromCmdLine(flags *pflag.FlagSet) error {
 	return nil
 }
 
-// Note: add a configFile parameter here because of unit test requiring custom
+// Note: add a configFiles parameter here because of unit test requiring custom
 // local directory
-func (c *MicroshiftConfig) ReadAndValidate(configFile string, flags *pflag.FlagSet) error {
-	if configFile != "" {
-		if err := c.ReadFromConfigFile(configFile); err != nil {
-			return err
-		}
+func (c *MicroshiftConfig) ReadAndValidate(configFiles []string, flags *pflag.FlagSet) error {
+	if err := c.ReadFromConfigFiles(configFiles); err != nil {
+		return err
 	}
+	if err := c.readFallbackConfigFiles(configDir); err != nil {
+		return err
+	}
+
+	before := *c
 	if err := c.ReadFromEnv(); err != nil {
 		return err
 	}
+	c.recordChanges(&before, ValueSource{EnvVar: "MICROSHIFT"})
+
+	before = *c
 	if err := c.ReadFromCmdLine(flags); err != nil {
 		return err
 	}
+	c.recordChanges(&before, ValueSource{CommandLine: true})
+
+	if errs := c.Validate(); len(errs) > 0 {
+		return c.configErrors(errs)
+	}
 
-	// validate serviceCIDR
 	clusterDNS, err := getClusterDNS(c.Cluster.ServiceCIDR)
 	if err != nil {
-		klog.Fatalf("failed to get DNS IP: %v", err)
+		return err
 	}
 	c.Cluster.DNS = clusterDNS
 
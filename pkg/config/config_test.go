@@ -2,11 +2,16 @@ package config
 
 import (
 	"os"
+	"path/filepath"
 	"reflect"
 	"strconv"
 	"testing"
+	"time"
 
 	"github.com/spf13/pflag"
+
+	"github.com/openshift/microshift/pkg/config/lvmd"
+	"github.com/openshift/microshift/pkg/config/ovn"
 )
 
 const (
@@ -32,6 +37,30 @@ func TestConfigFile(t *testing.T) {
 	}
 }
 
+// tests that per-service startup timeouts are parsed from the config file
+func TestStartupTimeoutsConfigFile(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "config.yaml")
+	contents := []byte("components:\n  startupTimeouts:\n    etcd: 5m\n    kube-apiserver: 90s\n")
+	if err := os.WriteFile(configFile, contents, 0600); err != nil {
+		t.Fatalf("failed to write config file: %v", err)
+	}
+
+	config := NewMicroshiftConfig()
+	if err := config.ReadFromConfigFile(configFile); err != nil {
+		t.Fatalf("ReadFromConfigFile() error = %v", err)
+	}
+
+	want := map[string]time.Duration{"etcd": 5 * time.Minute, "kube-apiserver": 90 * time.Second}
+	if len(config.Components.StartupTimeouts) != len(want) {
+		t.Errorf("got startup timeouts %v, want %v", config.Components.StartupTimeouts, want)
+	}
+	for name, timeout := range want {
+		if got := config.Components.StartupTimeouts[name].Duration; got != timeout {
+			t.Errorf("got startup timeout %s for %s, want %s", got, name, timeout)
+		}
+	}
+}
+
 // test that MicroShift is able to properly read the config from the commandline
 func TestCommandLineConfig(t *testing.T) {
 
@@ -51,6 +80,12 @@ func TestCommandLineConfig(t *testing.T) {
 					ServiceNodePortRange: "1024-32767",
 					Domain:               "cluster.local",
 				},
+				Storage:   StorageConfig{Lvmd: *lvmd.NewDefaultLvmdConfig()},
+				Network:   NetworkConfig{OVN: *ovn.NewDefaultOVNKubernetesConfig()},
+				Ingress:   IngressConfig{RouteAdmissionPolicy: RouteAdmissionPolicy{NamespaceOwnership: StrictNamespaceOwnershipCheck}},
+				Manifests: ManifestsConfig{KustomizePaths: GetManifestsDir()},
+				Etcd:      NewMicroshiftConfig().Etcd,
+				Backup:    BackupConfig{Directory: defaultBackupDir, MaxBackups: 7},
 			},
 			err: nil,
 		},
@@ -97,6 +132,52 @@ func TestCommandLineConfig(t *testing.T) {
 	}
 }
 
+// tests that the storage and network settings are read from lvmd.yaml and
+// ovn.yaml unless they are set in the config files
+func TestReadFallbackConfigFiles(t *testing.T) {
+	dir := t.TempDir()
+	for name, contents := range map[string]string{
+		"lvmd.yaml": "device-classes:\n- name: gold\n  volume-group: vg1\n  default: true\n",
+		"ovn.yaml":  "disableOVSInit: true\nmtu: 1300\n",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(contents), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	c := NewMicroshiftConfig()
+	if err := c.readFallbackConfigFiles(dir); err != nil {
+		t.Fatalf("readFallbackConfigFiles() error = %v", err)
+	}
+	if len(c.Storage.Lvmd.DeviceClasses) != 1 || c.Storage.Lvmd.DeviceClasses[0].Name != "gold" {
+		t.Errorf("expected device classes from lvmd.yaml, got %+v", c.Storage.Lvmd.DeviceClasses)
+	}
+	if !c.Network.OVN.DisableOVSInit || c.Network.OVN.MTU != 1300 {
+		t.Errorf("expected ovn config from ovn.yaml, got %+v", c.Network.OVN)
+	}
+	if source := c.Sources()["network.ovn"]; source.File != filepath.Join(dir, "ovn.yaml") {
+		t.Errorf("expected ovn.yaml as source of the network settings, got %v", source)
+	}
+
+	configFile := filepath.Join(dir, "config.yaml")
+	if err := os.WriteFile(configFile, []byte("network:\n  ovn:\n    mtu: 1200\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	c = NewMicroshiftConfig()
+	if err := c.ReadFromConfigFile(configFile); err != nil {
+		t.Fatalf("ReadFromConfigFile() error = %v", err)
+	}
+	if err := c.readFallbackConfigFiles(dir); err != nil {
+		t.Fatalf("readFallbackConfigFiles() error = %v", err)
+	}
+	if c.Network.OVN.DisableOVSInit || c.Network.OVN.MTU != 1200 {
+		t.Errorf("expected ovn.yaml to be ignored, got %+v", c.Network.OVN)
+	}
+	if len(c.Storage.Lvmd.DeviceClasses) != 1 || c.Storage.Lvmd.DeviceClasses[0].Name != "gold" {
+		t.Errorf("expected device classes from lvmd.yaml, got %+v", c.Storage.Lvmd.DeviceClasses)
+	}
+}
+
 // test to verify that MicroShift is able to populate the config from the environment variables
 func TestEnvironmentVariableConfig(t *testing.T) {
 	// set up the table tests using the above environment variables & the MicroShift config struct
@@ -120,6 +201,12 @@ func TestEnvironmentVariableConfig(t *testing.T) {
 					ServiceNodePortRange: "1024-32767",
 					Domain:               "cluster.local",
 				},
+				Storage:   StorageConfig{Lvmd: *lvmd.NewDefaultLvmdConfig()},
+				Network:   NetworkConfig{OVN: *ovn.NewDefaultOVNKubernetesConfig()},
+				Ingress:   IngressConfig{RouteAdmissionPolicy: RouteAdmissionPolicy{NamespaceOwnership: StrictNamespaceOwnershipCheck}},
+				Manifests: ManifestsConfig{KustomizePaths: GetManifestsDir()},
+				Etcd:      NewMicroshiftConfig().Etcd,
+				Backup:    BackupConfig{Directory: defaultBackupDir, MaxBackups: 7},
 			},
 			err: nil,
 			envList: []struct {
@@ -148,6 +235,12 @@ func TestEnvironmentVariableConfig(t *testing.T) {
 					ServiceNodePortRange: "1024-32767",
 					Domain:               "cluster.local",
 				},
+				Storage:   StorageConfig{Lvmd: *lvmd.NewDefaultLvmdConfig()},
+				Network:   NetworkConfig{OVN: *ovn.NewDefaultOVNKubernetesConfig()},
+				Ingress:   IngressConfig{RouteAdmissionPolicy: RouteAdmissionPolicy{NamespaceOwnership: StrictNamespaceOwnershipCheck}},
+				Manifests: ManifestsConfig{KustomizePaths: GetManifestsDir()},
+				Etcd:      NewMicroshiftConfig().Etcd,
+				Backup:    BackupConfig{Directory: defaultBackupDir, MaxBackups: 7},
 			},
 			err: nil,
 			envList: []struct {
@@ -168,7 +261,7 @@ func TestEnvironmentVariableConfig(t *testing.T) {
 	for _, tt := range ttests {
 		// first set the values
 		for _, env := range tt.envList {
-			os.Setenv(env.varName, env.value)
+			t.Setenv(env.varName, env.value)
 		}
 		// then read the values
 		microShiftconfig := NewMicroshiftConfig()
@@ -190,7 +283,7 @@ func TestMicroshiftConfigReadAndValidate(t *testing.T) {
 
 	c := NewMicroshiftConfig()
 
-	if err := c.ReadAndValidate(testConfigFile, flags); err != nil {
+	if err := c.ReadAndValidate([]string{testConfigFile}, flags); err != nil {
 		t.Errorf("failed to read and validate config: %v", err)
 	}
 }
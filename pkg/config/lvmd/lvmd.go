@@ -10,6 +10,8 @@ import (
 )
 
 const (
+	ConfigFileName = "lvmd.yaml"
+
 	defaultSockName             = "/run/lvmd/lvmd.socket"
 	defaultRHEL4EdgeVolumeGroup = "rhel"
 )
@@ -34,6 +36,12 @@ func (l *Lvmd) withDefaults() *Lvmd {
 	return l
 }
 
+// NewDefaultLvmdConfig returns the lvmd configuration used when the user has
+// not specified one.
+func NewDefaultLvmdConfig() *Lvmd {
+	return new(Lvmd).withDefaults()
+}
+
 func newLvmdConfigFromFile(p string) (*Lvmd, error) {
 	l := new(Lvmd)
 	buf, err := os.ReadFile(p)
@@ -0,0 +1,76 @@
+package lvmd
+
+import (
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// Validate checks the lvmd configuration the same way lvmd does on startup,
+// so that errors are reported before the topolvm-node pods crash loop.
+func (l *Lvmd) Validate(fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	if l.SocketName == "" {
+		allErrs = append(allErrs, field.Required(fldPath.Child("socket-name"), ""))
+	}
+
+	classesPath := fldPath.Child("device-classes")
+	if len(l.DeviceClasses) == 0 {
+		return append(allErrs, field.Required(classesPath, "at least one device class is required"))
+	}
+
+	names := map[string]bool{}
+	defaults := 0
+	for i, dc := range l.DeviceClasses {
+		dcPath := classesPath.Index(i)
+		if dc == nil {
+			allErrs = append(allErrs, field.Required(dcPath, ""))
+			continue
+		}
+
+		if !qualifiedNameRegexp.MatchString(dc.Name) {
+			allErrs = append(allErrs, field.Invalid(dcPath.Child("name"), dc.Name, "must be a qualified name"))
+		} else if names[dc.Name] {
+			allErrs = append(allErrs, field.Duplicate(dcPath.Child("name"), dc.Name))
+		}
+		names[dc.Name] = true
+
+		if dc.VolumeGroup == "" {
+			allErrs = append(allErrs, field.Required(dcPath.Child("volume-group"), ""))
+		}
+		if dc.Default {
+			defaults++
+		}
+		if dc.Stripe != nil && *dc.Stripe == 0 {
+			allErrs = append(allErrs, field.Invalid(dcPath.Child("stripe"), *dc.Stripe, "must be greater than 0"))
+		}
+		if dc.StripeSize != "" && !stripeSizeRegexp.MatchString(dc.StripeSize) {
+			allErrs = append(allErrs, field.Invalid(dcPath.Child("stripe-size"), dc.StripeSize, "must be a size, e.g. 64k"))
+		}
+
+		switch dc.Type {
+		case TypeThin:
+			if dc.ThinPoolConfig == nil {
+				allErrs = append(allErrs, field.Required(dcPath.Child("thin-pool"), "required for thin device classes"))
+				break
+			}
+			if dc.ThinPoolConfig.Name == "" {
+				allErrs = append(allErrs, field.Required(dcPath.Child("thin-pool", "name"), ""))
+			}
+			if dc.ThinPoolConfig.OverprovisionRatio < 1.0 {
+				allErrs = append(allErrs, field.Invalid(dcPath.Child("thin-pool", "overprovision-ratio"),
+					dc.ThinPoolConfig.OverprovisionRatio, "must be at least 1.0"))
+			}
+		case TypeThick, "":
+			if dc.ThinPoolConfig != nil {
+				allErrs = append(allErrs, field.Forbidden(dcPath.Child("thin-pool"), "only allowed for thin device classes"))
+			}
+		default:
+			allErrs = append(allErrs, field.NotSupported(dcPath.Child("type"), dc.Type, []string{string(TypeThick), string(TypeThin)}))
+		}
+	}
+	if defaults != 1 {
+		allErrs = append(allErrs, field.Invalid(classesPath, defaults, "exactly one device class must be the default"))
+	}
+
+	return allErrs
+}
@@ -6,12 +6,17 @@ import (
 	"net"
 	"os"
 
-	"gopkg.in/yaml.v2"
+	"k8s.io/apimachinery/pkg/util/validation/field"
 	"k8s.io/klog/v2"
+	"sigs.k8s.io/yaml"
 )
 
 const (
 	ConfigFileName = "ovn.yaml"
+
+	defaultMTU = 1400
+	minMTU     = 576
+	maxMTU     = 9000
 )
 
 type OVNKubernetesConfig struct {
@@ -32,14 +37,30 @@ func (o *OVNKubernetesConfig) ValidateOVSBridge(bridge string) error {
 	return nil
 }
 
+// Validate checks the values of the ovn-kubernetes configuration.
+func (o *OVNKubernetesConfig) Validate(fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	if o.MTU < minMTU || o.MTU > maxMTU {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("mtu"), int(o.MTU),
+			fmt.Sprintf("must be between %d and %d", minMTU, maxMTU)))
+	}
+	return allErrs
+}
+
 func (o *OVNKubernetesConfig) withDefaults() *OVNKubernetesConfig {
 	o.DisableOVSInit = false
-	o.MTU = 1400
+	o.MTU = defaultMTU
 	return o
 }
 
+// NewDefaultOVNKubernetesConfig returns the ovn-kubernetes configuration used
+// when the user has not specified one.
+func NewDefaultOVNKubernetesConfig() *OVNKubernetesConfig {
+	return new(OVNKubernetesConfig).withDefaults()
+}
+
 func newOVNKubernetesConfigFromFile(path string) (*OVNKubernetesConfig, error) {
-	o := new(OVNKubernetesConfig)
+	o := new(OVNKubernetesConfig).withDefaults()
 	buf, err := os.ReadFile(path)
 	if err != nil {
 		return nil, err
@@ -63,7 +84,7 @@ func NewOVNKubernetesConfigFromFileOrDefault(path string) (*OVNKubernetesConfig,
 
 	o, err := newOVNKubernetesConfigFromFile(path)
 	if err == nil {
-		klog.Info("got OVNKubernetes config from file %q", path)
+		klog.Infof("got OVNKubernetes config from file %q", path)
 		return o, nil
 	}
 	return nil, fmt.Errorf("getting OVNKubernetes config: %v", err)
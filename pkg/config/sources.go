@@ -0,0 +1,182 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	sigsyaml "sigs.k8s.io/yaml"
+)
+
+// ValueSource describes where a config value was set.
+type ValueSource struct {
+	// File and Line point to the config file the value was read from.
+	File string
+	Line int
+	// EnvVar is the environment variable the value was read from.
+	EnvVar string
+	// CommandLine is set if the value was read from a command line flag.
+	CommandLine bool
+}
+
+func (s ValueSource) String() string {
+	switch {
+	case s.File != "" && s.Line > 0:
+		return fmt.Sprintf("%s:%d", s.File, s.Line)
+	case s.File != "":
+		return s.File
+	case s.EnvVar != "":
+		return "env " + s.EnvVar
+	case s.CommandLine:
+		return "command line"
+	default:
+		return "default"
+	}
+}
+
+// Sources returns the sources of the values that were not defaulted, keyed
+// by their field path, e.g. "cluster.serviceCIDR" or
+// "components.startupTimeouts[etcd]".
+func (c *MicroshiftConfig) Sources() map[string]ValueSource {
+	return c.sources
+}
+
+// isSet returns whether the value at the field path, or any value below it,
+// was set by a config file, environment variable or command line flag.
+func (c *MicroshiftConfig) isSet(path string) bool {
+	for p := range c.sources {
+		if p == path || strings.HasPrefix(p, path+".") || strings.HasPrefix(p, path+"[") {
+			return true
+		}
+	}
+	return false
+}
+
+// sourceOf returns the source of the value at the field path, or of its
+// closest parent with a known source, e.g. the list of a missing list item
+// field.
+func (c *MicroshiftConfig) sourceOf(path string) ValueSource {
+	for path != "" {
+		if source, ok := c.sources[path]; ok {
+			return source
+		}
+		path = path[:strings.LastIndexAny(path, ".[")+1]
+		path = strings.TrimRight(path, ".[")
+	}
+	return ValueSource{}
+}
+
+func (c *MicroshiftConfig) setSource(path string, source ValueSource) {
+	if c.sources == nil {
+		c.sources = map[string]ValueSource{}
+	}
+	c.sources[path] = source
+}
+
+// recordFileSources records the config file as the source of all values set
+// in the YAML document. Structs and maps are merged across config files, so
+// only their fields and keys are recorded. Map values are replaced as a whole
+// even if they are structs, so they are recorded as well.
+func (c *MicroshiftConfig) recordFileSources(configFile string, root *yaml.Node) {
+	walkYAML(root, reflect.TypeOf(c).Elem(), nil, func(path *field.Path, key, value *yaml.Node, t, parent reflect.Type) {
+		if t != nil && (!isMerged(t) || parent.Kind() == reflect.Map) {
+			c.setSource(path.String(), ValueSource{File: configFile, Line: key.Line})
+		}
+	})
+}
+
+// isMerged returns whether values of the type are merged with, rather than
+// replace, the values they are decoded onto.
+func isMerged(t reflect.Type) bool {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if reflect.PtrTo(t).Implements(jsonUnmarshalerType) {
+		return false
+	}
+	return t.Kind() == reflect.Struct || t.Kind() == reflect.Map
+}
+
+// recordChanges records `source` for all values that differ from `before`.
+// The environment variable is filled in with the name envconfig reads the
+// value from.
+func (c *MicroshiftConfig) recordChanges(before *MicroshiftConfig, source ValueSource) {
+	walkChanges(before, c, func(path *field.Path, envVar string) {
+		s := source
+		if s.EnvVar != "" {
+			s.EnvVar = source.EnvVar + envVar
+		}
+		c.setSource(path.String(), s)
+	})
+}
+
+// Diff returns the field paths of the values that differ between the
+// configurations, e.g. "logVLevel" or "storage.lvmd.device-classes". Structs
+// are compared field by field, all other values as a whole.
+func Diff(before, after *MicroshiftConfig) []string {
+	changes := []string{}
+	walkChanges(before, after, func(path *field.Path, _ string) {
+		changes = append(changes, path.String())
+	})
+	return changes
+}
+
+// walkChanges calls fn for each value that differs between the
+// configurations, with its field path and the suffix of the environment
+// variable envconfig reads it from. Structs that decode themselves, such as
+// durations, are compared as a whole like in the config files.
+func walkChanges(before, after *MicroshiftConfig, fn func(path *field.Path, envVar string)) {
+	var walk func(before, after reflect.Value, path *field.Path, envVar string)
+	walk = func(before, after reflect.Value, path *field.Path, envVar string) {
+		for i := 0; i < after.NumField(); i++ {
+			f := after.Type().Field(i)
+			name := strings.Split(f.Tag.Get("json"), ",")[0]
+			if name == "-" || !f.IsExported() {
+				continue
+			}
+			fieldPath, fieldEnvVar := childPath(path, name), envVar+"_"+strings.ToUpper(f.Name)
+			if f.Type.Kind() == reflect.Struct && !reflect.PtrTo(f.Type).Implements(jsonUnmarshalerType) {
+				walk(before.Field(i), after.Field(i), fieldPath, fieldEnvVar)
+				continue
+			}
+			if !reflect.DeepEqual(before.Field(i).Interface(), after.Field(i).Interface()) {
+				fn(fieldPath, fieldEnvVar)
+			}
+		}
+	}
+	walk(reflect.ValueOf(before).Elem(), reflect.ValueOf(after).Elem(), nil, "")
+}
+
+// MarshalAnnotated returns the configuration as YAML, with each value that
+// was not defaulted annotated with its source.
+func (c *MicroshiftConfig) MarshalAnnotated() ([]byte, error) {
+	marshalled, err := sigsyaml.Marshal(c)
+	if err != nil {
+		return nil, err
+	}
+	root, err := parseYAML(marshalled)
+	if err != nil || root == nil {
+		return marshalled, err
+	}
+
+	walkYAML(root, reflect.TypeOf(c).Elem(), nil, func(path *field.Path, key, value *yaml.Node, t, _ reflect.Type) {
+		if source, ok := c.sources[path.String()]; ok {
+			key.LineComment = source.String()
+		}
+	})
+
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(root); err != nil {
+		return nil, err
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
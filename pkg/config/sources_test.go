@@ -0,0 +1,124 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/pflag"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// tests that drop-in config files are merged on top of the config file and
+// that the source of each value is recorded
+func TestReadFromConfigFilesMerge(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"config.yaml":      "nodeName: node1\ncluster:\n  domain: example.local\ncomponents:\n  disabled: [csi]\n  startupTimeouts:\n    etcd: 2m\n",
+		"config.d/10.yaml": "cluster:\n  serviceCIDR: 10.44.0.0/16\ncomponents:\n  startupTimeouts:\n    kubelet: 3m\n",
+		"config.d/20.yaml": "nodeName: node2\ncomponents:\n  disabled: [dns]\nstorage:\n  lvmd:\n    device-classes:\n    - name: gold\n      volume-group: vg1\n      default: true\n",
+	}
+	configFiles := []string{}
+	for _, name := range []string{"config.yaml", "config.d/10.yaml", "config.d/20.yaml"} {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(files[name]), 0600); err != nil {
+			t.Fatal(err)
+		}
+		configFiles = append(configFiles, path)
+	}
+
+	c := NewMicroshiftConfig()
+	if err := c.ReadFromConfigFiles(configFiles); err != nil {
+		t.Fatalf("ReadFromConfigFiles() error = %v", err)
+	}
+
+	if c.NodeName != "node2" || c.Cluster.Domain != "example.local" || c.Cluster.ServiceCIDR != "10.44.0.0/16" {
+		t.Errorf("later files must override earlier ones, got %+v", c)
+	}
+	if !reflect.DeepEqual(c.Components.Disabled, []string{"dns"}) {
+		t.Errorf("lists must be replaced, got %v", c.Components.Disabled)
+	}
+	if dc := c.Storage.Lvmd.DeviceClasses; len(dc) != 1 || dc[0].Name != "gold" || dc[0].SpareGB != nil {
+		t.Errorf("lists of structs must be replaced, got %+v", dc)
+	}
+	if c.Components.StartupTimeouts["etcd"].Duration != 2*time.Minute || c.Components.StartupTimeouts["kubelet"].Duration != 3*time.Minute {
+		t.Errorf("maps must be merged, got %v", c.Components.StartupTimeouts)
+	}
+
+	want := map[string]ValueSource{
+		"nodeName":                                    {File: configFiles[2], Line: 1},
+		"cluster.domain":                              {File: configFiles[0], Line: 3},
+		"cluster.serviceCIDR":                         {File: configFiles[1], Line: 2},
+		"components.disabled":                         {File: configFiles[2], Line: 3},
+		"components.startupTimeouts[etcd]":            {File: configFiles[0], Line: 7},
+		"components.startupTimeouts[kubelet]":         {File: configFiles[1], Line: 5},
+		"storage.lvmd.device-classes":                 {File: configFiles[2], Line: 6},
+		"storage.lvmd.device-classes[0].name":         {File: configFiles[2], Line: 7},
+		"storage.lvmd.device-classes[0].volume-group": {File: configFiles[2], Line: 8},
+		"storage.lvmd.device-classes[0].default":      {File: configFiles[2], Line: 9},
+	}
+	if !reflect.DeepEqual(c.Sources(), want) {
+		t.Errorf("got sources %v, want %v", c.Sources(), want)
+	}
+}
+
+// tests that values read from the environment and command line are recorded
+// with their source and annotated in the marshalled config
+func TestMarshalAnnotated(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(configFile, []byte("nodeName: node1\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("MICROSHIFT_CLUSTER_DOMAIN", "example.local")
+	flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	flags.Int("v", 0, "")
+	if err := flags.Parse([]string{"--v=3"}); err != nil {
+		t.Fatal(err)
+	}
+
+	c := NewMicroshiftConfig()
+	if err := c.ReadAndValidate([]string{configFile}, flags); err != nil {
+		t.Fatalf("ReadAndValidate() error = %v", err)
+	}
+	marshalled, err := c.MarshalAnnotated()
+	if err != nil {
+		t.Fatalf("MarshalAnnotated() error = %v", err)
+	}
+
+	for _, line := range []string{
+		"  domain: example.local # env MICROSHIFT_CLUSTER_DOMAIN\n",
+		"  serviceCIDR: 10.43.0.0/16\n",
+		"logVLevel: 3 # command line\n",
+		"nodeName: node1 # " + configFile + ":1\n",
+	} {
+		if !strings.Contains(string(marshalled), line) {
+			t.Errorf("expected %q in annotated config:\n%s", line, marshalled)
+		}
+	}
+}
+
+// tests that Diff reports the field paths of the changed values
+func TestDiff(t *testing.T) {
+	before := NewMicroshiftConfig()
+	after := NewMicroshiftConfig()
+	if changes := Diff(before, after); len(changes) != 0 {
+		t.Errorf("expected no changes, got %v", changes)
+	}
+
+	after.LogVLevel = 4
+	after.Cluster.ServiceCIDR = "10.44.0.0/16"
+	after.Network.OVN.MTU = 1300
+	after.Manifests.KustomizePaths = []string{"/etc/microshift/manifests", "/opt/manifests"}
+	after.Etcd.Defragmentation.CheckInterval = metav1.Duration{Duration: time.Hour}
+	want := []string{"logVLevel", "cluster.serviceCIDR", "network.ovn.mtu", "manifests.kustomizePaths", "etcd.defragmentation.checkInterval"}
+	if changes := Diff(before, after); !reflect.DeepEqual(changes, want) {
+		t.Errorf("expected changes %v, got %v", want, changes)
+	}
+}
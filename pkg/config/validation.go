@@ -0,0 +1,554 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilnet "k8s.io/apimachinery/pkg/util/net"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	sigsyaml "sigs.k8s.io/yaml"
+
+	"github.com/openshift/microshift/pkg/encryption"
+	"github.com/openshift/microshift/pkg/util/cryptomaterial"
+)
+
+// ConfigError is an error in a config file, optionally pointing to the file
+// and line it was found at.
+type ConfigError struct {
+	File string
+	Line int
+	Err  *field.Error
+}
+
+func (e *ConfigError) Error() string {
+	switch {
+	case e.File != "" && e.Line > 0:
+		return fmt.Sprintf("%s:%d: %s", e.File, e.Line, e.Err.Error())
+	case e.File != "":
+		return fmt.Sprintf("%s: %s", e.File, e.Err.Error())
+	default:
+		return e.Err.Error()
+	}
+}
+
+// ConfigErrors lists all errors found in the config files.
+type ConfigErrors struct {
+	Errors []*ConfigError
+}
+
+func (e *ConfigErrors) Error() string {
+	msgs := make([]string, 0, len(e.Errors))
+	for _, err := range e.Errors {
+		msgs = append(msgs, err.Error())
+	}
+	return fmt.Sprintf("invalid configuration:\n%s", strings.Join(msgs, "\n"))
+}
+
+// Validate checks the semantics of the configuration, e.g. that the CIDRs
+// are well formed and do not overlap.
+func (c *MicroshiftConfig) Validate() field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	if c.LogVLevel < 0 {
+		allErrs = append(allErrs, field.Invalid(field.NewPath("logVLevel"), c.LogVLevel, "must not be negative"))
+	}
+
+	nodeNamePath := field.NewPath("nodeName")
+	for _, msg := range validation.IsDNS1123Subdomain(c.NodeName) {
+		allErrs = append(allErrs, field.Invalid(nodeNamePath, c.NodeName, msg))
+	}
+
+	nodeIPPath := field.NewPath("nodeIP")
+	nodeIP := net.ParseIP(c.NodeIP)
+	if nodeIP == nil {
+		allErrs = append(allErrs, field.Invalid(nodeIPPath, c.NodeIP, "must be a valid IP address"))
+	}
+
+	allErrs = append(allErrs, c.Cluster.validate(field.NewPath("cluster"), nodeIP)...)
+	allErrs = append(allErrs, c.Components.validate(field.NewPath("components"))...)
+	allErrs = append(allErrs, c.Storage.Lvmd.Validate(field.NewPath("storage", "lvmd"))...)
+	allErrs = append(allErrs, c.Network.OVN.Validate(field.NewPath("network", "ovn"))...)
+	allErrs = append(allErrs, c.Ingress.validate(field.NewPath("ingress"))...)
+	allErrs = append(allErrs, c.Manifests.validate(field.NewPath("manifests"))...)
+	allErrs = append(allErrs, c.APIServer.validate(field.NewPath("apiServer"))...)
+	allErrs = append(allErrs, c.Certificates.validate(field.NewPath("certificates"))...)
+	allErrs = append(allErrs, c.Etcd.validate(field.NewPath("etcd"))...)
+	allErrs = append(allErrs, c.Backup.validate(field.NewPath("backup"))...)
+	return allErrs
+}
+
+func (c *ClusterConfig) validate(fldPath *field.Path, nodeIP net.IP) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	cidrs := map[string]*net.IPNet{}
+	for _, cidr := range []struct {
+		name  string
+		value string
+	}{
+		{"clusterCIDR", c.ClusterCIDR},
+		{"serviceCIDR", c.ServiceCIDR},
+	} {
+		_, ipNet, err := net.ParseCIDR(cidr.value)
+		if err != nil {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child(cidr.name), cidr.value, "must be a valid CIDR"))
+			continue
+		}
+		cidrs[cidr.name] = ipNet
+		if nodeIP != nil && ipNet.Contains(nodeIP) {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child(cidr.name), cidr.value,
+				fmt.Sprintf("must not contain the node IP %s", nodeIP)))
+		}
+	}
+	if clusterNet, serviceNet := cidrs["clusterCIDR"], cidrs["serviceCIDR"]; clusterNet != nil && serviceNet != nil {
+		if clusterNet.Contains(serviceNet.IP) || serviceNet.Contains(clusterNet.IP) {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("serviceCIDR"), c.ServiceCIDR,
+				fmt.Sprintf("must not overlap with clusterCIDR %s", c.ClusterCIDR)))
+		}
+	}
+	if cidrs["serviceCIDR"] != nil {
+		if _, err := getClusterDNS(c.ServiceCIDR); err != nil {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("serviceCIDR"), c.ServiceCIDR,
+				"must have at least 10 distinct host addresses"))
+		}
+	}
+
+	for _, msg := range validation.IsDNS1123Subdomain(c.Domain) {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("domain"), c.Domain, msg))
+	}
+
+	urlPath := fldPath.Child("url")
+	apiServerPort := 0
+	if parsed, err := url.Parse(c.URL); err != nil {
+		allErrs = append(allErrs, field.Invalid(urlPath, c.URL, err.Error()))
+	} else if parsed.Scheme != "https" || parsed.Hostname() == "" {
+		allErrs = append(allErrs, field.Invalid(urlPath, c.URL, "must be an https URL with a host"))
+	} else if port, err := c.ApiServerPort(); err != nil || port < 1 || port > 65535 {
+		allErrs = append(allErrs, field.Invalid(urlPath, c.URL, "must have a valid port"))
+	} else {
+		apiServerPort = port
+	}
+
+	portRangePath := fldPath.Child("serviceNodePortRange")
+	if portRange, err := utilnet.ParsePortRange(c.ServiceNodePortRange); err != nil {
+		allErrs = append(allErrs, field.Invalid(portRangePath, c.ServiceNodePortRange, err.Error()))
+	} else if portRange.Base < 1 {
+		allErrs = append(allErrs, field.Invalid(portRangePath, c.ServiceNodePortRange, "must be a range of ports starting at 1 or above"))
+	} else if apiServerPort > 0 && portRange.Contains(apiServerPort) {
+		allErrs = append(allErrs, field.Invalid(portRangePath, c.ServiceNodePortRange,
+			fmt.Sprintf("must not contain the API server port %d", apiServerPort)))
+	}
+
+	return allErrs
+}
+
+func (c *ComponentsConfig) validate(fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	for _, timeouts := range []struct {
+		name  string
+		value map[string]metav1.Duration
+	}{
+		{"startupTimeouts", c.StartupTimeouts},
+		{"stopTimeouts", c.StopTimeouts},
+	} {
+		for _, name := range sortedKeys(timeouts.value) {
+			if timeout := timeouts.value[name]; timeout.Duration < 0 {
+				allErrs = append(allErrs, field.Invalid(fldPath.Child(timeouts.name).Key(name), timeout.Duration.String(), "must not be negative"))
+			}
+		}
+	}
+	return allErrs
+}
+
+func (c *IngressConfig) validate(fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	switch ownership := c.RouteAdmissionPolicy.NamespaceOwnership; ownership {
+	case StrictNamespaceOwnershipCheck, InterNamespaceAllowedOwnershipCheck:
+	default:
+		allErrs = append(allErrs, field.NotSupported(fldPath.Child("routeAdmissionPolicy", "namespaceOwnership"), ownership,
+			[]string{string(StrictNamespaceOwnershipCheck), string(InterNamespaceAllowedOwnershipCheck)}))
+	}
+	return allErrs
+}
+
+func (c *ManifestsConfig) validate(fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	for i, path := range c.KustomizePaths {
+		if !filepath.IsAbs(path) {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("kustomizePaths").Index(i), path, "must be an absolute path"))
+		}
+	}
+	return allErrs
+}
+
+func (c *APIServerConfig) validate(fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	if c.Encryption.Type != "" && !StringInList(c.Encryption.Type, encryption.Types) {
+		allErrs = append(allErrs, field.NotSupported(fldPath.Child("encryption", "type"), c.Encryption.Type, encryption.Types))
+	}
+	for i, name := range c.SubjectAltNames {
+		allErrs = append(allErrs, validateCertificateName(fldPath.Child("subjectAltNames").Index(i), name)...)
+	}
+	for i, cert := range c.NamedCertificates {
+		certPath := fldPath.Child("namedCertificates").Index(i)
+		allErrs = append(allErrs, validateFilePath(certPath.Child("certPath"), cert.CertPath)...)
+		allErrs = append(allErrs, validateFilePath(certPath.Child("keyPath"), cert.KeyPath)...)
+		for j, name := range cert.Names {
+			allErrs = append(allErrs, validateCertificateName(certPath.Child("names").Index(j), name)...)
+		}
+	}
+	return allErrs
+}
+
+// validateCertificateName validates an IP address or a DNS name, optionally
+// a wildcard, a certificate is valid for.
+func validateCertificateName(fldPath *field.Path, name string) field.ErrorList {
+	if net.ParseIP(name) != nil {
+		return nil
+	}
+	allErrs := field.ErrorList{}
+	for _, msg := range validation.IsDNS1123Subdomain(strings.TrimPrefix(name, "*.")) {
+		allErrs = append(allErrs, field.Invalid(fldPath, name, msg))
+	}
+	return allErrs
+}
+
+func (c *CertificatesConfig) validate(fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	names := make([]string, 0, len(c.CustomSigners))
+	for name := range c.CustomSigners {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		signerPath := fldPath.Child("customSigners").Key(name)
+		if !StringInList(name, CustomizableSigners) {
+			allErrs = append(allErrs, field.NotSupported(signerPath, name, CustomizableSigners))
+			continue
+		}
+		allErrs = append(allErrs, validateFilePath(signerPath.Child("certPath"), c.CustomSigners[name].CertPath)...)
+		allErrs = append(allErrs, validateFilePath(signerPath.Child("keyPath"), c.CustomSigners[name].KeyPath)...)
+	}
+	for _, alg := range []struct {
+		name  string
+		value cryptomaterial.KeyAlgorithm
+	}{
+		{"keyAlgorithm", c.KeyAlgorithm},
+		{"serviceAccountKeyAlgorithm", c.ServiceAccountKeyAlgorithm},
+	} {
+		if alg.value != "" && !StringInList(string(alg.value), cryptomaterial.KeyAlgorithms) {
+			allErrs = append(allErrs, field.NotSupported(fldPath.Child(alg.name), alg.value, cryptomaterial.KeyAlgorithms))
+		}
+	}
+	if c.PKCS11.IsEnabled() {
+		pkcs11Path := fldPath.Child("pkcs11")
+		allErrs = append(allErrs, validateFilePath(pkcs11Path.Child("modulePath"), c.PKCS11.ModulePath)...)
+		if c.PKCS11.TokenLabel == "" {
+			allErrs = append(allErrs, field.Required(pkcs11Path.Child("tokenLabel"), ""))
+		}
+		allErrs = append(allErrs, validateFilePath(pkcs11Path.Child("pinFile"), c.PKCS11.PINFile)...)
+	}
+	return allErrs
+}
+
+// maxQuotaBackendSize is the largest quota etcd recommends, larger databases
+// degrade its performance.
+var maxQuotaBackendSize = resource.MustParse("8Gi")
+
+func (c *EtcdConfig) validate(fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	quotaPath := fldPath.Child("quotaBackendSize")
+	if quota, err := resource.ParseQuantity(c.QuotaBackendSize); err != nil {
+		allErrs = append(allErrs, field.Invalid(quotaPath, c.QuotaBackendSize, err.Error()))
+	} else if quota.Sign() <= 0 || quota.Cmp(maxQuotaBackendSize) > 0 {
+		allErrs = append(allErrs, field.Invalid(quotaPath, c.QuotaBackendSize, "must be positive and at most "+maxQuotaBackendSize.String()))
+	}
+
+	// the formats etcd accepts, a duration or a number of hours for periodic
+	// compactions and a number of revisions otherwise
+	retentionPath := fldPath.Child("autoCompactionRetention")
+	n, err := strconv.Atoi(c.AutoCompactionRetention)
+	switch c.AutoCompactionMode {
+	case "periodic":
+		if err != nil {
+			if d, err := time.ParseDuration(c.AutoCompactionRetention); err != nil || d < 0 {
+				allErrs = append(allErrs, field.Invalid(retentionPath, c.AutoCompactionRetention, "must be a duration or a number of hours"))
+			}
+		} else if n < 0 {
+			allErrs = append(allErrs, field.Invalid(retentionPath, c.AutoCompactionRetention, "must not be negative"))
+		}
+	case "revision":
+		if err != nil || n < 0 {
+			allErrs = append(allErrs, field.Invalid(retentionPath, c.AutoCompactionRetention, "must be a number of revisions"))
+		}
+	default:
+		allErrs = append(allErrs, field.NotSupported(fldPath.Child("autoCompactionMode"), c.AutoCompactionMode, []string{"periodic", "revision"}))
+	}
+
+	defragPath := fldPath.Child("defragmentation")
+	if c.Defragmentation.CheckInterval.Duration < 0 {
+		allErrs = append(allErrs, field.Invalid(defragPath.Child("checkInterval"), c.Defragmentation.CheckInterval.Duration.String(), "must not be negative"))
+	}
+	if threshold := c.Defragmentation.FragmentationThreshold; threshold < 1 || threshold > 99 {
+		allErrs = append(allErrs, field.Invalid(defragPath.Child("fragmentationThreshold"), threshold, "must be a percentage between 1 and 99"))
+	}
+
+	switch c.Mode {
+	case EtcdModeEmbedded:
+		// the memory of the MicroShift process is not limited
+		if c.MemoryLimit != "" {
+			allErrs = append(allErrs, field.Forbidden(fldPath.Child("memoryLimit"), "requires the process mode"))
+		}
+		if c.GoMemoryLimit != "" {
+			allErrs = append(allErrs, field.Forbidden(fldPath.Child("goMemoryLimit"), "requires the process mode"))
+		}
+	case EtcdModeProcess:
+		allErrs = append(allErrs, validateMemoryLimit(fldPath.Child("memoryLimit"), c.MemoryLimit)...)
+		allErrs = append(allErrs, validateMemoryLimit(fldPath.Child("goMemoryLimit"), c.GoMemoryLimit)...)
+		if c.MemoryLimit != "" && c.GoMemoryLimitBytes() > c.MemoryLimitBytes() {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("goMemoryLimit"), c.GoMemoryLimit, "must not exceed memoryLimit"))
+		}
+	default:
+		allErrs = append(allErrs, field.NotSupported(fldPath.Child("mode"), c.Mode, []string{EtcdModeEmbedded, EtcdModeProcess}))
+	}
+	return allErrs
+}
+
+// minEtcdMemoryLimit is the least memory etcd needs to start.
+var minEtcdMemoryLimit = resource.MustParse("128Mi")
+
+func validateMemoryLimit(fldPath *field.Path, limit string) field.ErrorList {
+	if limit == "" {
+		return nil
+	}
+	if quantity, err := resource.ParseQuantity(limit); err != nil {
+		return field.ErrorList{field.Invalid(fldPath, limit, err.Error())}
+	} else if quantity.Cmp(minEtcdMemoryLimit) < 0 {
+		return field.ErrorList{field.Invalid(fldPath, limit, "must be at least "+minEtcdMemoryLimit.String())}
+	}
+	return nil
+}
+
+func (c *BackupConfig) validate(fldPath *field.Path) field.ErrorList {
+	allErrs := validateFilePath(fldPath.Child("directory"), c.Directory)
+	if c.Interval.Duration < 0 {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("interval"), c.Interval.Duration.String(), "must not be negative"))
+	}
+	if c.MaxBackups < 1 {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("maxBackups"), c.MaxBackups, "must be positive"))
+	}
+	return allErrs
+}
+
+func validateFilePath(fldPath *field.Path, path string) field.ErrorList {
+	if path == "" {
+		return field.ErrorList{field.Required(fldPath, "")}
+	}
+	if !filepath.IsAbs(path) {
+		return field.ErrorList{field.Invalid(fldPath, path, "must be an absolute path")}
+	}
+	return nil
+}
+
+func sortedKeys(m map[string]metav1.Duration) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// ValidateConfigFiles reads the config files in order on top of the default
+// config and returns all errors found in them, pointing to the file and line
+// that set the offending value where possible. Unlike ReadAndValidate, it
+// neither reads the fallback config files nor the environment, so that the
+// result does not depend on the host the files are validated on.
+func ValidateConfigFiles(configFiles []string) error {
+	c := NewMicroshiftConfig()
+	if err := c.ReadFromConfigFiles(configFiles); err != nil {
+		return err
+	}
+	if errs := c.Validate(); len(errs) > 0 {
+		return c.configErrors(errs)
+	}
+	return nil
+}
+
+// configErrors points the errors to the file and line that set the offending
+// values, where known.
+func (c *MicroshiftConfig) configErrors(errs field.ErrorList) *ConfigErrors {
+	configErrs := &ConfigErrors{}
+	for _, err := range errs {
+		source := c.sourceOf(err.Field)
+		configErrs.Errors = append(configErrs.Errors, &ConfigError{File: source.File, Line: source.Line, Err: err})
+	}
+	return configErrs
+}
+
+// decode strictly decodes the contents of the config file into c, rejecting
+// fields that are unknown or whose case does not match, and records the file
+// as the source of the values it sets.
+func (c *MicroshiftConfig) decode(configFile string, contents []byte) error {
+	root, err := parseYAML(contents)
+	if err != nil {
+		return fmt.Errorf("decoding config file %q: %v", configFile, err)
+	}
+	if root == nil {
+		return nil
+	}
+	if unknown := unknownFields(configFile, root, reflect.TypeOf(c).Elem()); len(unknown) > 0 {
+		return &ConfigErrors{Errors: unknown}
+	}
+
+	resetLists(root, reflect.ValueOf(c))
+	if err := sigsyaml.UnmarshalStrict(contents, c); err != nil {
+		return fmt.Errorf("decoding config file %q: %v", configFile, err)
+	}
+	c.recordFileSources(configFile, root)
+	return nil
+}
+
+// resetLists zeroes the lists set in the YAML document, so that they replace
+// the lists of earlier config files. The JSON decoder would otherwise decode
+// into the existing elements of lists of pointers, merging them.
+func resetLists(node *yaml.Node, v reflect.Value) {
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct || node.Kind != yaml.MappingNode || reflect.PtrTo(v.Type()).Implements(jsonUnmarshalerType) {
+		return
+	}
+
+	fields := jsonFields(v.Type())
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		f, ok := fields[node.Content[i].Value]
+		if !ok {
+			continue
+		}
+		fv := v.FieldByIndex(f.Index)
+		if fv.Kind() == reflect.Slice {
+			fv.Set(reflect.Zero(fv.Type()))
+			continue
+		}
+		resetLists(node.Content[i+1], fv)
+	}
+}
+
+// parseYAML returns the root node of the YAML document, or nil if it is empty.
+func parseYAML(contents []byte) (*yaml.Node, error) {
+	var doc yaml.Node
+	if err := yaml.NewDecoder(bytes.NewReader(contents)).Decode(&doc); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if len(doc.Content) == 0 {
+		return nil, nil
+	}
+	return doc.Content[0], nil
+}
+
+// unknownFields reports all mapping keys in the YAML document that do not
+// match a JSON field name of the Go type exactly.
+func unknownFields(configFile string, root *yaml.Node, t reflect.Type) []*ConfigError {
+	errs := []*ConfigError{}
+	walkYAML(root, t, nil, func(path *field.Path, key, value *yaml.Node, t, parent reflect.Type) {
+		if t != nil {
+			return
+		}
+		msg := "unknown field"
+		for name := range jsonFields(parent) {
+			if strings.EqualFold(name, key.Value) {
+				msg = fmt.Sprintf("unknown field, did you mean %q?", name)
+			}
+		}
+		errs = append(errs, &ConfigError{File: configFile, Line: key.Line, Err: field.Forbidden(path, msg)})
+	})
+	return errs
+}
+
+var jsonUnmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+
+// walkYAML walks the YAML node alongside the Go type it is decoded into and
+// calls fn for each mapping key with its field path, the type of its value
+// and the type of the enclosing struct or map. The type is nil for keys that
+// do not match a JSON field name of the enclosing struct exactly.
+func walkYAML(node *yaml.Node, t reflect.Type, path *field.Path, fn func(path *field.Path, key, value *yaml.Node, t, parent reflect.Type)) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if reflect.PtrTo(t).Implements(jsonUnmarshalerType) {
+		return
+	}
+
+	switch {
+	case t.Kind() == reflect.Struct && node.Kind == yaml.MappingNode:
+		fields := jsonFields(t)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			keyPath := childPath(path, key.Value)
+			f, ok := fields[key.Value]
+			if !ok {
+				fn(keyPath, key, value, nil, t)
+				continue
+			}
+			fn(keyPath, key, value, f.Type, t)
+			walkYAML(value, f.Type, keyPath, fn)
+		}
+	case t.Kind() == reflect.Map && node.Kind == yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			keyPath := path.Key(key.Value)
+			fn(keyPath, key, value, t.Elem(), t)
+			walkYAML(value, t.Elem(), keyPath, fn)
+		}
+	case t.Kind() == reflect.Slice && node.Kind == yaml.SequenceNode:
+		for i, item := range node.Content {
+			walkYAML(item, t.Elem(), path.Index(i), fn)
+		}
+	}
+}
+
+func childPath(path *field.Path, name string) *field.Path {
+	if path == nil {
+		return field.NewPath(name)
+	}
+	return path.Child(name)
+}
+
+// jsonFields maps the JSON names of the struct's fields to the fields.
+func jsonFields(t reflect.Type) map[string]reflect.StructField {
+	fields := map[string]reflect.StructField{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name := strings.Split(f.Tag.Get("json"), ",")[0]
+		if name == "-" || !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		fields[name] = f
+	}
+	return fields
+}
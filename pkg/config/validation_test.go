@@ -0,0 +1,268 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+func writeConfigFile(t *testing.T, contents string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(contents), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestValidateConfigFile(t *testing.T) {
+	var tests = []struct {
+		name     string
+		contents string
+		// errors maps the expected line of each error to a substring of it.
+		errors map[int]string
+	}{
+		{
+			name:     "valid",
+			contents: "nodeName: node1\ncluster:\n  clusterCIDR: 10.42.0.0/16\n",
+		},
+		{
+			name:     "empty",
+			contents: "",
+		},
+		{
+			name:     "unknown fields",
+			contents: "nodeName: node1\ncluster:\n  clusterCidr: 10.42.0.0/16\nrouter: {}\n",
+			errors: map[int]string{
+				3: `cluster.clusterCidr: Forbidden: unknown field, did you mean "clusterCIDR"?`,
+				4: "router: Forbidden: unknown field",
+			},
+		},
+		{
+			name: "invalid values",
+			contents: `nodeName: Node_1
+cluster:
+  serviceCIDR: 10.42.0.0/24
+  serviceNodePortRange: 6000-7000
+components:
+  stopTimeouts:
+    etcd: -5s
+`,
+			errors: map[int]string{
+				1: `nodeName: Invalid value: "Node_1"`,
+				3: "must not overlap with clusterCIDR 10.42.0.0/16",
+				4: "must not contain the API server port 6443",
+				7: `components.stopTimeouts[etcd]: Invalid value: "-5s": must not be negative`,
+			},
+		},
+		{
+			name: "invalid storage and network",
+			contents: `storage:
+  lvmd:
+    device-classes:
+    - name: gold
+      volume-group: vg1
+      default: true
+      type: thin
+network:
+  ovn:
+    mtu: 100
+`,
+			errors: map[int]string{
+				3:  "storage.lvmd.device-classes[0].thin-pool: Required value",
+				10: "network.ovn.mtu: Invalid value: 100",
+			},
+		},
+		{
+			name: "invalid certificates",
+			contents: `apiServer:
+  subjectAltNames: [api.example.com, 10.0.0.1, -invalid]
+  namedCertificates:
+  - names:
+    - api.example.com
+    - "*.apps.example.com"
+    - 192.168.1.10
+    - Not_A_Name
+    certPath: /etc/pki/api.crt
+    keyPath: api.key
+certificates:
+  customSigners:
+    admin-kubeconfig-signer:
+      certPath: /etc/pki/ca.crt
+    etcd-signer:
+      certPath: /etc/pki/ca.crt
+      keyPath: /etc/pki/ca.key
+  keyAlgorithm: ECDSA-P521
+  pkcs11:
+    modulePath: libsofthsm2.so
+    pinFile: /etc/microshift/pin
+`,
+			errors: map[int]string{
+				2:  `apiServer.subjectAltNames[2]: Invalid value: "-invalid"`,
+				4:  `apiServer.namedCertificates[0].names[3]: Invalid value: "Not_A_Name"`,
+				10: `apiServer.namedCertificates[0].keyPath: Invalid value: "api.key": must be an absolute path`,
+				13: "certificates.customSigners[admin-kubeconfig-signer].keyPath: Required value",
+				15: `certificates.customSigners[etcd-signer]: Unsupported value: "etcd-signer"`,
+				18: `certificates.keyAlgorithm: Unsupported value: "ECDSA-P521"`,
+				20: `certificates.pkcs11.modulePath: Invalid value: "libsofthsm2.so": must be an absolute path`,
+				0:  "certificates.pkcs11.tokenLabel: Required value",
+			},
+		},
+		{
+			name:     "invalid encryption",
+			contents: "apiServer:\n  encryption:\n    type: aes\n",
+			errors: map[int]string{
+				3: `apiServer.encryption.type: Unsupported value: "aes"`,
+			},
+		},
+		{
+			name: "invalid etcd",
+			contents: `etcd:
+  quotaBackendSize: 16Gi
+  autoCompactionMode: revision
+  autoCompactionRetention: 10m
+  defragmentation:
+    checkInterval: -10m
+    fragmentationThreshold: 100
+`,
+			errors: map[int]string{
+				2: `etcd.quotaBackendSize: Invalid value: "16Gi": must be positive and at most 8Gi`,
+				4: `etcd.autoCompactionRetention: Invalid value: "10m": must be a number of revisions`,
+				6: `etcd.defragmentation.checkInterval: Invalid value: "-10m0s": must not be negative`,
+				7: "etcd.defragmentation.fragmentationThreshold: Invalid value: 100: must be a percentage between 1 and 99",
+			},
+		},
+		{
+			name: "etcd memory limit when embedded",
+			contents: `etcd:
+  memoryLimit: 1Gi
+  goMemoryLimit: 900Mi
+`,
+			errors: map[int]string{
+				2: "etcd.memoryLimit: Forbidden: requires the process mode",
+				3: "etcd.goMemoryLimit: Forbidden: requires the process mode",
+			},
+		},
+		{
+			name: "invalid etcd process",
+			contents: `etcd:
+  mode: process
+  memoryLimit: 64Mi
+  goMemoryLimit: 1Gi
+`,
+			errors: map[int]string{
+				3: `etcd.memoryLimit: Invalid value: "64Mi": must be at least 128Mi`,
+				4: `etcd.goMemoryLimit: Invalid value: "1Gi": must not exceed memoryLimit`,
+			},
+		},
+		{
+			name:     "invalid etcd mode",
+			contents: "etcd:\n  mode: container\n",
+			errors: map[int]string{
+				2: `etcd.mode: Unsupported value: "container"`,
+			},
+		},
+		{
+			name: "invalid backup",
+			contents: `backup:
+  directory: backups
+  interval: -1h
+  maxBackups: 0
+`,
+			errors: map[int]string{
+				2: `backup.directory: Invalid value: "backups": must be an absolute path`,
+				3: `backup.interval: Invalid value: "-1h0m0s": must not be negative`,
+				4: "backup.maxBackups: Invalid value: 0: must be positive",
+			},
+		},
+		{
+			name:     "too small service CIDR",
+			contents: "cluster:\n  serviceCIDR: 10.43.0.0/30\n",
+			errors: map[int]string{
+				2: "must have at least 10 distinct host addresses",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateConfigFiles([]string{writeConfigFile(t, tt.contents)})
+			if len(tt.errors) == 0 {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+
+			var configErrs *ConfigErrors
+			if !errors.As(err, &configErrs) {
+				t.Fatalf("expected ConfigErrors, got %v", err)
+			}
+			if len(configErrs.Errors) != len(tt.errors) {
+				t.Fatalf("expected %d errors, got %v", len(tt.errors), err)
+			}
+			for _, e := range configErrs.Errors {
+				expected, ok := tt.errors[e.Line]
+				if !ok {
+					t.Errorf("unexpected error on line %d: %v", e.Line, e.Err)
+					continue
+				}
+				if !strings.Contains(e.Err.Error(), expected) {
+					t.Errorf("expected error on line %d to contain %q, got %q", e.Line, expected, e.Err.Error())
+				}
+			}
+		})
+	}
+}
+
+func TestValidateConfigFileRepoExamples(t *testing.T) {
+	for _, path := range []string{testConfigFile, "../../packaging/microshift/config.yaml"} {
+		if err := ValidateConfigFiles([]string{path}); err != nil {
+			t.Errorf("%s: %v", path, err)
+		}
+	}
+}
+
+// tests that the result of validating config files does not depend on the
+// fallback config files and environment of the host
+func TestValidateConfigFilesHostIndependent(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "ovn.yaml"), []byte("mtu: 100\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	defer func(dir string) { configDir = dir }(configDir)
+	configDir = dir
+	t.Setenv("MICROSHIFT_CLUSTER_SERVICECIDR", "10.43.0.0/16")
+
+	if err := ValidateConfigFiles([]string{writeConfigFile(t, "nodeName: node1\n")}); err != nil {
+		t.Errorf("expected ovn.yaml to be ignored, got %v", err)
+	}
+
+	err := ValidateConfigFiles([]string{writeConfigFile(t, "cluster:\n  serviceCIDR: 10.43.0.0/30\n")})
+	var configErrs *ConfigErrors
+	if !errors.As(err, &configErrs) || len(configErrs.Errors) != 1 || configErrs.Errors[0].Line != 2 {
+		t.Errorf("expected the service CIDR in the file to be invalid, got %v", err)
+	}
+}
+
+func TestDefaultNodeName(t *testing.T) {
+	c := NewMicroshiftConfig()
+	if c.NodeName != strings.ToLower(c.NodeName) {
+		t.Errorf("expected the default node name to be lowercase, got %q", c.NodeName)
+	}
+	if errs := validation.IsDNS1123Subdomain(c.NodeName); len(errs) > 0 {
+		t.Errorf("expected the default node name to be valid, got %v", errs)
+	}
+}
+
+func TestReadFromConfigFileUnknownField(t *testing.T) {
+	c := NewMicroshiftConfig()
+	err := c.ReadFromConfigFile(writeConfigFile(t, "nodename: node1\n"))
+	if err == nil || !strings.Contains(err.Error(), `config.yaml:1: nodename: Forbidden: unknown field, did you mean "nodeName"?`) {
+		t.Errorf("expected unknown field error, got %v", err)
+	}
+}
@@ -0,0 +1,222 @@
+package configwatch
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/pflag"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/klog/v2"
+
+	"github.com/openshift/microshift/pkg/components"
+	"github.com/openshift/microshift/pkg/config"
+	"github.com/openshift/microshift/pkg/config/lvmd"
+	"github.com/openshift/microshift/pkg/config/ovn"
+	"github.com/openshift/microshift/pkg/kustomize"
+)
+
+// debounceInterval is how long to wait for further changes to the config
+// files before reloading, so a file is not read while it is being written.
+const debounceInterval = 2 * time.Second
+
+// reloadableSetting is a config setting that can be changed without
+// restarting MicroShift. Changes to the value at `path`, or to any value
+// below it, are applied by `apply`.
+type reloadableSetting struct {
+	path  string
+	apply func(cfg *config.MicroshiftConfig) error
+}
+
+var reloadableSettings = []reloadableSetting{
+	{path: "logVLevel", apply: applyLogVLevel},
+	{path: "manifests.kustomizePaths", apply: applyKustomizePaths},
+	{path: "storage.lvmd", apply: components.ReloadCSIPluginConfig},
+	{path: "network.ovn.mtu", apply: components.ReloadCNIPluginConfig},
+	{path: "ingress.routeAdmissionPolicy", apply: components.ReloadIngressRouterConfig},
+}
+
+// ConfigWatchController reloads the config when its files change or
+// MicroShift receives SIGHUP. Settings that can be changed live are applied,
+// changes to all other settings are logged as requiring a restart.
+type ConfigWatchController struct {
+	configDir  string
+	dropInDir  string
+	readConfig func() (*config.MicroshiftConfig, error)
+	settings   []reloadableSetting
+
+	// running is the config MicroShift was started with.
+	running *config.MicroshiftConfig
+	// applied is the config the reloadable settings were last applied from.
+	applied *config.MicroshiftConfig
+}
+
+func NewConfigWatchController(cfg *config.MicroshiftConfig, flags *pflag.FlagSet) *ConfigWatchController {
+	return &ConfigWatchController{
+		configDir: config.GetConfigDir(),
+		dropInDir: config.GetConfigDropInDir(),
+		readConfig: func() (*config.MicroshiftConfig, error) {
+			configFiles, err := config.GetConfigFiles()
+			if err != nil {
+				return nil, err
+			}
+			newCfg := config.NewMicroshiftConfig()
+			if err := newCfg.ReadAndValidate(configFiles, flags); err != nil {
+				return nil, err
+			}
+			return newCfg, nil
+		},
+		settings: reloadableSettings,
+		running:  cfg,
+		applied:  cfg,
+	}
+}
+
+func (c *ConfigWatchController) Name() string           { return "config-watch-controller" }
+func (c *ConfigWatchController) Dependencies() []string { return []string{"kube-apiserver"} }
+
+func (c *ConfigWatchController) Run(ctx context.Context, ready chan<- struct{}, stopped chan<- struct{}) error {
+	defer close(stopped)
+
+	sigHup := make(chan os.Signal, 1)
+	signal.Notify(sigHup, syscall.SIGHUP)
+	defer signal.Stop(sigHup)
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to create config file watcher: %w", err)
+	}
+	defer watcher.Close()
+	for _, dir := range []string{c.configDir, c.dropInDir} {
+		if err := watcher.Add(dir); err != nil {
+			klog.Warningf("Not watching %s for config changes: %v", dir, err)
+		}
+	}
+
+	klog.Infof("%s is ready", c.Name())
+	close(ready)
+
+	var debounce <-chan time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-sigHup:
+			klog.Infof("SIGHUP received, reloading config")
+			c.reload()
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return fmt.Errorf("config file watcher stopped")
+			}
+			if event.Name == c.dropInDir && event.Op&fsnotify.Create != 0 {
+				if err := watcher.Add(c.dropInDir); err != nil {
+					klog.Warningf("Not watching %s for config changes: %v", c.dropInDir, err)
+				}
+			}
+			if c.isConfigFile(event.Name) {
+				debounce = time.After(debounceInterval)
+			}
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return fmt.Errorf("config file watcher stopped")
+			}
+			klog.Warningf("Error watching config files: %v", err)
+		case <-debounce:
+			debounce = nil
+			klog.Infof("Config files changed, reloading config")
+			c.reload()
+		}
+	}
+}
+
+// isConfigFile returns whether the file is read when loading the config.
+func (c *ConfigWatchController) isConfigFile(path string) bool {
+	switch filepath.Dir(path) {
+	case c.configDir:
+		switch filepath.Base(path) {
+		case "config.yaml", lvmd.ConfigFileName, ovn.ConfigFileName:
+			return true
+		}
+	case c.dropInDir:
+		return filepath.Ext(path) == ".yaml"
+	}
+	return false
+}
+
+// reload reads the config and applies the changed reloadable settings. The
+// previous config is kept if the new one is invalid, and settings that failed
+// to apply are retried on the next reload.
+func (c *ConfigWatchController) reload() {
+	newCfg, err := c.readConfig()
+	if err != nil {
+		klog.Errorf("Not reloading config, keeping the current one: %v", err)
+		return
+	}
+
+	restartRequired := []string{}
+	for _, path := range config.Diff(c.running, newCfg) {
+		if c.settingFor(path) == nil {
+			restartRequired = append(restartRequired, path)
+		}
+	}
+	if len(restartRequired) > 0 {
+		klog.Warningf("Changes to %s require a restart of MicroShift to take effect", strings.Join(restartRequired, ", "))
+	}
+
+	changed := map[*reloadableSetting]bool{}
+	for _, path := range config.Diff(c.applied, newCfg) {
+		if setting := c.settingFor(path); setting != nil {
+			changed[setting] = true
+		}
+	}
+	errs := []error{}
+	for i := range c.settings {
+		setting := &c.settings[i]
+		if !changed[setting] {
+			continue
+		}
+		klog.Infof("Applying changes to %s", setting.path)
+		if err := setting.apply(newCfg); err != nil {
+			errs = append(errs, fmt.Errorf("failed to apply changes to %s: %w", setting.path, err))
+		}
+	}
+	if len(errs) > 0 {
+		klog.Errorf("Failed to reload config, retrying on the next change: %v", utilerrors.NewAggregate(errs))
+		return
+	}
+	if len(changed) > 0 {
+		klog.Infof("Reloaded config")
+	}
+	c.applied = newCfg
+}
+
+// settingFor returns the reloadable setting the value at the field path
+// belongs to, or nil if changing it requires a restart.
+func (c *ConfigWatchController) settingFor(path string) *reloadableSetting {
+	for i, setting := range c.settings {
+		if path == setting.path || strings.HasPrefix(path, setting.path+".") || strings.HasPrefix(path, setting.path+"[") {
+			return &c.settings[i]
+		}
+	}
+	return nil
+}
+
+func applyLogVLevel(cfg *config.MicroshiftConfig) error {
+	var level klog.Level
+	return level.Set(strconv.Itoa(cfg.LogVLevel))
+}
+
+func applyKustomizePaths(cfg *config.MicroshiftConfig) error {
+	if !cfg.Components.IsEnabled("kustomizer") {
+		klog.Infof("Not applying kustomize paths, the kustomizer is disabled")
+		return nil
+	}
+	return kustomize.NewKustomizer(cfg).ApplyKustomizationPaths()
+}
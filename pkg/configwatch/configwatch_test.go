@@ -0,0 +1,140 @@
+package configwatch
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/openshift/microshift/pkg/config"
+)
+
+// newTestController returns a controller that reads the configs sent on the
+// returned channel and records the reloadable settings it applies.
+func newTestController(t *testing.T, applyErr *error) (*ConfigWatchController, chan *config.MicroshiftConfig, *[]string) {
+	configs := make(chan *config.MicroshiftConfig, 1)
+	applied := &[]string{}
+	apply := func(path string) func(*config.MicroshiftConfig) error {
+		return func(*config.MicroshiftConfig) error {
+			*applied = append(*applied, path)
+			return *applyErr
+		}
+	}
+	dir := t.TempDir()
+	cfg := config.NewMicroshiftConfig()
+	c := &ConfigWatchController{
+		configDir: dir,
+		dropInDir: filepath.Join(dir, "config.d"),
+		readConfig: func() (*config.MicroshiftConfig, error) {
+			select {
+			case newCfg := <-configs:
+				return newCfg, nil
+			default:
+				return nil, errors.New("no config")
+			}
+		},
+		settings: []reloadableSetting{
+			{path: "logVLevel", apply: apply("logVLevel")},
+			{path: "storage.lvmd", apply: apply("storage.lvmd")},
+			{path: "network.ovn.mtu", apply: apply("network.ovn.mtu")},
+		},
+		running: cfg,
+		applied: cfg,
+	}
+	return c, configs, applied
+}
+
+// tests that only the changed reloadable settings are applied and that
+// failed ones are retried on the next reload
+func TestReload(t *testing.T) {
+	var applyErr error
+	c, configs, applied := newTestController(t, &applyErr)
+
+	newCfg := config.NewMicroshiftConfig()
+	newCfg.LogVLevel = 4
+	newCfg.Network.OVN.MTU = 1300
+	newCfg.Cluster.ServiceCIDR = "10.44.0.0/16"
+	applyErr = errors.New("apiserver unavailable")
+	configs <- newCfg
+	c.reload()
+	if want := []string{"logVLevel", "network.ovn.mtu"}; !reflect.DeepEqual(*applied, want) {
+		t.Errorf("expected %v to be applied, got %v", want, *applied)
+	}
+	if c.applied == newCfg {
+		t.Errorf("expected the config not to be marked as applied after a failure")
+	}
+
+	*applied = nil
+	applyErr = nil
+	configs <- newCfg
+	c.reload()
+	if want := []string{"logVLevel", "network.ovn.mtu"}; !reflect.DeepEqual(*applied, want) {
+		t.Errorf("expected %v to be applied again, got %v", want, *applied)
+	}
+	if c.applied != newCfg {
+		t.Errorf("expected the config to be marked as applied")
+	}
+
+	*applied = nil
+	configs <- newCfg
+	c.reload()
+	if len(*applied) != 0 {
+		t.Errorf("expected nothing to be applied for an unchanged config, got %v", *applied)
+	}
+
+	// invalid configs are not applied
+	c.reload()
+	if len(*applied) != 0 || c.applied != newCfg {
+		t.Errorf("expected the current config to be kept if the new one is invalid")
+	}
+}
+
+func TestIsConfigFile(t *testing.T) {
+	var applyErr error
+	c, _, _ := newTestController(t, &applyErr)
+	for path, want := range map[string]bool{
+		filepath.Join(c.configDir, "config.yaml"):          true,
+		filepath.Join(c.configDir, "lvmd.yaml"):            true,
+		filepath.Join(c.configDir, "ovn.yaml"):             true,
+		filepath.Join(c.configDir, "config.yaml.swp"):      false,
+		filepath.Join(c.configDir, "other.yaml"):           false,
+		filepath.Join(c.dropInDir, "10-storage.yaml"):      true,
+		filepath.Join(c.dropInDir, "10-storage.yaml.orig"): false,
+	} {
+		if got := c.isConfigFile(path); got != want {
+			t.Errorf("isConfigFile(%q) = %v, expected %v", path, got, want)
+		}
+	}
+}
+
+// tests that the config is reloaded on SIGHUP
+func TestRunReloadsOnSIGHUP(t *testing.T) {
+	var applyErr error
+	c, configs, applied := newTestController(t, &applyErr)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ready, stopped := make(chan struct{}), make(chan struct{})
+	go c.Run(ctx, ready, stopped)
+	<-ready
+
+	newCfg := config.NewMicroshiftConfig()
+	newCfg.LogVLevel = 2
+	configs <- newCfg
+	if err := syscall.Kill(syscall.Getpid(), syscall.SIGHUP); err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(configs) > 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	cancel()
+	<-stopped
+	if want := []string{"logVLevel"}; !reflect.DeepEqual(*applied, want) {
+		t.Errorf("expected %v to be applied, got %v", want, *applied)
+	}
+}
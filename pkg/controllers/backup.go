@@ -0,0 +1,102 @@
+package controllers
+
+import (
+	"context"
+	"time"
+
+	klog "k8s.io/klog/v2"
+
+	"github.com/openshift/microshift/pkg/backup"
+	"github.com/openshift/microshift/pkg/config"
+	"github.com/openshift/microshift/pkg/util"
+	"github.com/openshift/microshift/pkg/util/cryptomaterial"
+)
+
+const backupRetryInterval = 10 * time.Minute
+
+// BackupController takes a backup every backup.interval and removes the
+// oldest scheduled backups beyond backup.maxBackups. It does nothing if the
+// interval is zero.
+type BackupController struct {
+	cfg *config.MicroshiftConfig
+}
+
+func NewBackupController(cfg *config.MicroshiftConfig) *BackupController {
+	return &BackupController{cfg: cfg}
+}
+
+func (c *BackupController) Name() string           { return "backup-controller" }
+func (c *BackupController) Dependencies() []string { return []string{"etcd"} }
+
+func (c *BackupController) Run(ctx context.Context, ready chan<- struct{}, stopped chan<- struct{}) error {
+	defer close(stopped)
+	klog.Infof("%s is ready", c.Name())
+	close(ready)
+
+	interval := c.cfg.Backup.Interval.Duration
+	if interval == 0 {
+		klog.Infof("Scheduled backups are disabled")
+		<-ctx.Done()
+		return ctx.Err()
+	}
+
+	// continue the schedule of the previous runs of MicroShift
+	next := time.Now()
+	if last := c.lastScheduledBackup(); last != nil {
+		next = last.CreatedAt.Add(interval)
+	}
+	for {
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+
+		if err := c.backup(ctx); err != nil {
+			retry := backupRetryInterval
+			if interval < retry {
+				retry = interval
+			}
+			klog.Errorf("Failed to back up MicroShift, retrying in %s: %v", retry, err)
+			next = time.Now().Add(retry)
+			continue
+		}
+		next = time.Now().Add(interval)
+	}
+}
+
+func (c *BackupController) lastScheduledBackup() *backup.Backup {
+	backups, err := backup.List(c.cfg.Backup.Directory)
+	if err != nil {
+		klog.Warningf("Failed to list the backups in %s: %v", c.cfg.Backup.Directory, err)
+		return nil
+	}
+	for _, b := range backups {
+		if b.Scheduled {
+			return b
+		}
+	}
+	return nil
+}
+
+func (c *BackupController) backup(ctx context.Context) error {
+	client, err := util.NewEtcdClient(cryptomaterial.CertsDirectory(microshiftDataDir))
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	b, err := backup.Create(ctx, client, microshiftDataDir, c.cfg.Backup.Directory, true)
+	if err != nil {
+		return err
+	}
+	klog.Infof("Backed up MicroShift to %s", b.Path)
+
+	removed, err := backup.Prune(c.cfg.Backup.Directory, c.cfg.Backup.MaxBackups)
+	for _, b := range removed {
+		klog.Infof("Removed backup %s", b.Path)
+	}
+	return err
+}
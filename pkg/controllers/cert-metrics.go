@@ -0,0 +1,58 @@
+package controllers
+
+import (
+	"crypto/x509"
+	"strings"
+	"sync"
+
+	"k8s.io/component-base/metrics"
+	"k8s.io/component-base/metrics/legacyregistry"
+
+	"github.com/openshift/microshift/pkg/util/cryptomaterial/certchains"
+)
+
+// The certificate metrics are served by kube-apiserver at /metrics, which
+// serves the legacy registry of the process.
+var (
+	certificateExpirationTimestamp = metrics.NewGaugeVec(
+		&metrics.GaugeOpts{
+			Namespace:      "microshift",
+			Subsystem:      "certificate",
+			Name:           "expiration_timestamp_seconds",
+			Help:           "Time the certificate expires at in seconds since the epoch, by path of the certificate in its chain.",
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{"path"},
+	)
+	certificateRotationTimestamp = metrics.NewGaugeVec(
+		&metrics.GaugeOpts{
+			Namespace:      "microshift",
+			Subsystem:      "certificate",
+			Name:           "rotation_timestamp_seconds",
+			Help:           "Time the certificate is due for rotation at in seconds since the epoch, by path of the certificate in its chain.",
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{"path"},
+	)
+
+	registerCertificateMetricsOnce sync.Once
+)
+
+func registerCertificateMetrics() {
+	registerCertificateMetricsOnce.Do(func() {
+		legacyregistry.MustRegister(certificateExpirationTimestamp, certificateRotationTimestamp)
+	})
+}
+
+// updateCertificateMetrics sets the metrics of all certificates in the
+// chains, dropping the ones of certificates that no longer exist.
+func updateCertificateMetrics(cs *certchains.CertificateChains) error {
+	certificateExpirationTimestamp.Reset()
+	certificateRotationTimestamp.Reset()
+	return cs.WalkChains(nil, func(certPath []string, cert x509.Certificate) error {
+		path := strings.Join(certPath, "/")
+		certificateExpirationTimestamp.WithLabelValues(path).Set(float64(cert.NotAfter.Unix()))
+		certificateRotationTimestamp.WithLabelValues(path).Set(float64(certchains.WhenToRotate(&cert).Unix()))
+		return nil
+	})
+}
@@ -0,0 +1,250 @@
+package controllers
+
+import (
+	"context"
+	"crypto/x509"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/tools/record"
+	klog "k8s.io/klog/v2"
+
+	"github.com/openshift/microshift/pkg/components"
+	"github.com/openshift/microshift/pkg/config"
+	"github.com/openshift/microshift/pkg/servicemanager"
+	"github.com/openshift/microshift/pkg/util/cryptomaterial/certchains"
+)
+
+const (
+	certRotationCheckInterval = time.Hour
+	// nodeLookupTimeout bounds looking up the node the events are recorded
+	// on.
+	nodeLookupTimeout = 10 * time.Second
+)
+
+// reloadFunc makes the users of a rotated certificate pick it up.
+type reloadFunc func(cfg *config.MicroshiftConfig, cs *certchains.CertificateChains) error
+
+// liveRotatedCerts are the short-lived leaf certificates that can be rotated
+// without restarting MicroShift, with the function that makes their users
+// pick up the new certificate. Certificates without a function are reloaded
+// from disk by their users: kube-apiserver reloads its serving and client
+// certificates, and the kubeconfigs of kube-controller-manager,
+// kube-scheduler and the kubelet reference their client certificate files.
+var liveRotatedCerts = map[string]reloadFunc{
+	"kube-control-plane-signer/kube-controller-manager":                            nil,
+	"kube-control-plane-signer/kube-scheduler":                                     nil,
+	"kube-apiserver-to-kubelet-signer/kube-apiserver-to-kubelet-client":            nil,
+	"kubelet-signer/kube-csr-signer/kubelet-client":                                nil,
+	"aggregator-signer/aggregator-client":                                          nil,
+	"service-ca/route-controller-manager-serving":                                  nil,
+	"kube-apiserver-external-signer/kube-external-serving":                         nil,
+	"kube-apiserver-localhost-signer/kube-apiserver-localhost-serving":             nil,
+	"kube-apiserver-service-network-signer/kube-apiserver-service-network-serving": nil,
+	"ingress-ca/router-default-serving":                                            reloadRouterServingCertificate,
+}
+
+func reloadRouterServingCertificate(cfg *config.MicroshiftConfig, cs *certchains.CertificateChains) error {
+	certPEM, keyPEM, err := cs.GetCertKey("ingress-ca", "router-default-serving")
+	if err != nil {
+		return err
+	}
+	return components.ReloadIngressRouterServingCertificate(cfg, certPEM, keyPEM)
+}
+
+// CertRotationController periodically checks the certificates for rotation.
+// Certificates in liveRotatedCerts are rotated in place, MicroShift is
+// restarted to rotate any other certificate, e.g. signers. It exports the
+// expiration and rotation times of the certificates as metrics, and records
+// events on the node about the certificates it rotates or that expire.
+type CertRotationController struct {
+	cfg              *config.MicroshiftConfig
+	certChains       *certchains.CertificateChains
+	writeKubeconfigs func() error
+	restarter        servicemanager.RestartRequester
+
+	liveCerts map[string]reloadFunc
+	now       func() time.Time
+	// pendingReloads are the rotated certificates whose users still need to
+	// pick them up.
+	pendingReloads map[string]bool
+	// recorder records the events, none are recorded if it is nil.
+	recorder record.EventRecorder
+	// nodes looks up the node the events are recorded on.
+	nodes typedcorev1.NodeInterface
+	// nodeUID is the UID of the node once it was looked up.
+	nodeUID types.UID
+}
+
+func NewCertRotationController(
+	cfg *config.MicroshiftConfig,
+	certChains *certchains.CertificateChains,
+	writeKubeconfigs func() error,
+	restarter servicemanager.RestartRequester,
+) *CertRotationController {
+	registerCertificateMetrics()
+	return &CertRotationController{
+		cfg:              cfg,
+		certChains:       certChains,
+		writeKubeconfigs: writeKubeconfigs,
+		restarter:        restarter,
+		liveCerts:        liveRotatedCerts,
+		now:              time.Now,
+		pendingReloads:   map[string]bool{},
+	}
+}
+
+func (c *CertRotationController) Name() string           { return "cert-rotation-controller" }
+func (c *CertRotationController) Dependencies() []string { return []string{"kube-apiserver"} }
+
+func (c *CertRotationController) Run(ctx context.Context, ready chan<- struct{}, stopped chan<- struct{}) error {
+	defer close(stopped)
+	ticker := time.NewTicker(certRotationCheckInterval)
+	defer ticker.Stop()
+
+	broadcaster, err := c.startRecordingEvents()
+	if err != nil {
+		return err
+	}
+	defer broadcaster.Shutdown()
+
+	klog.Infof("%s is ready", c.Name())
+	close(ready)
+
+	for {
+		if err := c.rotate(); err != nil {
+			klog.Errorf("Failed to rotate certificates, retrying in %s: %v", certRotationCheckInterval, err)
+			c.event(corev1.EventTypeWarning, "CertificateRotationFailed", "Failed to rotate certificates: %v", err)
+		}
+		if err := updateCertificateMetrics(c.certChains); err != nil {
+			klog.Errorf("Failed to update the certificate metrics: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// rotate regenerates the certificates that are due for rotation and makes
+// their users pick them up. If any of them cannot be rotated live, a restart
+// of MicroShift is requested instead, which rotates them on startup.
+func (c *CertRotationController) rotate() error {
+	now := c.now()
+	live, restartRequired := [][]string{}, []string{}
+	err := c.certChains.WalkChains(nil, func(certPath []string, cert x509.Certificate) error {
+		if now.Before(certchains.WhenToRotate(&cert)) {
+			return nil
+		}
+		path := strings.Join(certPath, "/")
+		if c.certChains.IsCustomSigner(certPath...) {
+			klog.Warningf("Custom CA %s expires at %s, replace it", path, cert.NotAfter)
+			c.event(corev1.EventTypeWarning, "CertificateExpiring", "Custom CA %s expires at %s, replace it", path, cert.NotAfter)
+			return nil
+		}
+		if _, ok := c.liveCerts[path]; ok {
+			live = append(live, append([]string{}, certPath...))
+		} else {
+			restartRequired = append(restartRequired, path)
+			c.event(corev1.EventTypeWarning, "CertificateExpiring", "Certificate %s expires at %s, restarting MicroShift to rotate it", path, cert.NotAfter)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(restartRequired) > 0 {
+		c.restarter.RequestRestart(servicemanager.RestartReasonCertificateRotation,
+			fmt.Sprintf("certificates %s are due for rotation", strings.Join(restartRequired, ", ")))
+		return nil
+	}
+
+	for _, certPath := range live {
+		path := strings.Join(certPath, "/")
+		klog.Infof("Rotating certificate %s", path)
+		if err := c.certChains.Regenerate(certPath...); err != nil {
+			return fmt.Errorf("failed to rotate %s: %w", path, err)
+		}
+		c.pendingReloads[path] = true
+	}
+	if len(live) > 0 {
+		if err := c.writeKubeconfigs(); err != nil {
+			return fmt.Errorf("failed to update the kubeconfigs: %w", err)
+		}
+	}
+
+	pending := make([]string, 0, len(c.pendingReloads))
+	for path := range c.pendingReloads {
+		pending = append(pending, path)
+	}
+	sort.Strings(pending)
+	for _, path := range pending {
+		if reload := c.liveCerts[path]; reload != nil {
+			if err := reload(c.cfg, c.certChains); err != nil {
+				return fmt.Errorf("failed to reload certificate %s: %w", path, err)
+			}
+		}
+		delete(c.pendingReloads, path)
+		klog.Infof("Rotated certificate %s", path)
+		c.event(corev1.EventTypeNormal, "CertificateRotated", "Rotated certificate %s", path)
+	}
+	return nil
+}
+
+// startRecordingEvents records the events of the controller with the admin
+// kubeconfig, unless a recorder was set already.
+func (c *CertRotationController) startRecordingEvents() (record.EventBroadcaster, error) {
+	broadcaster := record.NewBroadcaster()
+	if c.recorder != nil {
+		return broadcaster, nil
+	}
+
+	restConfig, err := clientcmd.BuildConfigFromFlags("", c.cfg.KubeConfigPath(config.KubeAdmin))
+	if err != nil {
+		return nil, err
+	}
+	client, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		return nil, err
+	}
+	c.nodes = client.CoreV1().Nodes()
+	broadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: client.CoreV1().Events("")})
+	c.recorder = broadcaster.NewRecorder(scheme.Scheme, corev1.EventSource{Component: "microshift", Host: c.cfg.NodeName})
+	return broadcaster, nil
+}
+
+// event records an event on the node, which is recorded in the default
+// namespace like the kubelet's events for the node.
+func (c *CertRotationController) event(eventType, reason, messageFmt string, args ...interface{}) {
+	if c.recorder == nil {
+		return
+	}
+	c.recorder.Eventf(c.nodeReference(), eventType, reason, messageFmt, args...)
+}
+
+// nodeReference returns the reference to the node, without its UID if the
+// node is not registered yet. The UID is looked up until it is known.
+func (c *CertRotationController) nodeReference() *corev1.ObjectReference {
+	if c.nodeUID == "" && c.nodes != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), nodeLookupTimeout)
+		defer cancel()
+		if n, err := c.nodes.Get(ctx, c.cfg.NodeName, metav1.GetOptions{}); err == nil {
+			c.nodeUID = n.UID
+		} else {
+			klog.V(2).Infof("Failed to look up node %s to record events on it: %v", c.cfg.NodeName, err)
+		}
+	}
+	return &corev1.ObjectReference{Kind: "Node", Name: c.cfg.NodeName, UID: c.nodeUID}
+}
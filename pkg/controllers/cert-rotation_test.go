@@ -0,0 +1,164 @@
+package controllers
+
+import (
+	"crypto/x509"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/component-base/metrics/testutil"
+
+	"github.com/openshift/microshift/pkg/config"
+	"github.com/openshift/microshift/pkg/servicemanager"
+	"github.com/openshift/microshift/pkg/util/cryptomaterial/certchains"
+)
+
+var errReloadFailed = errors.New("reload failed")
+
+type fakeRestarter struct {
+	reasons []servicemanager.RestartReason
+}
+
+func (r *fakeRestarter) RequestRestart(reason servicemanager.RestartReason, message string) {
+	r.reasons = append(r.reasons, reason)
+}
+
+// newTestCertRotationController returns a controller for a signer valid for
+// a year with a client certificate valid for 30 days, which is due for
+// rotation right away.
+func newTestCertRotationController(t *testing.T, liveCerts map[string]reloadFunc) (*CertRotationController, *fakeRestarter, *int) {
+	chains, err := certchains.NewCertificateChains(
+		certchains.NewCertificateSigner("signer", t.TempDir(), 365).
+			WithClientCertificates(&certchains.ClientCertificateSigningRequestInfo{
+				CSRMeta:  certchains.CSRMeta{Name: "client", ValidityDays: 30},
+				UserInfo: &user.DefaultInfo{Name: "client"},
+			}),
+	).Complete()
+	require.NoError(t, err)
+
+	restarter := &fakeRestarter{}
+	kubeconfigWrites := 0
+	c := NewCertRotationController(config.NewMicroshiftConfig(), chains, func() error {
+		kubeconfigWrites++
+		return nil
+	}, restarter)
+	c.liveCerts = liveCerts
+	c.recorder = record.NewFakeRecorder(10)
+	return c, restarter, &kubeconfigWrites
+}
+
+func requireEvents(t *testing.T, c *CertRotationController, events ...string) {
+	t.Helper()
+	var recorded []string
+	for len(c.recorder.(*record.FakeRecorder).Events) > 0 {
+		recorded = append(recorded, <-c.recorder.(*record.FakeRecorder).Events)
+	}
+	require.Equal(t, events, recorded)
+}
+
+func TestCertRotationControllerLive(t *testing.T) {
+	reloads := 0
+	c, restarter, kubeconfigWrites := newTestCertRotationController(t, map[string]reloadFunc{
+		"signer/client": func(*config.MicroshiftConfig, *certchains.CertificateChains) error {
+			reloads++
+			return nil
+		},
+	})
+	before, _, err := c.certChains.GetCertKey("signer", "client")
+	require.NoError(t, err)
+
+	require.NoError(t, c.rotate())
+	after, _, err := c.certChains.GetCertKey("signer", "client")
+	require.NoError(t, err)
+	require.NotEqual(t, before, after, "the certificate was not rotated")
+	require.Equal(t, 1, *kubeconfigWrites)
+	require.Equal(t, 1, reloads)
+	require.Empty(t, restarter.reasons)
+	require.Empty(t, c.pendingReloads)
+	requireEvents(t, c, "Normal CertificateRotated Rotated certificate signer/client")
+}
+
+func TestCertRotationControllerRetriesReload(t *testing.T) {
+	var reloadErr error = errReloadFailed
+	c, _, _ := newTestCertRotationController(t, map[string]reloadFunc{
+		"signer/client": func(*config.MicroshiftConfig, *certchains.CertificateChains) error {
+			return reloadErr
+		},
+	})
+
+	require.ErrorIs(t, c.rotate(), errReloadFailed)
+	require.Equal(t, map[string]bool{"signer/client": true}, c.pendingReloads)
+
+	// nothing is due anymore, but the reload is retried
+	reloadErr = nil
+	c.now = func() time.Time { return time.Now().Add(-365 * 24 * time.Hour) }
+	require.NoError(t, c.rotate())
+	require.Empty(t, c.pendingReloads)
+}
+
+func TestCertRotationControllerRestart(t *testing.T) {
+	c, restarter, kubeconfigWrites := newTestCertRotationController(t, map[string]reloadFunc{})
+	before, _, err := c.certChains.GetCertKey("signer", "client")
+	require.NoError(t, err)
+
+	require.NoError(t, c.rotate())
+	after, _, err := c.certChains.GetCertKey("signer", "client")
+	require.NoError(t, err)
+	require.Equal(t, before, after, "certificates must be rotated on restart")
+	require.Equal(t, 0, *kubeconfigWrites)
+	require.Equal(t, []servicemanager.RestartReason{servicemanager.RestartReasonCertificateRotation}, restarter.reasons)
+
+	events := c.recorder.(*record.FakeRecorder).Events
+	require.Len(t, events, 1)
+	require.Contains(t, <-events, "Warning CertificateExpiring Certificate signer/client expires at")
+}
+
+func TestCertRotationControllerNothingDue(t *testing.T) {
+	c, restarter, kubeconfigWrites := newTestCertRotationController(t, map[string]reloadFunc{"signer/client": nil})
+	c.now = func() time.Time { return time.Now().Add(-365 * 24 * time.Hour) }
+
+	require.NoError(t, c.rotate())
+	require.Equal(t, 0, *kubeconfigWrites)
+	require.Empty(t, restarter.reasons)
+	requireEvents(t, c)
+}
+
+func TestCertRotationControllerNodeReference(t *testing.T) {
+	c, _, _ := newTestCertRotationController(t, nil)
+	c.cfg.NodeName = "node"
+	c.nodes = fake.NewSimpleClientset().CoreV1().Nodes()
+	require.Equal(t, &corev1.ObjectReference{Kind: "Node", Name: "node"}, c.nodeReference())
+
+	client := fake.NewSimpleClientset(&corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "node", UID: "4a2b3c4d"},
+	})
+	c.nodes = client.CoreV1().Nodes()
+	require.Equal(t, &corev1.ObjectReference{Kind: "Node", Name: "node", UID: "4a2b3c4d"}, c.nodeReference())
+
+	// the UID is only looked up once
+	require.Equal(t, &corev1.ObjectReference{Kind: "Node", Name: "node", UID: "4a2b3c4d"}, c.nodeReference())
+	require.Len(t, client.Actions(), 1)
+}
+
+func TestUpdateCertificateMetrics(t *testing.T) {
+	c, _, _ := newTestCertRotationController(t, nil)
+	require.NoError(t, updateCertificateMetrics(c.certChains))
+
+	require.NoError(t, c.certChains.WalkChains(nil, func(certPath []string, cert x509.Certificate) error {
+		path := strings.Join(certPath, "/")
+		expiration, err := testutil.GetGaugeMetricValue(certificateExpirationTimestamp.WithLabelValues(path))
+		require.NoError(t, err)
+		require.Equal(t, float64(cert.NotAfter.Unix()), expiration, path)
+		rotation, err := testutil.GetGaugeMetricValue(certificateRotationTimestamp.WithLabelValues(path))
+		require.NoError(t, err)
+		require.Equal(t, float64(certchains.WhenToRotate(&cert).Unix()), rotation, path)
+		return nil
+	}))
+}
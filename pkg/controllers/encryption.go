@@ -0,0 +1,154 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+	klog "k8s.io/klog/v2"
+
+	"github.com/openshift/microshift/pkg/config"
+	"github.com/openshift/microshift/pkg/encryption"
+)
+
+const (
+	encryptionRetryInterval = time.Minute
+	encryptionListLimit     = 500
+)
+
+// EncryptionController rewrites the encrypted resources after the write key
+// of the encryption configuration changed, so that they are encrypted with
+// it. The keys that are no longer used are removed on the next start.
+type EncryptionController struct {
+	cfg *config.MicroshiftConfig
+	// client is created from the admin kubeconfig if it is nil
+	client kubernetes.Interface
+}
+
+func NewEncryptionController(cfg *config.MicroshiftConfig) *EncryptionController {
+	return &EncryptionController{cfg: cfg}
+}
+
+func (c *EncryptionController) Name() string           { return "encryption-controller" }
+func (c *EncryptionController) Dependencies() []string { return []string{"kube-apiserver"} }
+
+func (c *EncryptionController) Run(ctx context.Context, ready chan<- struct{}, stopped chan<- struct{}) error {
+	defer close(stopped)
+
+	if c.client == nil {
+		restConfig, err := clientcmd.BuildConfigFromFlags("", c.cfg.KubeConfigPath(config.KubeAdmin))
+		if err != nil {
+			return err
+		}
+		c.client, err = kubernetes.NewForConfig(restConfig)
+		if err != nil {
+			return err
+		}
+	}
+
+	klog.Infof("%s is ready", c.Name())
+	close(ready)
+
+	err := wait.PollImmediateInfiniteWithContext(ctx, encryptionRetryInterval, func(ctx context.Context) (bool, error) {
+		if err := c.migrate(ctx); err != nil {
+			klog.Errorf("Failed to rewrite the encrypted resources, retrying in %s: %v", encryptionRetryInterval, err)
+			return false, nil
+		}
+		return true, nil
+	})
+	if err != nil {
+		return err
+	}
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+// migrate rewrites all encrypted resources if the write key changed since
+// they were last rewritten.
+func (c *EncryptionController) migrate(ctx context.Context) error {
+	state, err := encryption.Load(encryption.Dir(microshiftDataDir))
+	if err != nil {
+		return err
+	}
+	if !state.MigrationPending() {
+		return nil
+	}
+
+	key := state.WriteKey()
+	klog.Infof("Rewriting %v with encryption key %s", encryption.Resources, key.ID())
+	secretsClient := c.client.CoreV1().Secrets
+	secrets, err := rewrite(ctx,
+		func(ctx context.Context, opts metav1.ListOptions) (runtime.Object, error) {
+			return secretsClient(metav1.NamespaceAll).List(ctx, opts)
+		},
+		func(ctx context.Context, obj runtime.Object) error {
+			secret := obj.(*corev1.Secret)
+			_, err := secretsClient(secret.Namespace).Update(ctx, secret, metav1.UpdateOptions{})
+			return err
+		})
+	if err != nil {
+		return fmt.Errorf("failed to rewrite secrets: %w", err)
+	}
+	configMapsClient := c.client.CoreV1().ConfigMaps
+	configMaps, err := rewrite(ctx,
+		func(ctx context.Context, opts metav1.ListOptions) (runtime.Object, error) {
+			return configMapsClient(metav1.NamespaceAll).List(ctx, opts)
+		},
+		func(ctx context.Context, obj runtime.Object) error {
+			configMap := obj.(*corev1.ConfigMap)
+			_, err := configMapsClient(configMap.Namespace).Update(ctx, configMap, metav1.UpdateOptions{})
+			return err
+		})
+	if err != nil {
+		return fmt.Errorf("failed to rewrite configmaps: %w", err)
+	}
+	if err := state.SetMigrated(key); err != nil {
+		return err
+	}
+	klog.Infof("Rewrote %d secrets and %d configmaps with encryption key %s, unused keys are removed on the next start",
+		secrets, configMaps, key.ID())
+	return nil
+}
+
+// rewrite updates all objects returned by the pages of list without changes
+// and returns how many it updated. The API server stores objects again on
+// updates without changes if they were not encrypted with its write key.
+// Objects that were changed or deleted meanwhile were already written with it.
+func rewrite(ctx context.Context,
+	list func(context.Context, metav1.ListOptions) (runtime.Object, error),
+	update func(context.Context, runtime.Object) error,
+) (int, error) {
+	count := 0
+	opts := metav1.ListOptions{Limit: encryptionListLimit}
+	for {
+		page, err := list(ctx, opts)
+		if err != nil {
+			return count, err
+		}
+		objects, err := meta.ExtractList(page)
+		if err != nil {
+			return count, err
+		}
+		for _, obj := range objects {
+			if err := update(ctx, obj); err != nil && !apierrors.IsConflict(err) && !apierrors.IsNotFound(err) {
+				return count, err
+			}
+			count++
+		}
+		listMeta, err := meta.ListAccessor(page)
+		if err != nil {
+			return count, err
+		}
+		if opts.Continue = listMeta.GetContinue(); opts.Continue == "" {
+			return count, nil
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/openshift/microshift/pkg/config"
+	"github.com/openshift/microshift/pkg/encryption"
+)
+
+func TestEncryptionControllerMigrate(t *testing.T) {
+	dataDir := microshiftDataDir
+	microshiftDataDir = t.TempDir()
+	t.Cleanup(func() { microshiftDataDir = dataDir })
+
+	state, err := encryption.Load(encryption.Dir(microshiftDataDir))
+	require.NoError(t, err)
+	_, err = state.Ensure(encryption.AESGCM)
+	require.NoError(t, err)
+	require.NoError(t, state.Write())
+
+	client := fake.NewSimpleClientset(
+		&corev1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "a"}},
+		&corev1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "kube-system", Name: "b"}},
+		&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "c"}},
+	)
+	c := NewEncryptionController(config.NewMicroshiftConfig())
+	c.client = client
+
+	require.NoError(t, c.migrate(context.Background()))
+	updated := []string{}
+	for _, action := range client.Actions() {
+		if action.GetVerb() == "update" {
+			updated = append(updated, action.GetResource().Resource+"/"+action.GetNamespace())
+		}
+	}
+	require.ElementsMatch(t, []string{"secrets/default", "secrets/kube-system", "configmaps/default"}, updated)
+
+	state, err = encryption.Load(encryption.Dir(microshiftDataDir))
+	require.NoError(t, err)
+	require.False(t, state.MigrationPending())
+
+	// nothing is rewritten once the migration completed
+	client.ClearActions()
+	require.NoError(t, c.migrate(context.Background()))
+	require.Empty(t, client.Actions())
+}
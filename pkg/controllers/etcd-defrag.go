@@ -0,0 +1,169 @@
+package controllers
+
+import (
+	"context"
+	"time"
+
+	"go.etcd.io/etcd/api/v3/etcdserverpb"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	klog "k8s.io/klog/v2"
+
+	"github.com/openshift/microshift/pkg/config"
+	"github.com/openshift/microshift/pkg/util"
+	"github.com/openshift/microshift/pkg/util/cryptomaterial"
+)
+
+const (
+	// defragMinDBSize is the size below which the database is never
+	// defragmented, as there is little space to reclaim.
+	defragMinDBSize = 100 * 1024 * 1024
+	// defragMaxWriteRate is the rate of revisions per second since the last
+	// check above which defragmentation is postponed. Defragmenting blocks
+	// etcd, it should happen when few writes are waiting for it.
+	defragMaxWriteRate = 10
+	// defragForcedQuotaPercentage is the percentage of the quota above
+	// which the database is defragmented regardless of the write rate.
+	defragForcedQuotaPercentage = 80
+)
+
+// EtcdDefragController periodically checks the fragmentation of etcd's
+// database and defragments it when the unused share of the database exceeds
+// etcd.defragmentation.fragmentationThreshold while few writes are happening.
+// It disarms the NOSPACE alarm once the database fits in its quota again, so
+// that etcd accepts writes again.
+type EtcdDefragController struct {
+	cfg         *config.MicroshiftConfig
+	maintenance clientv3.Maintenance
+	now         func() time.Time
+
+	// lastRevision is the revision of the database at lastCheck
+	lastRevision int64
+	lastCheck    time.Time
+}
+
+func NewEtcdDefragController(cfg *config.MicroshiftConfig) *EtcdDefragController {
+	return &EtcdDefragController{cfg: cfg, now: time.Now}
+}
+
+func (c *EtcdDefragController) Name() string           { return "etcd-defrag-controller" }
+func (c *EtcdDefragController) Dependencies() []string { return []string{"etcd"} }
+
+func (c *EtcdDefragController) Run(ctx context.Context, ready chan<- struct{}, stopped chan<- struct{}) error {
+	defer close(stopped)
+
+	interval := c.cfg.Etcd.Defragmentation.CheckInterval.Duration
+	if interval == 0 {
+		klog.Infof("%s is ready", c.Name())
+		close(ready)
+		klog.Infof("Defragmentation of etcd is disabled")
+		<-ctx.Done()
+		return ctx.Err()
+	}
+
+	client, err := util.NewEtcdClient(cryptomaterial.CertsDirectory(microshiftDataDir))
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	c.maintenance = client
+
+	klog.Infof("%s is ready", c.Name())
+	close(ready)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+
+		if err := c.check(ctx); err != nil {
+			klog.Errorf("Failed to check the fragmentation of etcd, retrying in %s: %v", interval, err)
+		}
+	}
+}
+
+// check defragments the database if it is fragmented, or if it exceeded its
+// quota, and disarms the NOSPACE alarm if it fits in the quota afterwards.
+func (c *EtcdDefragController) check(ctx context.Context) error {
+	status, err := c.maintenance.Status(ctx, util.EtcdEndpoint)
+	if err != nil {
+		return err
+	}
+	alarms, err := c.noSpaceAlarms(ctx)
+	if err != nil {
+		return err
+	}
+
+	// the write rate is unknown until the second check, which postpones the
+	// defragmentation like a high write rate
+	now := c.now()
+	busy := true
+	if !c.lastCheck.IsZero() {
+		busy = float64(status.Header.Revision-c.lastRevision)/now.Sub(c.lastCheck).Seconds() > defragMaxWriteRate
+	}
+	c.lastRevision, c.lastCheck = status.Header.Revision, now
+
+	quota := c.cfg.Etcd.QuotaBackendBytes()
+	unused := status.DbSize - status.DbSizeInUse
+	fragmented := status.DbSize >= defragMinDBSize &&
+		unused*100 >= status.DbSize*int64(c.cfg.Etcd.Defragmentation.FragmentationThreshold)
+	nearQuota := status.DbSize*100 >= quota*defragForcedQuotaPercentage
+	switch {
+	case len(alarms) > 0 && unused > 0:
+		klog.Warningf("etcd's database of %d bytes exceeded its quota of %d bytes, defragmenting it", status.DbSize, quota)
+	case !fragmented:
+		klog.V(2).Infof("etcd's database of %d bytes has %d unused bytes, not defragmenting it", status.DbSize, unused)
+		return c.disarm(ctx, alarms, status.DbSize, quota)
+	case nearQuota:
+		klog.Infof("etcd's database of %d bytes is close to its quota of %d bytes, defragmenting it", status.DbSize, quota)
+	case busy:
+		klog.Infof("Postponing the defragmentation of etcd's database with %d unused bytes until writes slow down", unused)
+		return nil
+	}
+
+	start := c.now()
+	if _, err := c.maintenance.Defragment(ctx, util.EtcdEndpoint); err != nil {
+		return err
+	}
+	status, err = c.maintenance.Status(ctx, util.EtcdEndpoint)
+	if err != nil {
+		return err
+	}
+	klog.Infof("Defragmented etcd's database in %s, its size is %d bytes", c.now().Sub(start), status.DbSize)
+	return c.disarm(ctx, alarms, status.DbSize, quota)
+}
+
+func (c *EtcdDefragController) noSpaceAlarms(ctx context.Context) ([]*etcdserverpb.AlarmMember, error) {
+	resp, err := c.maintenance.AlarmList(ctx)
+	if err != nil {
+		return nil, err
+	}
+	alarms := []*etcdserverpb.AlarmMember{}
+	for _, alarm := range resp.Alarms {
+		if alarm.Alarm == etcdserverpb.AlarmType_NOSPACE {
+			alarms = append(alarms, alarm)
+		}
+	}
+	return alarms, nil
+}
+
+// disarm disarms the NOSPACE alarms if the database fits in its quota.
+func (c *EtcdDefragController) disarm(ctx context.Context, alarms []*etcdserverpb.AlarmMember, dbSize, quota int64) error {
+	if len(alarms) == 0 {
+		return nil
+	}
+	if dbSize >= quota {
+		klog.Warningf("etcd's database of %d bytes still exceeds its quota of %d bytes, delete resources to free space", dbSize, quota)
+		return nil
+	}
+	for _, alarm := range alarms {
+		if _, err := c.maintenance.AlarmDisarm(ctx, (*clientv3.AlarmMember)(alarm)); err != nil {
+			return err
+		}
+	}
+	klog.Infof("Disarmed etcd's NOSPACE alarm, its database of %d bytes fits in its quota of %d bytes again", dbSize, quota)
+	return nil
+}
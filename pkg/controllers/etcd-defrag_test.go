@@ -0,0 +1,124 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.etcd.io/etcd/api/v3/etcdserverpb"
+	clientv3 "go.etcd.io/etcd/client/v3"
+
+	"github.com/openshift/microshift/pkg/config"
+)
+
+const mib = 1024 * 1024
+
+// fakeMaintenance simulates the database of etcd, defragmenting it releases
+// its unused space.
+type fakeMaintenance struct {
+	clientv3.Maintenance
+
+	dbSize, dbSizeInUse, revision int64
+	noSpace                       bool
+	defragmentations              int
+}
+
+func (m *fakeMaintenance) Status(ctx context.Context, endpoint string) (*clientv3.StatusResponse, error) {
+	return &clientv3.StatusResponse{
+		Header:      &etcdserverpb.ResponseHeader{Revision: m.revision},
+		DbSize:      m.dbSize,
+		DbSizeInUse: m.dbSizeInUse,
+	}, nil
+}
+
+func (m *fakeMaintenance) Defragment(ctx context.Context, endpoint string) (*clientv3.DefragmentResponse, error) {
+	m.dbSize = m.dbSizeInUse
+	m.defragmentations++
+	return &clientv3.DefragmentResponse{}, nil
+}
+
+func (m *fakeMaintenance) AlarmList(ctx context.Context) (*clientv3.AlarmResponse, error) {
+	resp := &clientv3.AlarmResponse{}
+	if m.noSpace {
+		resp.Alarms = []*etcdserverpb.AlarmMember{{MemberID: 1, Alarm: etcdserverpb.AlarmType_NOSPACE}}
+	}
+	return resp, nil
+}
+
+func (m *fakeMaintenance) AlarmDisarm(ctx context.Context, am *clientv3.AlarmMember) (*clientv3.AlarmResponse, error) {
+	if am.Alarm == etcdserverpb.AlarmType_NOSPACE {
+		m.noSpace = false
+	}
+	return &clientv3.AlarmResponse{}, nil
+}
+
+func TestEtcdDefragController(t *testing.T) {
+	tests := []struct {
+		name string
+		// revisions written between the two checks, a minute apart
+		revisions   int64
+		dbSize      int64
+		dbSizeInUse int64
+		noSpace     bool
+
+		wantDefragmentations int
+		wantNoSpace          bool
+	}{
+		{
+			name:   "not fragmented",
+			dbSize: 1000 * mib, dbSizeInUse: 600 * mib,
+		},
+		{
+			name:   "too small",
+			dbSize: 90 * mib, dbSizeInUse: 10 * mib,
+		},
+		{
+			name:   "fragmented",
+			dbSize: 1000 * mib, dbSizeInUse: 500 * mib,
+			wantDefragmentations: 1,
+		},
+		{
+			name:      "fragmented but busy",
+			revisions: 60 * (defragMaxWriteRate + 1),
+			dbSize:    1000 * mib, dbSizeInUse: 500 * mib,
+		},
+		{
+			name:      "fragmented and busy close to the quota",
+			revisions: 60 * (defragMaxWriteRate + 1),
+			dbSize:    1800 * mib, dbSizeInUse: 900 * mib,
+			wantDefragmentations: 1,
+		},
+		{
+			name:      "exceeded quota",
+			revisions: 60 * (defragMaxWriteRate + 1),
+			dbSize:    2048 * mib, dbSizeInUse: 1800 * mib, noSpace: true,
+			wantDefragmentations: 1,
+		},
+		{
+			name:   "exceeded quota without unused space",
+			dbSize: 2048 * mib, dbSizeInUse: 2048 * mib, noSpace: true,
+			wantNoSpace: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.NewMicroshiftConfig()
+			m := &fakeMaintenance{dbSize: tt.dbSize, dbSizeInUse: tt.dbSizeInUse, noSpace: tt.noSpace}
+			now := time.Now()
+			c := NewEtcdDefragController(cfg)
+			c.maintenance = m
+			c.now = func() time.Time { return now }
+
+			// the first check only measures the write rate
+			require.NoError(t, c.check(context.Background()))
+			m.revision += tt.revisions
+			now = now.Add(time.Minute)
+			require.NoError(t, c.check(context.Background()))
+
+			require.Equal(t, tt.wantDefragmentations, m.defragmentations)
+			require.Equal(t, tt.wantNoSpace, m.noSpace)
+		})
+	}
+}
@@ -0,0 +1,130 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"syscall"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+	klog "k8s.io/klog/v2"
+
+	"github.com/openshift/microshift/pkg/config"
+	"github.com/openshift/microshift/pkg/util"
+	"github.com/openshift/microshift/pkg/util/cryptomaterial"
+)
+
+const (
+	// etcdScopeUnit is the systemd scope the etcd process runs in when its
+	// memory is limited.
+	etcdScopeUnit = "microshift-etcd"
+	// etcdReadinessInterval is the time between checks whether the etcd
+	// process serves clients.
+	etcdReadinessInterval = time.Second
+	// etcdKillTimeout is the time the etcd process gets to exit after
+	// SIGTERM before it is killed, it must leave time to kill it within
+	// etcdStopTimeout.
+	etcdKillTimeout = 15
+)
+
+// runProcess runs etcd in a child process, the MicroShift binary with the
+// `etcd run` command, until ctx is done. It fails if the process exits, so
+// that the ServiceManager restarts it, or all of MicroShift once
+// kube-apiserver was started.
+func (s *EtcdService) runProcess(ctx context.Context, ready chan<- struct{}) error {
+	executable, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	cmd := etcdProcessCommand(s.cfg, executable)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	// stop etcd if MicroShift dies without stopping it
+	cmd.SysProcAttr = &syscall.SysProcAttr{Pdeathsig: syscall.SIGTERM}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("%s failed to start: %v", s.Name(), err)
+	}
+	klog.Infof("Started %s process %d: %v", s.Name(), cmd.Process.Pid, cmd.Args)
+
+	exited := make(chan error, 1)
+	go func() { exited <- cmd.Wait() }()
+
+	readinessCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go s.waitForProcess(readinessCtx, ready)
+
+	select {
+	case err := <-exited:
+		if err == nil {
+			err = fmt.Errorf("exit status 0")
+		}
+		return fmt.Errorf("%s process exited unexpectedly: %v", s.Name(), err)
+	case <-ctx.Done():
+	}
+
+	s.stopProcess(cmd, exited, etcdKillTimeout*time.Second)
+	return ctx.Err()
+}
+
+// stopProcess stops the etcd process with SIGTERM and kills it if it did not
+// exit within killTimeout. It returns once the process exited.
+func (s *EtcdService) stopProcess(cmd *exec.Cmd, exited <-chan error, killTimeout time.Duration) {
+	klog.Infof("Stopping %s process %d", s.Name(), cmd.Process.Pid)
+	if err := cmd.Process.Signal(syscall.SIGTERM); err != nil {
+		klog.Warningf("Failed to stop %s process: %v", s.Name(), err)
+	}
+	select {
+	case <-exited:
+	case <-time.After(killTimeout):
+		klog.Warningf("%s process did not stop within %s, killing it", s.Name(), killTimeout)
+		_ = cmd.Process.Kill()
+		<-exited
+	}
+}
+
+// waitForProcess closes ready once the etcd process serves clients.
+func (s *EtcdService) waitForProcess(ctx context.Context, ready chan<- struct{}) {
+	client, err := util.NewEtcdClient(cryptomaterial.CertsDirectory(microshiftDataDir))
+	if err != nil {
+		klog.Errorf("Failed to create the client of the %s process: %v", s.Name(), err)
+		return
+	}
+	defer client.Close()
+
+	err = wait.PollImmediateInfiniteWithContext(ctx, etcdReadinessInterval, func(ctx context.Context) (bool, error) {
+		statusCtx, cancel := context.WithTimeout(ctx, etcdReadinessInterval)
+		defer cancel()
+		_, err := client.Status(statusCtx, util.EtcdEndpoint)
+		return err == nil, nil
+	})
+	if err != nil {
+		return
+	}
+	klog.Infof("%s is ready", s.Name())
+	close(ready)
+}
+
+// etcdProcessCommand returns the command running etcd with the MicroShift
+// binary at executable. The node's name and IP are passed on in case they
+// were set by flags, the process reads the rest of the config itself. If the
+// memory of etcd is limited, it runs in a systemd scope with the limit.
+func etcdProcessCommand(cfg *config.MicroshiftConfig, executable string) *exec.Cmd {
+	args := []string{executable, "etcd", "run", "--node-name", cfg.NodeName, "--node-ip", cfg.NodeIP}
+	if limit := cfg.Etcd.MemoryLimitBytes(); limit > 0 {
+		args = append([]string{
+			"systemd-run", "--scope", "--quiet", "--collect",
+			"--unit", etcdScopeUnit,
+			"--property", "MemoryMax=" + strconv.FormatInt(limit, 10),
+		}, args...)
+	}
+
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Env = os.Environ()
+	if limit := cfg.Etcd.GoMemoryLimitBytes(); limit > 0 {
+		cmd.Env = append(cmd.Env, "GOMEMLIMIT="+strconv.FormatInt(limit, 10))
+	}
+	return cmd
+}
@@ -0,0 +1,57 @@
+package controllers
+
+import (
+	"bufio"
+	"os/exec"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/openshift/microshift/pkg/config"
+)
+
+func TestEtcdProcessCommand(t *testing.T) {
+	cfg := config.NewMicroshiftConfig()
+	cfg.NodeName = "node"
+	cfg.NodeIP = "10.0.0.1"
+	cfg.Etcd.Mode = config.EtcdModeProcess
+
+	cmd := etcdProcessCommand(cfg, "/usr/bin/microshift")
+	assert.Equal(t, []string{"/usr/bin/microshift", "etcd", "run", "--node-name", "node", "--node-ip", "10.0.0.1"}, cmd.Args)
+	assert.NotContains(t, cmd.Env[len(cmd.Env)-1], "GOMEMLIMIT=")
+
+	cfg.Etcd.MemoryLimit = "1Gi"
+	cmd = etcdProcessCommand(cfg, "/usr/bin/microshift")
+	assert.Equal(t, []string{
+		"systemd-run", "--scope", "--quiet", "--collect", "--unit", "microshift-etcd", "--property", "MemoryMax=1073741824",
+		"/usr/bin/microshift", "etcd", "run", "--node-name", "node", "--node-ip", "10.0.0.1",
+	}, cmd.Args)
+	assert.Equal(t, "GOMEMLIMIT=966367641", cmd.Env[len(cmd.Env)-1])
+
+	cfg.Etcd.GoMemoryLimit = "512Mi"
+	cmd = etcdProcessCommand(cfg, "/usr/bin/microshift")
+	assert.Equal(t, "GOMEMLIMIT=536870912", cmd.Env[len(cmd.Env)-1])
+}
+
+func TestEtcdStopProcess(t *testing.T) {
+	s := NewEtcd(config.NewMicroshiftConfig())
+	assert.Less(t, etcdKillTimeout*time.Second, s.StopTimeout(), "the etcd process must be killed before its stop timeout")
+
+	// a process ignoring SIGTERM gets killed
+	cmd := exec.Command("sh", "-c", "trap '' TERM; echo started; sleep 60")
+	stdout, err := cmd.StdoutPipe()
+	require.NoError(t, err)
+	require.NoError(t, cmd.Start())
+	_, err = bufio.NewReader(stdout).ReadString('\n')
+	require.NoError(t, err)
+	exited := make(chan error, 1)
+	go func() { exited <- cmd.Wait() }()
+
+	begin := time.Now()
+	s.stopProcess(cmd, exited, 100*time.Millisecond)
+	assert.Less(t, time.Since(begin), 10*time.Second)
+	assert.Equal(t, syscall.SIGKILL, cmd.ProcessState.Sys().(syscall.WaitStatus).Signal())
+}
@@ -20,8 +20,10 @@ import (
 	"fmt"
 	"net/url"
 	"path/filepath"
+	"time"
 
 	"github.com/openshift/microshift/pkg/config"
+	"github.com/openshift/microshift/pkg/servicemanager"
 	"github.com/openshift/microshift/pkg/util/cryptomaterial"
 	etcd "go.etcd.io/etcd/server/v3/embed"
 	"k8s.io/klog/v2"
@@ -41,20 +43,42 @@ var (
 
 const (
 	etcdStartupTimeout = 60
+	etcdStopTimeout    = 20
 )
 
 type EtcdService struct {
+	cfg     *config.MicroshiftConfig
 	etcdCfg *etcd.Config
 }
 
 func NewEtcd(cfg *config.MicroshiftConfig) *EtcdService {
-	s := &EtcdService{}
+	s := &EtcdService{cfg: cfg}
 	s.configure(cfg)
 	return s
 }
 
 func (s *EtcdService) Name() string           { return "etcd" }
 func (s *EtcdService) Dependencies() []string { return []string{} }
+func (s *EtcdService) StartupTimeout() time.Duration {
+	return etcdStartupTimeout * time.Second
+}
+func (s *EtcdService) StopTimeout() time.Duration {
+	return etcdStopTimeout * time.Second
+}
+
+// RestartPolicy restarts the etcd process when it fails. The embedded etcd
+// cannot be started again within the same process.
+func (s *EtcdService) RestartPolicy() servicemanager.RestartPolicy {
+	if s.cfg.Etcd.Mode != config.EtcdModeProcess {
+		return servicemanager.RestartPolicy{Mode: servicemanager.RestartNever}
+	}
+	return servicemanager.RestartPolicy{
+		Mode:        servicemanager.RestartOnFailure,
+		MaxRestarts: 5,
+		Backoff:     time.Second,
+		MaxBackoff:  30 * time.Second,
+	}
+}
 
 func (s *EtcdService) configure(cfg *config.MicroshiftConfig) {
 	certsDir := cryptomaterial.CertsDirectory(microshiftDataDir)
@@ -77,7 +101,11 @@ func (s *EtcdService) configure(cfg *config.MicroshiftConfig) {
 	s.etcdCfg.ListenMetricsUrls = setURL([]string{"127.0.0.1"}, ":2381")
 
 	s.etcdCfg.Name = cfg.NodeName
-	s.etcdCfg.InitialCluster = fmt.Sprintf("%s=https://%s:2380", cfg.NodeName, cfg.NodeIP)
+	s.etcdCfg.InitialCluster = fmt.Sprintf("%s=%s", cfg.NodeName, EtcdPeerURL(cfg))
+
+	s.etcdCfg.QuotaBackendBytes = cfg.Etcd.QuotaBackendBytes()
+	s.etcdCfg.AutoCompactionMode = cfg.Etcd.AutoCompactionMode
+	s.etcdCfg.AutoCompactionRetention = cfg.Etcd.AutoCompactionRetention
 
 	s.etcdCfg.CipherSuites = tlsCipherSuites
 	s.etcdCfg.ClientTLSInfo.CertFile = cryptomaterial.PeerCertPath(etcdServingCertDir)
@@ -92,6 +120,14 @@ func (s *EtcdService) configure(cfg *config.MicroshiftConfig) {
 func (s *EtcdService) Run(ctx context.Context, ready chan<- struct{}, stopped chan<- struct{}) error {
 	defer close(stopped)
 
+	if s.cfg.Etcd.Mode == config.EtcdModeProcess {
+		return s.runProcess(ctx, ready)
+	}
+	return s.RunEmbedded(ctx, ready)
+}
+
+// RunEmbedded runs etcd within the current process until ctx is done.
+func (s *EtcdService) RunEmbedded(ctx context.Context, ready chan<- struct{}) error {
 	e, err := etcd.StartEtcd(s.etcdCfg)
 	if err != nil {
 		return fmt.Errorf("%s failed to start: %v", s.Name(), err)
@@ -99,7 +135,11 @@ func (s *EtcdService) Run(ctx context.Context, ready chan<- struct{}, stopped ch
 
 	// run readiness check
 	go func() {
-		<-e.Server.ReadyNotify()
+		select {
+		case <-e.Server.ReadyNotify():
+		case <-ctx.Done():
+			return
+		}
 		klog.Infof("%s is ready", s.Name())
 		close(ready)
 	}()
@@ -110,6 +150,12 @@ func (s *EtcdService) Run(ctx context.Context, ready chan<- struct{}, stopped ch
 	return ctx.Err()
 }
 
+// EtcdPeerURL returns the URL etcd serves its peers at, which identifies the
+// etcd member in the cluster.
+func EtcdPeerURL(cfg *config.MicroshiftConfig) string {
+	return "https://" + cfg.NodeIP + ":2380"
+}
+
 func setURL(hostnames []string, port string) []url.URL {
 	urls := make([]url.URL, len(hostnames))
 	for i, name := range hostnames {
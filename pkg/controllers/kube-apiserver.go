@@ -42,12 +42,17 @@ import (
 	"github.com/openshift/library-go/pkg/operator/resource/resourcemerge"
 	embedded "github.com/openshift/microshift/assets"
 	"github.com/openshift/microshift/pkg/config"
+	"github.com/openshift/microshift/pkg/encryption"
+	"github.com/openshift/microshift/pkg/servicemanager"
 	"github.com/openshift/microshift/pkg/util/cryptomaterial"
 	hostassignmentv1 "k8s.io/kubernetes/openshift-kube-apiserver/admission/route/apis/hostassignment/v1"
 )
 
 const (
 	kubeAPIStartupTimeout = 60
+	// kube-apiserver delays its shutdown by the configured
+	// shutdown-delay-duration of 70s
+	kubeAPIStopTimeout = 80
 )
 
 var baseKubeAPIServerConfigs = [][]byte{
@@ -73,6 +78,8 @@ type KubeAPIServer struct {
 
 	masterURL     string
 	servingCAPath string
+
+	encryptionType encryption.Type
 }
 
 func NewKubeAPIServer(cfg *config.MicroshiftConfig) *KubeAPIServer {
@@ -85,6 +92,18 @@ func NewKubeAPIServer(cfg *config.MicroshiftConfig) *KubeAPIServer {
 
 func (s *KubeAPIServer) Name() string           { return "kube-apiserver" }
 func (s *KubeAPIServer) Dependencies() []string { return []string{"etcd"} }
+func (s *KubeAPIServer) StartupTimeout() time.Duration {
+	return kubeAPIStartupTimeout * time.Second
+}
+func (s *KubeAPIServer) StopTimeout() time.Duration {
+	return kubeAPIStopTimeout * time.Second
+}
+
+// RestartPolicy keeps etcd from restarting kube-apiserver in-process, it
+// registers global state that does not allow it to run twice.
+func (s *KubeAPIServer) RestartPolicy() servicemanager.RestartPolicy {
+	return servicemanager.RestartPolicy{Mode: servicemanager.RestartNever, SingleRun: true}
+}
 
 func (s *KubeAPIServer) configure(cfg *config.MicroshiftConfig) error {
 	s.verbosity = cfg.LogVLevel
@@ -100,10 +119,6 @@ func (s *KubeAPIServer) configure(cfg *config.MicroshiftConfig) error {
 	servingCert := cryptomaterial.ServingCertPath(serviceNetworkServingCertDir)
 	servingKey := cryptomaterial.ServingKeyPath(serviceNetworkServingCertDir)
 
-	if err := s.configureAuditPolicy(cfg); err != nil {
-		return fmt.Errorf("failed to configure kube-apiserver audit policy: %w", err)
-	}
-
 	// Get the apiserver port so we can set it as an argument
 	apiServerPort, err := cfg.Cluster.ApiServerPort()
 	if err != nil {
@@ -112,15 +127,17 @@ func (s *KubeAPIServer) configure(cfg *config.MicroshiftConfig) error {
 
 	s.masterURL = cfg.Cluster.URL
 	s.servingCAPath = cryptomaterial.ServiceAccountTokenCABundlePath(certsDir)
+	s.encryptionType = cfg.APIServer.Encryption.ProviderType()
 
 	overrides := &kubecontrolplanev1.KubeAPIServerConfig{
 		APIServerArguments: map[string]kubecontrolplanev1.Arguments{
-			"advertise-address": {cfg.NodeIP},
-			"audit-policy-file": {microshiftDataDir + "/resources/kube-apiserver-audit-policies/default.yaml"},
-			"client-ca-file":    {clientCABundlePath},
-			"etcd-cafile":       {cryptomaterial.CACertPath(cryptomaterial.EtcdSignerDir(certsDir))},
-			"etcd-certfile":     {cryptomaterial.ClientCertPath(etcdClientCertDir)},
-			"etcd-keyfile":      {cryptomaterial.ClientKeyPath(etcdClientCertDir)},
+			"advertise-address":          {cfg.NodeIP},
+			"audit-policy-file":          {microshiftDataDir + "/resources/kube-apiserver-audit-policies/default.yaml"},
+			"client-ca-file":             {clientCABundlePath},
+			"encryption-provider-config": {encryption.ConfigPath(microshiftDataDir)},
+			"etcd-cafile":                {cryptomaterial.CACertPath(cryptomaterial.EtcdSignerDir(certsDir))},
+			"etcd-certfile":              {cryptomaterial.ClientCertPath(etcdClientCertDir)},
+			"etcd-keyfile":               {cryptomaterial.ClientKeyPath(etcdClientCertDir)},
 			"etcd-servers": {
 				"https://127.0.0.1:2379",
 			},
@@ -181,7 +198,7 @@ func (s *KubeAPIServer) configure(cfg *config.MicroshiftConfig) error {
 					BindAddress:   net.JoinHostPort("0.0.0.0", strconv.Itoa(apiServerPort)),
 					MinTLSVersion: string(fixedTLSProfile.MinTLSVersion),
 					CipherSuites:  crypto.OpenSSLToIANACipherSuites(fixedTLSProfile.Ciphers),
-					NamedCertificates: []configv1.NamedCertificate{
+					NamedCertificates: append(userNamedCertificates(cfg), []configv1.NamedCertificate{
 						{
 							CertInfo: configv1.CertInfo{
 								CertFile: cryptomaterial.ServingCertPath(cryptomaterial.KubeAPIServerExternalServingCertDir(certsDir)),
@@ -200,7 +217,7 @@ func (s *KubeAPIServer) configure(cfg *config.MicroshiftConfig) error {
 								KeyFile:  servingKey,
 							},
 						},
-					},
+					}...),
 				},
 			},
 		},
@@ -248,7 +265,7 @@ func (s *KubeAPIServer) configure(cfg *config.MicroshiftConfig) error {
 	return nil
 }
 
-func (s *KubeAPIServer) configureAuditPolicy(cfg *config.MicroshiftConfig) error {
+func (s *KubeAPIServer) configureAuditPolicy() error {
 	data := []byte(`
 apiVersion: audit.k8s.io/v1
 kind: Policy
@@ -288,10 +305,32 @@ rules:
 	return os.WriteFile(path, data, 0644)
 }
 
+// configureEncryption writes the EncryptionConfiguration, adding a key if
+// the encryption type changed and removing the keys that are no longer used.
+func (s *KubeAPIServer) configureEncryption() error {
+	state, err := encryption.Load(encryption.Dir(microshiftDataDir))
+	if err != nil {
+		return err
+	}
+	if _, err := state.Ensure(s.encryptionType); err != nil {
+		return err
+	}
+	if state.MigrationPending() {
+		klog.Infof("Secrets and configmaps will be rewritten with encryption key %s", state.WriteKey().ID())
+	}
+	return state.Write()
+}
+
 func (s *KubeAPIServer) Run(ctx context.Context, ready chan<- struct{}, stopped chan<- struct{}) error {
 	if s.configureErr != nil {
 		return fmt.Errorf("configuration failed: %w", s.configureErr)
 	}
+	if err := s.configureAuditPolicy(); err != nil {
+		return fmt.Errorf("failed to configure kube-apiserver audit policy: %w", err)
+	}
+	if err := s.configureEncryption(); err != nil {
+		return fmt.Errorf("failed to configure kube-apiserver encryption: %w", err)
+	}
 
 	defer close(stopped)
 	errorChannel := make(chan error, 1)
@@ -300,7 +339,7 @@ func (s *KubeAPIServer) Run(ctx context.Context, ready chan<- struct{}, stopped
 
 	// run readiness check
 	go func() {
-		err := wait.PollImmediateWithContext(ctx, time.Second, kubeAPIStartupTimeout*time.Second, func(ctx context.Context) (bool, error) {
+		err := wait.PollImmediateUntilWithContext(ctx, time.Second, func(ctx context.Context) (bool, error) {
 			restConfig, err := clientcmd.BuildConfigFromFlags(s.masterURL, "")
 			if err != nil {
 				return false, err
@@ -372,3 +411,20 @@ func (s *KubeAPIServer) Run(ctx context.Context, ready chan<- struct{}, stopped
 	}()
 	return <-errorChannel
 }
+
+// userNamedCertificates returns the serving certificates configured by the
+// user. kube-apiserver prefers earlier certificates for names that several
+// certificates are valid for, so they go before the generated ones.
+func userNamedCertificates(cfg *config.MicroshiftConfig) []configv1.NamedCertificate {
+	namedCerts := []configv1.NamedCertificate{}
+	for _, namedCert := range cfg.APIServer.NamedCertificates {
+		namedCerts = append(namedCerts, configv1.NamedCertificate{
+			Names: namedCert.Names,
+			CertInfo: configv1.CertInfo{
+				CertFile: namedCert.CertPath,
+				KeyFile:  namedCert.KeyPath,
+			},
+		})
+	}
+	return namedCerts
+}
@@ -21,6 +21,7 @@ import (
 	"errors"
 	"fmt"
 	"sort"
+	"time"
 
 	embedded "github.com/openshift/microshift/assets"
 	"github.com/openshift/microshift/pkg/assets"
@@ -38,6 +39,8 @@ import (
 
 const (
 	kcmDefaultConfigAsset = "components/kube-controller-manager/defaultconfig.yaml"
+
+	kcmStartupTimeout = 120
 )
 
 type KubeControllerManager struct {
@@ -57,6 +60,9 @@ func NewKubeControllerManager(cfg *config.MicroshiftConfig) *KubeControllerManag
 
 func (s *KubeControllerManager) Name() string           { return "kube-controller-manager" }
 func (s *KubeControllerManager) Dependencies() []string { return []string{"kube-apiserver"} }
+func (s *KubeControllerManager) StartupTimeout() time.Duration {
+	return kcmStartupTimeout * time.Second
+}
 
 func kcmRootCAFile() string {
 	certsDir := cryptomaterial.CertsDirectory(microshiftDataDir)
@@ -115,10 +121,11 @@ func (s *KubeControllerManager) Run(ctx context.Context, ready chan<- struct{},
 
 	// run readiness check
 	go func() {
-		healthcheckStatus := util.RetryInsecureHttpsGet("https://127.0.0.1:10257/healthz")
+		healthcheckStatus := util.RetryInsecureHttpsGet(ctx, "https://127.0.0.1:10257/healthz")
 		if healthcheckStatus != 200 {
 			klog.Errorf("kube-controller-manager failed to start")
 			errorChannel <- errors.New("kube-controller-manager failed to start")
+			return
 		}
 
 		klog.Infof("%s is ready", s.Name())
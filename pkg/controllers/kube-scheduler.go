@@ -5,7 +5,7 @@ Licensed under the Apache License, Version 2.0 (the "License");
 you may not use this file except in compliance with the License.
 You may obtain a copy of the License at
 
-    http://www.apache.org/licenses/LICENSE-2.0
+	http://www.apache.org/licenses/LICENSE-2.0
 
 Unless required by applicable law or agreed to in writing, software
 distributed under the License is distributed on an "AS IS" BASIS,
@@ -22,6 +22,7 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/openshift/microshift/pkg/config"
 	"github.com/openshift/microshift/pkg/util"
@@ -32,10 +33,11 @@ import (
 )
 
 const (
-	kubeSchedulerStartupTimeout = 30
+	kubeSchedulerStartupTimeout = 120
 )
 
 type KubeScheduler struct {
+	cfg        *config.MicroshiftConfig
 	options    *schedulerOptions.Options
 	kubeconfig string
 }
@@ -48,12 +50,12 @@ func NewKubeScheduler(cfg *config.MicroshiftConfig) *KubeScheduler {
 
 func (s *KubeScheduler) Name() string           { return "kube-scheduler" }
 func (s *KubeScheduler) Dependencies() []string { return []string{"kube-apiserver"} }
+func (s *KubeScheduler) StartupTimeout() time.Duration {
+	return kubeSchedulerStartupTimeout * time.Second
+}
 
 func (s *KubeScheduler) configure(cfg *config.MicroshiftConfig) {
-	if err := s.writeConfig(cfg); err != nil {
-		klog.Fatalf("failed to write kube-scheduler config: %v", err)
-	}
-
+	s.cfg = cfg
 	s.options = schedulerOptions.NewOptions()
 	s.options.ConfigFile = microshiftDataDir + "/resources/kube-scheduler/config/config.yaml"
 	s.kubeconfig = cfg.KubeConfigPath(config.KubeAdmin)
@@ -74,14 +76,19 @@ leaderElection:
 
 func (s *KubeScheduler) Run(ctx context.Context, ready chan<- struct{}, stopped chan<- struct{}) error {
 	defer close(stopped)
+
+	if err := s.writeConfig(s.cfg); err != nil {
+		return fmt.Errorf("failed to write kube-scheduler config: %w", err)
+	}
 	errorChannel := make(chan error, 1)
 
 	// run readiness check
 	go func() {
-		healthcheckStatus := util.RetryInsecureHttpsGet("https://127.0.0.1:10259/healthz")
+		healthcheckStatus := util.RetryInsecureHttpsGet(ctx, "https://127.0.0.1:10259/healthz")
 		if healthcheckStatus != 200 {
-			klog.Errorf("%s healthcheck failed", s.Name(), fmt.Errorf("kube-scheduler failed to start"))
+			klog.Errorf("%s healthcheck failed", s.Name())
 			errorChannel <- errors.New("kube-scheduler healthcheck failed")
+			return
 		}
 
 		klog.Infof("%s is ready", s.Name())
@@ -17,6 +17,7 @@ package controllers
 
 import (
 	"context"
+	"time"
 
 	"k8s.io/klog/v2"
 
@@ -41,6 +42,8 @@ type OCPRouteControllerManager struct {
 const (
 	// OCPRouteControllerManager component name
 	componentRCM = "route-controller-manager"
+
+	rcmStartupTimeout = 120
 )
 
 func NewRouteControllerManager(cfg *config.MicroshiftConfig) *OCPRouteControllerManager {
@@ -54,6 +57,10 @@ func (s *OCPRouteControllerManager) Dependencies() []string {
 	return []string{"kube-apiserver", "openshift-crd-manager"}
 }
 
+func (s *OCPRouteControllerManager) StartupTimeout() time.Duration {
+	return rcmStartupTimeout * time.Second
+}
+
 func (s *OCPRouteControllerManager) configure(cfg *config.MicroshiftConfig) {
 	s.kubeconfig = cfg.KubeConfigPath(config.KubeAdmin)
 	s.config = s.writeConfig(cfg)
@@ -98,9 +105,11 @@ func (s *OCPRouteControllerManager) Run(ctx context.Context, ready chan<- struct
 
 	// run readiness check
 	go func() {
-		healthcheckStatus := util.RetryTCPConnection("127.0.0.1", "8445")
+		// the service manager enforces the startup timeout by canceling ctx
+		healthcheckStatus := util.RetryTCPConnection(ctx, "127.0.0.1", "8445")
 		if !healthcheckStatus {
-			klog.Fatalf("initial healthcheck on %s failed", s.Name())
+			klog.Errorf("initial healthcheck on %s failed", s.Name())
+			return
 		}
 		klog.Infof("%s is ready", s.Name())
 		close(ready)
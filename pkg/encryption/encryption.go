@@ -0,0 +1,285 @@
+// Package encryption manages the EncryptionConfiguration kube-apiserver
+// encrypts resources in etcd with.
+package encryption
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	apiserverconfigv1 "k8s.io/apiserver/pkg/apis/config/v1"
+	"sigs.k8s.io/yaml"
+)
+
+// Type is the type of the provider resources are encrypted with.
+type Type string
+
+const (
+	Identity  Type = "identity"
+	AESCBC    Type = "aescbc"
+	AESGCM    Type = "aesgcm"
+	Secretbox Type = "secretbox"
+)
+
+// Types are the supported provider types.
+var Types = []string{string(Identity), string(AESCBC), string(AESGCM), string(Secretbox)}
+
+// Resources are the resources that are encrypted.
+var Resources = []string{"secrets", "configmaps"}
+
+const (
+	keySize    = 32
+	keyPrefix  = "key-"
+	configName = "config.yaml"
+	// migratedName holds the ID of the key all resources were rewritten
+	// with.
+	migratedName = "migrated"
+)
+
+// Dir returns the directory of the encryption configuration and its state.
+func Dir(dataDir string) string {
+	return filepath.Join(dataDir, "resources", "kube-apiserver", "encryption")
+}
+
+// ConfigPath returns the path of the EncryptionConfiguration kube-apiserver
+// reads.
+func ConfigPath(dataDir string) string {
+	return filepath.Join(Dir(dataDir), configName)
+}
+
+// Key is a key of a provider, identity has no key.
+type Key struct {
+	Type   Type
+	Name   string
+	Secret string
+}
+
+// ID identifies the key across provider types.
+func (k Key) ID() string {
+	if k.Type == Identity {
+		return string(Identity)
+	}
+	return string(k.Type) + "/" + k.Name
+}
+
+// State is the encryption configuration of a MicroShift instance.
+type State struct {
+	dir string
+
+	// Keys in the order kube-apiserver tries them when reading, the first
+	// key is used for writing.
+	Keys []Key
+	// Migrated is the ID of the key all resources were last rewritten with.
+	Migrated string
+}
+
+// Load reads the state in dir. Without an encryption configuration, nothing
+// has been encrypted yet.
+func Load(dir string) (*State, error) {
+	s := &State{dir: dir}
+	data, err := os.ReadFile(filepath.Join(dir, configName))
+	if os.IsNotExist(err) {
+		s.Keys = []Key{{Type: Identity}}
+		s.Migrated = string(Identity)
+		return s, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	config := &apiserverconfigv1.EncryptionConfiguration{}
+	if err := yaml.UnmarshalStrict(data, config); err != nil {
+		return nil, fmt.Errorf("invalid encryption configuration %s: %w", filepath.Join(dir, configName), err)
+	}
+	if len(config.Resources) == 0 {
+		return nil, fmt.Errorf("invalid encryption configuration %s: no resources", filepath.Join(dir, configName))
+	}
+	// all resources share the providers
+	for _, provider := range config.Resources[0].Providers {
+		switch {
+		case provider.Identity != nil:
+			s.Keys = append(s.Keys, Key{Type: Identity})
+		case provider.AESCBC != nil:
+			s.Keys = append(s.Keys, keysOf(AESCBC, provider.AESCBC.Keys)...)
+		case provider.AESGCM != nil:
+			s.Keys = append(s.Keys, keysOf(AESGCM, provider.AESGCM.Keys)...)
+		case provider.Secretbox != nil:
+			s.Keys = append(s.Keys, keysOf(Secretbox, provider.Secretbox.Keys)...)
+		default:
+			return nil, fmt.Errorf("unsupported provider in encryption configuration %s", filepath.Join(dir, configName))
+		}
+	}
+	if len(s.Keys) == 0 {
+		return nil, fmt.Errorf("invalid encryption configuration %s: no providers", filepath.Join(dir, configName))
+	}
+
+	migrated, err := os.ReadFile(filepath.Join(dir, migratedName))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	s.Migrated = strings.TrimSpace(string(migrated))
+	return s, nil
+}
+
+func keysOf(t Type, keys []apiserverconfigv1.Key) []Key {
+	result := make([]Key, 0, len(keys))
+	for _, key := range keys {
+		result = append(result, Key{Type: t, Name: key.Name, Secret: key.Secret})
+	}
+	return result
+}
+
+// WriteKey returns the key resources are written with.
+func (s *State) WriteKey() Key {
+	return s.Keys[0]
+}
+
+// MigrationPending returns whether resources may still be encrypted with
+// other keys than the write key.
+func (s *State) MigrationPending() bool {
+	return s.Migrated != s.WriteKey().ID()
+}
+
+// Ensure makes keys of type t the write keys, generating a new one if
+// needed. Once all resources were rewritten with the write key, the other
+// keys are removed. It returns whether the state changed.
+func (s *State) Ensure(t Type) (bool, error) {
+	changed := false
+	if !s.MigrationPending() && len(s.Keys) > 1 {
+		s.Keys = s.Keys[:1]
+		changed = true
+	}
+	if s.WriteKey().Type == t {
+		return changed, nil
+	}
+	if t == Identity {
+		s.Keys = append([]Key{{Type: Identity}}, s.without(Identity)...)
+		return true, nil
+	}
+	if _, err := s.Rotate(t); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Rotate adds a new key of type t and makes it the write key.
+func (s *State) Rotate(t Type) (Key, error) {
+	if t == Identity {
+		return Key{}, fmt.Errorf("identity has no keys to rotate")
+	}
+	secret := make([]byte, keySize)
+	if _, err := rand.Read(secret); err != nil {
+		return Key{}, err
+	}
+	key := Key{Type: t, Name: s.nextKeyName(), Secret: base64.StdEncoding.EncodeToString(secret)}
+	s.Keys = append([]Key{key}, s.Keys...)
+	return key, nil
+}
+
+func (s *State) nextKeyName() string {
+	last := 0
+	for _, key := range s.Keys {
+		if n, err := strconv.Atoi(strings.TrimPrefix(key.Name, keyPrefix)); err == nil && n > last {
+			last = n
+		}
+	}
+	return keyPrefix + strconv.Itoa(last+1)
+}
+
+func (s *State) without(t Type) []Key {
+	keys := []Key{}
+	for _, key := range s.Keys {
+		if key.Type != t {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// Write writes the EncryptionConfiguration and the migration state.
+func (s *State) Write() error {
+	data, err := yaml.Marshal(s.encryptionConfiguration())
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(s.dir, 0700); err != nil {
+		return err
+	}
+	if err := writeFile(filepath.Join(s.dir, configName), data); err != nil {
+		return err
+	}
+	return writeFile(filepath.Join(s.dir, migratedName), []byte(s.Migrated+"\n"))
+}
+
+// SetMigrated records that all resources were rewritten with the key.
+func (s *State) SetMigrated(key Key) error {
+	s.Migrated = key.ID()
+	return writeFile(filepath.Join(s.dir, migratedName), []byte(s.Migrated+"\n"))
+}
+
+func writeFile(path string, data []byte) error {
+	if current, err := os.ReadFile(path); err == nil && bytes.Equal(current, data) {
+		return nil
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// encryptionConfiguration returns the keys as providers, consecutive keys
+// of the same type share a provider.
+func (s *State) encryptionConfiguration() *apiserverconfigv1.EncryptionConfiguration {
+	providers := []apiserverconfigv1.ProviderConfiguration{}
+	var last Type
+	for _, key := range s.Keys {
+		apiKey := apiserverconfigv1.Key{Name: key.Name, Secret: key.Secret}
+		if key.Type == last && key.Type != Identity {
+			provider := &providers[len(providers)-1]
+			switch key.Type {
+			case AESCBC:
+				provider.AESCBC.Keys = append(provider.AESCBC.Keys, apiKey)
+			case AESGCM:
+				provider.AESGCM.Keys = append(provider.AESGCM.Keys, apiKey)
+			case Secretbox:
+				provider.Secretbox.Keys = append(provider.Secretbox.Keys, apiKey)
+			}
+			continue
+		}
+		last = key.Type
+
+		switch key.Type {
+		case Identity:
+			providers = append(providers, apiserverconfigv1.ProviderConfiguration{Identity: &apiserverconfigv1.IdentityConfiguration{}})
+		case AESCBC:
+			providers = append(providers, apiserverconfigv1.ProviderConfiguration{AESCBC: &apiserverconfigv1.AESConfiguration{Keys: []apiserverconfigv1.Key{apiKey}}})
+		case AESGCM:
+			providers = append(providers, apiserverconfigv1.ProviderConfiguration{AESGCM: &apiserverconfigv1.AESConfiguration{Keys: []apiserverconfigv1.Key{apiKey}}})
+		case Secretbox:
+			providers = append(providers, apiserverconfigv1.ProviderConfiguration{Secretbox: &apiserverconfigv1.SecretboxConfiguration{Keys: []apiserverconfigv1.Key{apiKey}}})
+		}
+	}
+
+	config := &apiserverconfigv1.EncryptionConfiguration{
+		Resources: []apiserverconfigv1.ResourceConfiguration{{Resources: Resources, Providers: providers}},
+	}
+	config.APIVersion = apiserverconfigv1.SchemeGroupVersion.String()
+	config.Kind = "EncryptionConfiguration"
+	return config
+}
+
+// KeyOf returns the ID of the key a value stored in etcd is encrypted with,
+// identity if it is not encrypted.
+func KeyOf(value []byte) string {
+	// encrypted values are prefixed with k8s:enc:<type>:v1:<name>:
+	parts := strings.SplitN(string(value), ":", 6)
+	if len(parts) < 6 || parts[0] != "k8s" || parts[1] != "enc" {
+		return string(Identity)
+	}
+	return parts[2] + "/" + parts[4]
+}
@@ -0,0 +1,112 @@
+package encryption
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apiserver/pkg/server/options/encryptionconfig"
+	"k8s.io/apiserver/pkg/storage/value"
+)
+
+func keyIDs(s *State) []string {
+	ids := []string{}
+	for _, key := range s.Keys {
+		ids = append(ids, key.ID())
+	}
+	return ids
+}
+
+// secretsTransformer loads the EncryptionConfiguration like kube-apiserver
+// and returns the transformer of secrets.
+func secretsTransformer(t *testing.T, dir string) value.Transformer {
+	t.Helper()
+	transformers, err := encryptionconfig.GetTransformerOverrides(filepath.Join(dir, configName))
+	require.NoError(t, err)
+	transformer, ok := transformers[schema.GroupResource{Resource: "secrets"}]
+	require.True(t, ok, "no transformer for secrets")
+	return transformer
+}
+
+func TestState(t *testing.T) {
+	ctx := context.Background()
+	dataCtx := value.DefaultContext("/kubernetes.io/secrets/default/test")
+	dir := filepath.Join(t.TempDir(), "encryption")
+
+	// nothing was encrypted before encryption was configured
+	s, err := Load(dir)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"identity"}, keyIDs(s))
+	assert.False(t, s.MigrationPending())
+
+	changed, err := s.Ensure(AESCBC)
+	require.NoError(t, err)
+	assert.True(t, changed)
+	assert.Equal(t, []string{"aescbc/key-1", "identity"}, keyIDs(s))
+	assert.True(t, s.MigrationPending())
+	require.NoError(t, s.Write())
+
+	s, err = Load(dir)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"aescbc/key-1", "identity"}, keyIDs(s))
+	assert.True(t, s.MigrationPending())
+
+	transformer := secretsTransformer(t, dir)
+	stored, err := transformer.TransformToStorage(ctx, []byte("secret"), dataCtx)
+	require.NoError(t, err)
+	assert.Equal(t, "aescbc/key-1", KeyOf(stored))
+	assert.Equal(t, "identity", KeyOf([]byte("k8s\x00plaintext")))
+
+	// after the migration, the keys that are not used for writing are
+	// removed on the next start
+	require.NoError(t, s.SetMigrated(s.WriteKey()))
+	changed, err = s.Ensure(AESCBC)
+	require.NoError(t, err)
+	assert.True(t, changed)
+	assert.Equal(t, []string{"aescbc/key-1"}, keyIDs(s))
+	changed, err = s.Ensure(AESCBC)
+	require.NoError(t, err)
+	assert.False(t, changed)
+
+	key, err := s.Rotate(AESCBC)
+	require.NoError(t, err)
+	assert.Equal(t, "aescbc/key-2", key.ID())
+	changed, err = s.Ensure(Secretbox)
+	require.NoError(t, err)
+	assert.True(t, changed)
+	assert.Equal(t, []string{"secretbox/key-3", "aescbc/key-2", "aescbc/key-1"}, keyIDs(s))
+	require.NoError(t, s.Write())
+
+	// values encrypted with the previous keys are still read, but stale
+	transformer = secretsTransformer(t, dir)
+	plain, stale, err := transformer.TransformFromStorage(ctx, stored, dataCtx)
+	require.NoError(t, err)
+	assert.Equal(t, "secret", string(plain))
+	assert.True(t, stale)
+	stored, err = transformer.TransformToStorage(ctx, []byte("secret"), dataCtx)
+	require.NoError(t, err)
+	assert.Equal(t, "secretbox/key-3", KeyOf(stored))
+
+	// disabling the encryption keeps the keys to read the encrypted values
+	changed, err = s.Ensure(Identity)
+	require.NoError(t, err)
+	assert.True(t, changed)
+	assert.Equal(t, []string{"identity", "secretbox/key-3", "aescbc/key-2", "aescbc/key-1"}, keyIDs(s))
+	require.NoError(t, s.Write())
+	plain, stale, err = secretsTransformer(t, dir).TransformFromStorage(ctx, stored, dataCtx)
+	require.NoError(t, err)
+	assert.Equal(t, "secret", string(plain))
+	assert.True(t, stale)
+}
+
+func TestLoadInvalid(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, configName), []byte("kind: EncryptionConfiguration\nresources: []\n"), 0600))
+	_, err := Load(dir)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "no resources")
+}
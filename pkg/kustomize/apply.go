@@ -3,11 +3,13 @@ package kustomize
 import (
 	"context"
 	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
 	"time"
 
 	"github.com/openshift/microshift/pkg/config"
+	"github.com/openshift/microshift/pkg/servicemanager"
 	"github.com/spf13/cobra"
 	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/cli-runtime/pkg/genericclioptions"
@@ -23,8 +25,6 @@ const (
 	retryTimeout  = 1 * time.Minute
 )
 
-var microshiftManifestsDir = config.GetManifestsDir()
-
 type Kustomizer struct {
 	paths      []string
 	kubeconfig string
@@ -32,7 +32,7 @@ type Kustomizer struct {
 
 func NewKustomizer(cfg *config.MicroshiftConfig) *Kustomizer {
 	return &Kustomizer{
-		paths:      microshiftManifestsDir,
+		paths:      cfg.Manifests.KustomizePaths,
 		kubeconfig: cfg.KubeConfigPath(config.KubeAdmin),
 	}
 }
@@ -40,29 +40,49 @@ func NewKustomizer(cfg *config.MicroshiftConfig) *Kustomizer {
 func (s *Kustomizer) Name() string           { return "kustomizer" }
 func (s *Kustomizer) Dependencies() []string { return []string{"kube-apiserver"} }
 
+func (s *Kustomizer) RestartPolicy() servicemanager.RestartPolicy {
+	return servicemanager.RestartPolicy{
+		Mode:        servicemanager.RestartOnFailure,
+		MaxRestarts: 5,
+		Backoff:     retryInterval,
+		MaxBackoff:  5 * time.Minute,
+	}
+}
+
 func (s *Kustomizer) Run(ctx context.Context, ready chan<- struct{}, stopped chan<- struct{}) error {
 	defer close(stopped)
 	defer close(ready)
 
-	for _, path := range s.paths {
-		s.ApplyKustomizationPath(path)
+	if err := s.ApplyKustomizationPaths(); err != nil {
+		return err
 	}
 
 	return ctx.Err()
 }
 
-func (s *Kustomizer) ApplyKustomizationPath(path string) {
+// ApplyKustomizationPaths applies the kustomizations at the configured paths
+// in order.
+func (s *Kustomizer) ApplyKustomizationPaths() error {
+	for _, path := range s.paths {
+		if err := s.ApplyKustomizationPath(path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Kustomizer) ApplyKustomizationPath(path string) error {
 	kustomization := filepath.Join(path, "kustomization.yaml")
 	if _, err := os.Stat(kustomization); !errors.Is(err, os.ErrNotExist) {
 		klog.Infof("Applying kustomization at %v ", kustomization)
 		if err := ApplyKustomizationWithRetries(path, s.kubeconfig); err != nil {
-			klog.Fatalf("Applying kustomization at %v failed: %s. Giving up.", kustomization, err)
-		} else {
-			klog.Infof("Kustomization at %v applied successfully.", kustomization)
+			return fmt.Errorf("applying kustomization at %v failed: %w", kustomization, err)
 		}
+		klog.Infof("Kustomization at %v applied successfully.", kustomization)
 	} else {
 		klog.Infof("No kustomization found at " + kustomization)
 	}
+	return nil
 }
 
 func ApplyKustomizationWithRetries(kustomization string, kubeconfig string) error {
@@ -6,9 +6,11 @@ import (
 	"regexp"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/openshift/microshift/pkg/config"
 	"github.com/openshift/microshift/pkg/mdns/server"
+	"github.com/openshift/microshift/pkg/servicemanager"
 	"k8s.io/klog/v2"
 )
 
@@ -37,6 +39,15 @@ func (s *MicroShiftmDNSController) Dependencies() []string {
 	return []string{"openshift-default-scc-manager"}
 }
 
+func (s *MicroShiftmDNSController) RestartPolicy() servicemanager.RestartPolicy {
+	return servicemanager.RestartPolicy{
+		Mode:        servicemanager.RestartOnFailure,
+		MaxRestarts: 10,
+		Backoff:     5 * time.Second,
+		MaxBackoff:  5 * time.Minute,
+	}
+}
+
 func (c *MicroShiftmDNSController) Run(ctx context.Context, ready chan<- struct{}, stopped chan<- struct{}) error {
 	defer close(stopped)
 
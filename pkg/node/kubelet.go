@@ -22,10 +22,12 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"time"
 
 	"k8s.io/klog/v2"
 
 	"github.com/openshift/microshift/pkg/config"
+	"github.com/openshift/microshift/pkg/servicemanager"
 	"github.com/openshift/microshift/pkg/util"
 	"github.com/openshift/microshift/pkg/util/cryptomaterial"
 
@@ -42,11 +44,14 @@ import (
 const (
 	// Kubelet component name
 	componentKubelet = "kubelet"
+
+	kubeletStartupTimeout = 120
 )
 
 var microshiftDataDir = config.GetDataDir()
 
 type KubeletServer struct {
+	cfg          *config.MicroshiftConfig
 	kubeletflags *kubeletoptions.KubeletFlags
 	kubeconfig   *kubeletconfig.KubeletConfiguration
 }
@@ -59,12 +64,18 @@ func NewKubeletServer(cfg *config.MicroshiftConfig) *KubeletServer {
 
 func (s *KubeletServer) Name() string           { return componentKubelet }
 func (s *KubeletServer) Dependencies() []string { return []string{"kube-apiserver"} }
+func (s *KubeletServer) StartupTimeout() time.Duration {
+	return kubeletStartupTimeout * time.Second
+}
 
-func (s *KubeletServer) configure(cfg *config.MicroshiftConfig) {
+// RestartPolicy keeps the kubelet from being restarted in-process, it
+// registers global state that does not allow it to run twice.
+func (s *KubeletServer) RestartPolicy() servicemanager.RestartPolicy {
+	return servicemanager.RestartPolicy{Mode: servicemanager.RestartNever, SingleRun: true}
+}
 
-	if err := s.writeConfig(cfg); err != nil {
-		klog.Fatalf("Failed to write kubelet config", err)
-	}
+func (s *KubeletServer) configure(cfg *config.MicroshiftConfig) {
+	s.cfg = cfg
 
 	kubeletFlags := kubeletoptions.NewKubeletFlags()
 	kubeletFlags.BootstrapKubeconfig = cfg.KubeConfigPath(config.Kubelet)
@@ -77,13 +88,6 @@ func (s *KubeletServer) configure(cfg *config.MicroshiftConfig) {
 	kubeletFlags.NodeLabels["node-role.kubernetes.io/master"] = ""
 	kubeletFlags.NodeLabels["node-role.kubernetes.io/worker"] = ""
 
-	kubeletConfig, err := loadConfigFile(microshiftDataDir + "/resources/kubelet/config/config.yaml")
-
-	if err != nil {
-		klog.Fatalf("Failed to load Kubelet Configuration", err)
-	}
-
-	s.kubeconfig = kubeletConfig
 	s.kubeletflags = kubeletFlags
 }
 
@@ -137,11 +141,25 @@ serverTLSBootstrap: false #TODO`)
 func (s *KubeletServer) Run(ctx context.Context, ready chan<- struct{}, stopped chan<- struct{}) error {
 
 	defer close(stopped)
+
+	// the config is written when the kubelet starts, so constructing the
+	// service does not touch the data dir
+	if err := s.writeConfig(s.cfg); err != nil {
+		return fmt.Errorf("failed to write kubelet config: %w", err)
+	}
+	kubeletConfig, err := loadConfigFile(microshiftDataDir + "/resources/kubelet/config/config.yaml")
+	if err != nil {
+		return fmt.Errorf("failed to load kubelet configuration: %w", err)
+	}
+	s.kubeconfig = kubeletConfig
+
 	// run readiness check
 	go func() {
-		healthcheckStatus := util.RetryInsecureHttpsGet("http://127.0.0.1:10248/healthz")
+		// the service manager enforces the startup timeout by canceling ctx
+		healthcheckStatus := util.RetryInsecureHttpsGet(ctx, "http://127.0.0.1:10248/healthz")
 		if healthcheckStatus != 200 {
-			klog.Fatalf("", fmt.Errorf("%s failed to start", s.Name()))
+			klog.Errorf("%s failed to start", s.Name())
+			return
 		}
 		klog.Infof("%s is ready", s.Name())
 		close(ready)
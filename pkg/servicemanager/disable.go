@@ -0,0 +1,36 @@
+package servicemanager
+
+import (
+	"fmt"
+)
+
+// DisableServices excludes the services from being run. Validation fails if
+// any of the services that remain enabled depends on a disabled one.
+func (m *ServiceManager) DisableServices(names ...string) {
+	for _, name := range names {
+		m.disabled[name] = true
+	}
+}
+
+func (m *ServiceManager) isDisabled(name string) bool {
+	return m.disabled[name]
+}
+
+func (m *ServiceManager) validateDisabled() error {
+	for name := range m.disabled {
+		if _, exists := m.serviceMap[name]; !exists {
+			return fmt.Errorf("unknown service '%s' configured to be disabled", name)
+		}
+	}
+	for _, service := range m.services {
+		if m.isDisabled(service.Name()) {
+			continue
+		}
+		for _, dependency := range service.Dependencies() {
+			if m.isDisabled(dependency) {
+				return fmt.Errorf("service '%s' depends on disabled service '%s'", service.Name(), dependency)
+			}
+		}
+	}
+	return nil
+}
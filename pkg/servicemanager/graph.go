@@ -0,0 +1,110 @@
+package servicemanager
+
+import (
+	"fmt"
+	"io"
+	"sort"
+)
+
+// Graph is the dependency graph of the services supervised by a
+// ServiceManager.
+type Graph struct {
+	Nodes []GraphNode `json:"nodes"`
+	// Edges point from a service to each of its dependencies.
+	Edges []GraphEdge `json:"edges"`
+}
+
+type GraphNode struct {
+	Name string `json:"name"`
+	// State and WaitingFor are only set for graphs of a running instance,
+	// except for disabled services.
+	State      ServiceState `json:"state,omitempty"`
+	WaitingFor []string     `json:"waitingFor,omitempty"`
+}
+
+type GraphEdge struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// Graph returns the dependency graph of the added services. Only disabled
+// services are annotated with their state.
+func (m *ServiceManager) Graph() *Graph {
+	statuses := make([]ServiceStatus, 0, len(m.services))
+	for _, service := range m.services {
+		statuses = append(statuses, ServiceStatus{
+			Name:         service.Name(),
+			Dependencies: service.Dependencies(),
+		})
+	}
+
+	g := NewGraph(statuses, false)
+	for i := range g.Nodes {
+		if m.isDisabled(g.Nodes[i].Name) {
+			g.Nodes[i].State = StateDisabled
+		}
+	}
+	return g
+}
+
+// NewGraph builds the dependency graph from the status of the services. If
+// `live` is set, the nodes are annotated with the state of the services.
+// Nodes are ordered by name and edges by their endpoints to keep the output
+// stable across runs.
+func NewGraph(statuses []ServiceStatus, live bool) *Graph {
+	g := &Graph{Nodes: []GraphNode{}, Edges: []GraphEdge{}}
+	for _, s := range statuses {
+		node := GraphNode{Name: s.Name}
+		if live {
+			node.State = s.State
+			node.WaitingFor = s.WaitingFor
+		}
+		g.Nodes = append(g.Nodes, node)
+		for _, dependency := range s.Dependencies {
+			g.Edges = append(g.Edges, GraphEdge{From: s.Name, To: dependency})
+		}
+	}
+
+	sort.Slice(g.Nodes, func(i, j int) bool { return g.Nodes[i].Name < g.Nodes[j].Name })
+	sort.Slice(g.Edges, func(i, j int) bool {
+		if g.Edges[i].From != g.Edges[j].From {
+			return g.Edges[i].From < g.Edges[j].From
+		}
+		return g.Edges[i].To < g.Edges[j].To
+	})
+	return g
+}
+
+var stateColors = map[ServiceState]string{
+	StatePending:        "gray",
+	StateWaitingForDeps: "gold",
+	StateStarting:       "lightblue",
+	StateReady:          "palegreen",
+	StateFailed:         "salmon",
+	StateRestarting:     "orange",
+	StateStopping:       "khaki",
+	StateStopped:        "lightgray",
+	StateDisabled:       "white",
+}
+
+// WriteDOT writes the graph in the Graphviz DOT language.
+func (g *Graph) WriteDOT(w io.Writer) error {
+	if _, err := fmt.Fprintln(w, "digraph microshift {"); err != nil {
+		return err
+	}
+	fmt.Fprintln(w, "  rankdir=BT;")
+	fmt.Fprintln(w, "  node [shape=box];")
+	for _, node := range g.Nodes {
+		if node.State == "" {
+			fmt.Fprintf(w, "  %q;\n", node.Name)
+			continue
+		}
+		fmt.Fprintf(w, "  %q [label=%q, style=filled, fillcolor=%q];\n",
+			node.Name, node.Name+"\n"+string(node.State), stateColors[node.State])
+	}
+	for _, edge := range g.Edges {
+		fmt.Fprintf(w, "  %q -> %q;\n", edge.From, edge.To)
+	}
+	_, err := fmt.Fprintln(w, "}")
+	return err
+}
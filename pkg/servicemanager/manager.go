@@ -4,7 +4,11 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"sort"
+	"strings"
+	"sync"
 	"syscall"
+	"time"
 
 	"github.com/openshift/microshift/pkg/util/sigchannel"
 	"k8s.io/klog/v2"
@@ -16,6 +20,16 @@ type ServiceManager struct {
 
 	services   []Service
 	serviceMap map[string]Service
+	created    time.Time
+
+	startupTimeouts map[string]time.Duration
+	stopTimeouts    map[string]time.Duration
+	disabled        map[string]bool
+
+	restartRequests chan RestartRequest
+
+	mu      sync.Mutex
+	runners map[string]*serviceRunner
 }
 
 func NewServiceManager() *ServiceManager {
@@ -25,6 +39,13 @@ func NewServiceManager() *ServiceManager {
 
 		services:   []Service{},
 		serviceMap: make(map[string]Service),
+		created:    time.Now(),
+
+		startupTimeouts: make(map[string]time.Duration),
+		stopTimeouts:    make(map[string]time.Duration),
+		disabled:        make(map[string]bool),
+
+		restartRequests: make(chan RestartRequest, 1),
 	}
 }
 func (s *ServiceManager) Name() string           { return s.name }
@@ -37,160 +58,360 @@ func (m *ServiceManager) AddService(s Service) error {
 	if _, exists := m.serviceMap[s.Name()]; exists {
 		return fmt.Errorf("service '%s' added more than once", s.Name())
 	}
-	for _, dependency := range s.Dependencies() {
-		// Enforce that services can only be added after adding their dependencies,
-		// i.e. they'll always remain topology sorted. Should we want to relax this
-		// constraint later, we can add topo sorting in the Run() step.
-		if _, exists := m.serviceMap[dependency]; !exists {
-			return fmt.Errorf("dependecy '%s' of service '%s' not yet defined", dependency, s.Name())
-		}
-	}
 
+	// Services may be added in any order, dependencies are resolved and
+	// checked for cycles when the manager is validated or run.
 	m.services = append(m.services, s)
 	m.serviceMap[s.Name()] = s
 	return nil
 }
 
+// Validate checks that all dependencies of the added services are defined,
+// that there are no dependency cycles, that no enabled service depends on a
+// disabled one and that startup and stop timeouts are sane.
+func (m *ServiceManager) Validate() error {
+	if _, err := m.topoSort(m.services); err != nil {
+		return err
+	}
+	if err := m.validateDisabled(); err != nil {
+		return err
+	}
+	return m.validateTimeouts()
+}
+
 func (m *ServiceManager) Run(ctx context.Context, ready chan<- struct{}, stopped chan<- struct{}) error {
 	defer close(stopped)
 
-	services := m.services
-	// No need for topological sorting here as long as we enforce order while adding services.
-	// services, err := m.topoSort(services)
-	// if err != nil {
-	// 	fmt.Error("error: %v", err)
-	// }
-
-	readyMap := make(map[string]<-chan struct{})
-	stoppedMap := make(map[string]<-chan struct{})
+	services, err := m.topoSort(m.services)
+	if err != nil {
+		return err
+	}
+	if err := m.validateDisabled(); err != nil {
+		return err
+	}
+	if err := m.validateTimeouts(); err != nil {
+		return err
+	}
 
+	runners := make(map[string]*serviceRunner)
+	enabled := []Service{}
 	for _, service := range services {
-		// Compile a list of ready channels of the service's dependencies (if any).
-		depsReadyList := []<-chan struct{}{}
-		for _, dependency := range service.Dependencies() {
-			depsReadyList = append(depsReadyList, readyMap[dependency])
+		if m.isDisabled(service.Name()) {
+			klog.Infof("Not starting %s, it is disabled", service.Name())
+			continue
 		}
+		enabled = append(enabled, service)
 
-		// Wait until all of the service's dependencies signalled readiness.
-		// If the context gets canceled before, return immediately.
-		select {
-		case <-sigchannel.And(depsReadyList):
-		case <-ctx.Done():
-			return ctx.Err()
+		runner := newServiceRunner(service)
+		runner.startupTimeout = m.startupTimeoutFor(service)
+		runner.stopTimeout = m.stopTimeoutFor(service)
+		for _, dependency := range service.Dependencies() {
+			runner.dependencies = append(runner.dependencies, runners[dependency])
+			runners[dependency].dependents = append(runners[dependency].dependents, runner)
 		}
+		runners[service.Name()] = runner
+	}
 
-		// Start the service and store its ready and stopped channels
-		serviceReady, serviceStopped := m.asyncRun(ctx, service)
-		readyMap[service.Name()] = serviceReady
-		stoppedMap[service.Name()] = serviceStopped
+	m.mu.Lock()
+	m.runners = runners
+	m.mu.Unlock()
+
+	var wg sync.WaitGroup
+	readyList := []<-chan struct{}{}
+	for _, service := range enabled {
+		runner := runners[service.Name()]
+		readyList = append(readyList, runner.firstReady)
+
+		wg.Add(1)
+		klog.WithMicroshiftLoggerComponent(service.Name(), func() {
+			go func() {
+				defer wg.Done()
+				m.supervise(ctx, runner)
+			}()
+		})
 	}
 
 	// If we receive readiness signals from all services, signal readiness of manager
 	go func() {
-		<-sigchannel.And(values(readyMap))
+		<-sigchannel.And(readyList)
 		close(ready)
 	}()
 
-	// Stop manager when all services stopped
-	<-sigchannel.And(values(stoppedMap))
+	// When the manager is canceled, stop services in reverse dependency order
+	// so that no service loses a dependency while it is still running.
+	shutdownDone := make(chan error, 1)
+	go func() {
+		<-ctx.Done()
+		shutdownDone <- m.shutdown(enabled, runners)
+	}()
+
+	// Stop manager when all services stopped, or failed to stop in time
+	exited := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(exited)
+	}()
+	var shutdownErr error
+	select {
+	case <-exited:
+		if ctx.Err() != nil {
+			shutdownErr = <-shutdownDone
+		}
+	case shutdownErr = <-shutdownDone:
+	}
+	if shutdownErr != nil {
+		return shutdownErr
+	}
 	return ctx.Err()
 }
 
-func (m *ServiceManager) asyncRun(ctx context.Context, service Service) (<-chan struct{}, <-chan struct{}) {
-	ready, stopped := make(chan struct{}), make(chan struct{})
-	klog.WithMicroshiftLoggerComponent(service.Name(), func() {
+// shutdown stops the services in reverse dependency order, giving each of
+// them up to its stop timeout, and reports the services that did not stop in
+// time.
+func (m *ServiceManager) shutdown(services []Service, runners map[string]*serviceRunner) error {
+	klog.Infof("Stopping %d services in reverse dependency order", len(services))
+	start := time.Now()
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	notStopped := []string{}
+	for _, service := range services {
+		runner := runners[service.Name()]
+		wg.Add(1)
 		go func() {
-			defer func() {
-				if r := recover(); r != nil {
-					klog.Errorf("%s panicked: %s", service.Name(), r)
-					klog.Error("Stopping MicroShift")
-					syscall.Kill(syscall.Getpid(), syscall.SIGTERM)
-					if !sigchannel.IsClosed(stopped) {
-						close(stopped)
-					}
-				}
-			}()
+			defer wg.Done()
+			if err := runner.stopAfterDependents(); err != nil {
+				klog.Errorf("%v, stopping its dependencies anyway", err)
+				mu.Lock()
+				notStopped = append(notStopped, runner.service.Name())
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
 
-			klog.Infof("Starting %s", service.Name())
-			if err := service.Run(ctx, ready, stopped); err != nil && !errors.Is(err, context.Canceled) {
-				klog.Errorf("service %s exited with error: %s, stopping MicroShift", service.Name(), err)
-				syscall.Kill(syscall.Getpid(), syscall.SIGTERM)
+	if len(notStopped) > 0 {
+		sort.Strings(notStopped)
+		klog.Errorf("Stopped services after %s, %d of %d services did not stop in time: %s",
+			time.Since(start).Round(time.Millisecond), len(notStopped), len(services), strings.Join(notStopped, ", "))
+		return fmt.Errorf("services did not stop in time: %s", strings.Join(notStopped, ", "))
+	}
+	klog.Infof("Stopped all %d services after %s", len(services), time.Since(start).Round(time.Millisecond))
+	return nil
+}
+
+// supervise runs the service as soon as its dependencies are ready and
+// restarts it according to its restart policy until the context gets canceled.
+// Failures that are not covered by the restart policy stop MicroShift, those of
+// services with started SingleRun dependents restart it.
+func (m *ServiceManager) supervise(ctx context.Context, runner *serviceRunner) {
+	defer close(runner.exited)
+	defer runner.stop()
+
+	name := runner.service.Name()
+	policy := runner.policy
+	backoff := policy.Backoff
+	restarts := 0
+
+	for {
+		// Wait until all of the service's dependencies signalled readiness.
+		// If the context gets canceled before, return immediately.
+		runner.setState(StateWaitingForDeps)
+		if !runner.waitForDependencies(ctx) {
+			runner.setState(StateStopped)
+			return
+		}
+
+		runCtx, cancel := context.WithCancel(runner.stopCtx)
+		run := runner.start(cancel)
+		err := m.runOnce(runCtx, runner, run)
+		cancel()
+		dependencyRestart := runner.finish()
+
+		if ctx.Err() != nil {
+			if err != nil && !errors.Is(err, context.Canceled) {
+				klog.Errorf("service %s exited with error: %s, stopping MicroShift", name, err)
+				runner.recordExit(StateFailed, err)
+				stopMicroShift()
 			} else {
-				klog.Infof("%s completed", service.Name())
+				klog.Infof("%s completed", name)
+				runner.recordExit(StateStopped, nil)
 			}
-		}()
-	})
-	return ready, stopped
+			return
+		}
+
+		if dependencyRestart {
+			klog.Infof("Restarting %s because one of its dependencies restarted", name)
+			continue
+		}
+
+		if !policy.shouldRestart(err) {
+			if err != nil {
+				klog.Errorf("service %s exited with error: %s, stopping MicroShift", name, err)
+				runner.recordExit(StateFailed, err)
+				stopMicroShift()
+			} else {
+				klog.Infof("%s completed", name)
+				runner.recordExit(StateStopped, nil)
+			}
+			return
+		}
+
+		if restarts > 0 && runner.readyFor() >= policy.ResetAfter {
+			klog.Infof("%s was ready for %s, resetting its %d restarts", name, policy.ResetAfter, restarts)
+			restarts = 0
+			backoff = policy.Backoff
+		}
+		if policy.MaxRestarts > 0 && restarts >= policy.MaxRestarts {
+			klog.Errorf("service %s exited with error: %v, restart budget of %d exhausted, stopping MicroShift", name, err, policy.MaxRestarts)
+			runner.recordExit(StateFailed, err)
+			stopMicroShift()
+			return
+		}
+		if dependent := runner.singleRunDependent(); dependent != "" {
+			message := fmt.Sprintf("service %s exited with error: %v, %s cannot be restarted with it", name, err, dependent)
+			klog.Errorf("%s, restarting MicroShift", message)
+			runner.recordExit(StateFailed, err)
+			m.RequestRestart(RestartReasonServiceFailed, message)
+			return
+		}
+		restarts++
+
+		runner.markNotReady()
+		runner.recordExit(StateRestarting, err)
+		runner.stopDependents()
+
+		klog.Warningf("service %s exited with error: %v, restarting in %s (restart %d)", name, err, backoff, restarts)
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			runner.setState(StateStopped)
+			return
+		}
+		backoff = policy.nextBackoff(backoff)
+	}
 }
 
-func values(m map[string]<-chan struct{}) []<-chan struct{} {
-	values := make([]<-chan struct{}, 0, len(m))
-	for _, v := range m {
-		values = append(values, v)
+// runOnce runs the service until its Run function returns, turning panics into
+// errors. If the service does not become ready within its startup timeout, it
+// gets canceled and a StartupTimeoutError is returned.
+func (m *ServiceManager) runOnce(ctx context.Context, runner *serviceRunner, run int) (err error) {
+	service := runner.service
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	ready, stopped := make(chan struct{}), make(chan struct{})
+	timedOut := make(chan error, 1)
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		var deadline <-chan time.Time
+		if runner.startupTimeout > 0 {
+			timer := time.NewTimer(runner.startupTimeout)
+			defer timer.Stop()
+			deadline = timer.C
+		}
+
+		select {
+		case <-ready:
+			runner.setReady(run)
+		case <-deadline:
+			timedOut <- &StartupTimeoutError{
+				Service: service.Name(),
+				Timeout: runner.startupTimeout,
+				Blocked: runner.blockedChains(),
+			}
+			cancel()
+		case <-done:
+		}
+	}()
+
+	func() {
+		defer func() {
+			if r := recover(); r != nil {
+				klog.Errorf("%s panicked: %s", service.Name(), r)
+				err = fmt.Errorf("%s panicked: %v", service.Name(), r)
+			}
+		}()
+
+		klog.Infof("Starting %s", service.Name())
+		err = service.Run(ctx, ready, stopped)
+	}()
+
+	select {
+	case timeoutErr := <-timedOut:
+		return timeoutErr
+	default:
+	}
+
+	// Do not lose the readiness signal of services that complete right away.
+	if sigchannel.IsClosed(ready) {
+		runner.setReady(run)
 	}
-	return values
+	return err
+}
+
+func stopMicroShift() {
+	klog.Error("Stopping MicroShift")
+	syscall.Kill(syscall.Getpid(), syscall.SIGTERM)
 }
 
 //---- topological sorting of directed acyclic graphs via DFS traversal -----
 
-// type markers map[Service]bool
-
-// // Find remaining unmarked nodes and visit them until all nodes are marked.
-// func (m *ServiceManager) topoSort(services []Service) ([]Service, error) {
-// 	sorted := []Service{}
-
-// 	permanent := make(markers)
-// 	temporary := make(markers)
-
-// 	for foundUnmarked := true; foundUnmarked; {
-// 		foundUnmarked = false
-// 		for _, service := range services {
-// 			if !marked(service, permanent) {
-// 				if err := m.visit(&sorted, service, permanent, temporary); err != nil {
-// 					return nil, err
-// 				}
-// 				foundUnmarked = true
-// 			}
-// 		}
-// 	}
-
-// 	return sorted, nil
-// }
-
-// func mark(service Service, m markers) {
-// 	m[service] = true
-// }
-
-// func unmark(service Service, m markers) {
-// 	delete(m, service)
-// }
-
-// func marked(service Service, m markers) bool {
-// 	_, ok := m[service]
-// 	return ok
-// }
-
-// // Recursively visit all of a node's dependencies.
-// func (m *ServiceManager) visit(sorted *[]Service, service Service, permanent markers, temporary markers) error {
-// 	if marked(service, permanent) {
-// 		return nil
-// 	}
-// 	if marked(service, temporary) {
-// 		return fmt.Errorf("detected cyclic dependencies")
-// 	}
-
-// 	mark(service, temporary)
-// 	for _, name := range service.Dependencies() {
-// 		dependency, exists := m.serviceMap[name]
-// 		if !exists {
-// 			return fmt.Errorf("unknown dependency '%s' for service '%s'", dependency, name)
-// 		}
-// 		m.visit(sorted, dependency, permanent, temporary)
-// 	}
-// 	unmark(service, temporary)
-
-// 	mark(service, permanent)
-// 	*sorted = append(*sorted, service)
-
-// 	return nil
-// }
+type markers map[string]bool
+
+// topoSort orders the services such that every service comes after all of
+// its dependencies, otherwise keeping the order in which they were added.
+func (m *ServiceManager) topoSort(services []Service) ([]Service, error) {
+	sorted := []Service{}
+
+	permanent := make(markers)
+	temporary := make(markers)
+
+	for _, service := range services {
+		if err := m.visit(&sorted, service, nil, permanent, temporary); err != nil {
+			return nil, err
+		}
+	}
+
+	return sorted, nil
+}
+
+// Recursively visit all of a node's dependencies. `path` holds the chain of
+// services that led to the node and is used for reporting errors.
+func (m *ServiceManager) visit(sorted *[]Service, service Service, path []string, permanent markers, temporary markers) error {
+	name := service.Name()
+	if permanent[name] {
+		return nil
+	}
+
+	path = append(path[:len(path):len(path)], name)
+	if temporary[name] {
+		for i := range path {
+			if path[i] == name {
+				path = path[i:]
+				break
+			}
+		}
+		return fmt.Errorf("detected cyclic dependencies: %s", strings.Join(path, " -> "))
+	}
+
+	temporary[name] = true
+	for _, dependencyName := range service.Dependencies() {
+		dependency, exists := m.serviceMap[dependencyName]
+		if !exists {
+			return fmt.Errorf("unknown dependency '%s' of service '%s': %s",
+				dependencyName, name, strings.Join(append(path[:len(path):len(path)], dependencyName), " -> "))
+		}
+		if err := m.visit(sorted, dependency, path, permanent, temporary); err != nil {
+			return err
+		}
+	}
+	delete(temporary, name)
+
+	permanent[name] = true
+	*sorted = append(*sorted, service)
+
+	return nil
+}
@@ -5,7 +5,11 @@ import (
 	"errors"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"testing"
 	"time"
@@ -36,7 +40,7 @@ func TestAddService(t *testing.T) {
 			serviceTest{service: NewGenericService("foo", nil, nil), out: errors.New("service 'foo' added more than once")},
 		},
 		{
-			serviceTest{service: NewGenericService("bar", []string{"foo"}, nil), out: errors.New("dependecy 'foo' of service 'bar' not yet defined")},
+			serviceTest{service: NewGenericService("bar", []string{"foo"}, nil), out: nil},
 			serviceTest{service: NewGenericService("foo", nil, nil), out: nil},
 		},
 	}
@@ -59,6 +63,119 @@ func TestAddService(t *testing.T) {
 	}
 }
 
+func TestValidate(t *testing.T) {
+	var tests = []struct {
+		name     string
+		services []Service
+		out      error
+	}{
+		{
+			name: "out of order",
+			services: []Service{
+				NewGenericService("baz", []string{"bar"}, nil),
+				NewGenericService("bar", []string{"foo"}, nil),
+				NewGenericService("foo", nil, nil),
+			},
+			out: nil,
+		},
+		{
+			name: "unknown dependency",
+			services: []Service{
+				NewGenericService("baz", []string{"bar"}, nil),
+				NewGenericService("bar", []string{"foo"}, nil),
+			},
+			out: errors.New("unknown dependency 'foo' of service 'bar': baz -> bar -> foo"),
+		},
+		{
+			name: "cycle",
+			services: []Service{
+				NewGenericService("foo", nil, nil),
+				NewGenericService("bar", []string{"foo", "baz"}, nil),
+				NewGenericService("baz", []string{"qux"}, nil),
+				NewGenericService("qux", []string{"bar"}, nil),
+			},
+			out: errors.New("detected cyclic dependencies: bar -> baz -> qux -> bar"),
+		},
+		{
+			name: "self dependency",
+			services: []Service{
+				NewGenericService("foo", []string{"foo"}, nil),
+			},
+			out: errors.New("detected cyclic dependencies: foo -> foo"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewServiceManager()
+			for _, s := range tt.services {
+				if err := m.AddService(s); err != nil {
+					t.Fatalf("failed to add service %s: %v", s.Name(), err)
+				}
+			}
+
+			got := "<nil>"
+			if err := m.Validate(); err != nil {
+				got = err.Error()
+			}
+			want := "<nil>"
+			if tt.out != nil {
+				want = tt.out.Error()
+			}
+			if want != got {
+				t.Errorf("got: %v; want: %v", got, want)
+			}
+		})
+	}
+}
+
+func TestRunOutOfOrder(t *testing.T) {
+	var mu sync.Mutex
+	started := []string{}
+
+	var recordStart = func(name string) RunFunc {
+		return func(ctx context.Context, ready chan<- struct{}, stopped chan<- struct{}) error {
+			defer close(stopped)
+			mu.Lock()
+			started = append(started, name)
+			mu.Unlock()
+			close(ready)
+			return nil
+		}
+	}
+
+	m := NewServiceManager()
+	m.AddService(NewGenericService("baz", []string{"bar", "foo"}, recordStart("baz")))
+	m.AddService(NewGenericService("bar", []string{"foo"}, recordStart("bar")))
+	m.AddService(NewGenericService("foo", nil, recordStart("foo")))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ready, stopped := make(chan struct{}), make(chan struct{})
+	if err := m.Run(ctx, ready, stopped); err != nil {
+		t.Errorf("error running %s: %v", m.Name(), err)
+	}
+
+	if want := []string{"foo", "bar", "baz"}; !reflect.DeepEqual(started, want) {
+		t.Errorf("services started in order %v, want %v", started, want)
+	}
+}
+
+func TestRunCyclicDependencies(t *testing.T) {
+	m := NewServiceManager()
+	m.AddService(NewGenericService("foo", []string{"bar"}, nil))
+	m.AddService(NewGenericService("bar", []string{"foo"}, nil))
+
+	ready, stopped := make(chan struct{}), make(chan struct{})
+	if err := m.Run(context.Background(), ready, stopped); err == nil {
+		t.Errorf("an error about cyclic dependencies was expected from %s", m.Name())
+	}
+	if !sigchannel.IsClosed(stopped) {
+		t.Errorf("stopped channel not closed after failing to run service manager")
+	}
+}
+
 func TestRunToCompletion(t *testing.T) {
 	var wg sync.WaitGroup
 	defer wg.Wait()
@@ -229,3 +346,616 @@ func TestRunToServicePanic(t *testing.T) {
 		t.Errorf("stopped channel not closed after completing service manager")
 	}
 }
+
+func TestRunRestartOnFailure(t *testing.T) {
+	var flakyRuns, barRuns int32
+
+	var waitForContext = func(ctx context.Context, ready chan<- struct{}, stopped chan<- struct{}) error {
+		defer close(stopped)
+		close(ready)
+		<-ctx.Done()
+		return ctx.Err()
+	}
+
+	var failTwice = func(ctx context.Context, ready chan<- struct{}, stopped chan<- struct{}) error {
+		defer close(stopped)
+		close(ready)
+		if atomic.AddInt32(&flakyRuns, 1) < 3 {
+			<-time.After(100 * time.Millisecond)
+			return errors.New("I'm flaky")
+		}
+		<-ctx.Done()
+		return ctx.Err()
+	}
+
+	var countRuns = func(ctx context.Context, ready chan<- struct{}, stopped chan<- struct{}) error {
+		atomic.AddInt32(&barRuns, 1)
+		return waitForContext(ctx, ready, stopped)
+	}
+
+	m := NewServiceManager()
+	m.AddService(NewGenericService("foo", nil, waitForContext))
+	m.AddService(NewGenericService("flaky", []string{"foo"}, failTwice).WithRestartPolicy(RestartPolicy{
+		Mode:        RestartOnFailure,
+		MaxRestarts: 3,
+		Backoff:     10 * time.Millisecond,
+	}))
+	m.AddService(NewGenericService("bar", []string{"flaky"}, countRuns))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	cancelOnSigTerm(cancel, ctx)
+
+	ready, stopped := make(chan struct{}), make(chan struct{})
+	go func() {
+		m.Run(ctx, ready, stopped)
+	}()
+
+	deadline := time.After(5 * time.Second)
+	for atomic.LoadInt32(&barRuns) < 3 {
+		select {
+		case <-deadline:
+			t.Fatalf("timeout waiting for bar to be restarted, runs: %d", atomic.LoadInt32(&barRuns))
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+
+	if ctx.Err() != nil {
+		t.Errorf("%s stopped MicroShift although flaky was within its restart budget", m.Name())
+	}
+	if runs := atomic.LoadInt32(&flakyRuns); runs != 3 {
+		t.Errorf("flaky was run %d times, want 3", runs)
+	}
+
+	cancel()
+	<-stopped
+}
+
+func TestRunRestartResetAfterReady(t *testing.T) {
+	var flakyRuns int32
+
+	var failAfterReady = func(ctx context.Context, ready chan<- struct{}, stopped chan<- struct{}) error {
+		defer close(stopped)
+		close(ready)
+		if atomic.AddInt32(&flakyRuns, 1) < 4 {
+			<-time.After(100 * time.Millisecond)
+			return errors.New("I'm flaky once in a while")
+		}
+		<-ctx.Done()
+		return ctx.Err()
+	}
+
+	m := NewServiceManager()
+	m.AddService(NewGenericService("flaky", nil, failAfterReady).WithRestartPolicy(RestartPolicy{
+		Mode:        RestartOnFailure,
+		MaxRestarts: 1,
+		Backoff:     10 * time.Millisecond,
+		ResetAfter:  50 * time.Millisecond,
+	}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	cancelOnSigTerm(cancel, ctx)
+
+	ready, stopped := make(chan struct{}), make(chan struct{})
+	go func() {
+		m.Run(ctx, ready, stopped)
+	}()
+
+	deadline := time.After(5 * time.Second)
+	for atomic.LoadInt32(&flakyRuns) < 4 {
+		select {
+		case <-deadline:
+			t.Fatalf("timeout waiting for flaky to be restarted, runs: %d", atomic.LoadInt32(&flakyRuns))
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+
+	if ctx.Err() != nil {
+		t.Errorf("%s stopped MicroShift although flaky was ready long enough to reset its restarts", m.Name())
+	}
+
+	cancel()
+	<-stopped
+}
+
+func TestRunRestartWithSingleRunDependent(t *testing.T) {
+	var apiserverRuns int32
+	apiserverStarted := make(chan struct{})
+
+	var failOnce = func(ctx context.Context, ready chan<- struct{}, stopped chan<- struct{}) error {
+		defer close(stopped)
+		close(ready)
+		select {
+		case <-apiserverStarted:
+			return errors.New("I crashed")
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	var countRuns = func(ctx context.Context, ready chan<- struct{}, stopped chan<- struct{}) error {
+		defer close(stopped)
+		if atomic.AddInt32(&apiserverRuns, 1) == 1 {
+			close(apiserverStarted)
+		}
+		close(ready)
+		<-ctx.Done()
+		return ctx.Err()
+	}
+
+	m := NewServiceManager()
+	m.AddService(NewGenericService("etcd", nil, failOnce).WithRestartPolicy(RestartPolicy{
+		Mode:    RestartOnFailure,
+		Backoff: 10 * time.Millisecond,
+	}))
+	m.AddService(NewGenericService("kube-apiserver", []string{"etcd"}, countRuns).WithRestartPolicy(RestartPolicy{
+		Mode:      RestartNever,
+		SingleRun: true,
+	}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ready, stopped := make(chan struct{}), make(chan struct{})
+	go func() {
+		m.Run(ctx, ready, stopped)
+	}()
+
+	select {
+	case request := <-m.RestartRequested():
+		if request.Reason != RestartReasonServiceFailed || !strings.Contains(request.Message, "kube-apiserver cannot be restarted") {
+			t.Errorf("got restart request %+v, want one for etcd failing", request)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("no restart of MicroShift requested after etcd failed")
+	}
+
+	// give the ServiceManager the chance to restart the services in-process
+	time.Sleep(100 * time.Millisecond)
+	if runs := atomic.LoadInt32(&apiserverRuns); runs != 1 {
+		t.Errorf("kube-apiserver was run %d times, want 1", runs)
+	}
+
+	cancel()
+	<-stopped
+}
+
+func TestRunRestartBudgetExhausted(t *testing.T) {
+	var runs int32
+
+	var alwaysFail = func(ctx context.Context, ready chan<- struct{}, stopped chan<- struct{}) error {
+		defer close(stopped)
+		atomic.AddInt32(&runs, 1)
+		return errors.New("I'm always crashing")
+	}
+
+	var waitForContext = func(ctx context.Context, ready chan<- struct{}, stopped chan<- struct{}) error {
+		defer close(stopped)
+		close(ready)
+		<-ctx.Done()
+		return nil
+	}
+
+	m := NewServiceManager()
+	m.AddService(NewGenericService("foo", nil, waitForContext))
+	m.AddService(NewGenericService("bar-crash", []string{"foo"}, alwaysFail).WithRestartPolicy(RestartPolicy{
+		Mode:        RestartOnFailure,
+		MaxRestarts: 2,
+		Backoff:     10 * time.Millisecond,
+	}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	cancelOnSigTerm(cancel, ctx)
+
+	ready, stopped := make(chan struct{}), make(chan struct{})
+	if err := m.Run(ctx, ready, stopped); err == nil {
+		t.Errorf("an error from bar-crash was expected %s: %v", m.Name(), err)
+	}
+
+	if got := atomic.LoadInt32(&runs); got != 3 {
+		t.Errorf("bar-crash was run %d times, want 3", got)
+	}
+
+	if !sigchannel.IsClosed(stopped) {
+		t.Errorf("stopped channel not closed after completing service manager")
+	}
+}
+
+func TestStatus(t *testing.T) {
+	var waitForContext = func(ctx context.Context, ready chan<- struct{}, stopped chan<- struct{}) error {
+		defer close(stopped)
+		close(ready)
+		<-ctx.Done()
+		return nil
+	}
+
+	var runToCompletion = func(ctx context.Context, ready chan<- struct{}, stopped chan<- struct{}) error {
+		defer close(stopped)
+		close(ready)
+		return nil
+	}
+
+	m := NewServiceManager()
+	m.AddService(NewGenericService("foo", nil, waitForContext))
+	m.AddService(NewGenericService("bar", []string{"foo"}, runToCompletion))
+
+	for _, s := range m.Status() {
+		if s.State != StatePending {
+			t.Errorf("service %s is %s before running %s, want %s", s.Name, s.State, m.Name(), StatePending)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	socketPath := filepath.Join(t.TempDir(), "status.sock")
+	served := make(chan struct{})
+	go func() {
+		defer close(served)
+		if err := m.ServeStatus(ctx, socketPath); err != nil {
+			t.Errorf("failed to serve status: %v", err)
+		}
+	}()
+
+	ready, stopped := make(chan struct{}), make(chan struct{})
+	go func() {
+		m.Run(ctx, ready, stopped)
+	}()
+
+	select {
+	case <-ready:
+	case <-time.After(time.Second * 5):
+		t.Fatalf("timeout waiting for %s to become ready", m.Name())
+	}
+
+	want := map[string]ServiceState{"foo": StateReady, "bar": StateStopped}
+	var statuses []ServiceStatus
+	deadline := time.After(5 * time.Second)
+	for {
+		var err error
+		statuses, err = GetStatus(socketPath)
+		if err == nil && len(statuses) == 2 && statuses[0].State == want["foo"] && statuses[1].State == want["bar"] {
+			break
+		}
+		select {
+		case <-deadline:
+			t.Fatalf("timeout waiting for status %v, got: %+v (err: %v)", want, statuses, err)
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+
+	if statuses[0].ReadyAt == nil {
+		t.Errorf("ready service foo reports no readiness timestamp")
+	}
+	if !reflect.DeepEqual(statuses[1].Dependencies, []string{"foo"}) {
+		t.Errorf("got dependencies %v for bar, want [foo]", statuses[1].Dependencies)
+	}
+
+	cancel()
+	<-stopped
+	<-served
+}
+
+func TestRunStartupTimeout(t *testing.T) {
+	var neverReady = func(ctx context.Context, ready chan<- struct{}, stopped chan<- struct{}) error {
+		defer close(stopped)
+		<-ctx.Done()
+		return ctx.Err()
+	}
+
+	var waitForContext = func(ctx context.Context, ready chan<- struct{}, stopped chan<- struct{}) error {
+		defer close(stopped)
+		close(ready)
+		<-ctx.Done()
+		return nil
+	}
+
+	m := NewServiceManager()
+	m.AddService(NewGenericService("foo", nil, waitForContext))
+	m.AddService(NewGenericService("bar-slow", []string{"foo"}, neverReady))
+	m.AddService(NewGenericService("baz", []string{"bar-slow"}, waitForContext))
+	m.SetStartupTimeouts(map[string]time.Duration{"bar-slow": 50 * time.Millisecond})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	cancelOnSigTerm(cancel, ctx)
+
+	ready, stopped := make(chan struct{}), make(chan struct{})
+	m.Run(ctx, ready, stopped)
+
+	if sigchannel.IsClosed(ready) {
+		t.Errorf("ready channel closed although bar-slow never became ready")
+	}
+
+	want := "bar-slow did not become ready within 50ms, blocking bar-slow -> baz"
+	for _, s := range m.Status() {
+		if s.Name == "bar-slow" {
+			if s.State != StateFailed || s.LastError != want {
+				t.Errorf("got state %s with error %q for bar-slow, want %s with error %q", s.State, s.LastError, StateFailed, want)
+			}
+		}
+	}
+}
+
+func TestValidateStartupTimeouts(t *testing.T) {
+	var noop = func(ctx context.Context, ready chan<- struct{}, stopped chan<- struct{}) error {
+		return nil
+	}
+
+	var ttests = []struct {
+		timeouts map[string]time.Duration
+		wantErr  bool
+	}{
+		{map[string]time.Duration{"foo": time.Minute}, false},
+		{map[string]time.Duration{"foo": 0}, false},
+		{map[string]time.Duration{"foo": -time.Minute}, true},
+		{map[string]time.Duration{"bar": time.Minute}, true},
+	}
+
+	for _, tt := range ttests {
+		m := NewServiceManager()
+		m.AddService(NewGenericService("foo", nil, noop))
+		m.SetStartupTimeouts(tt.timeouts)
+		if err := m.Validate(); (err != nil) != tt.wantErr {
+			t.Errorf("Validate() with startup timeouts %v returned error %v, wantErr %v", tt.timeouts, err, tt.wantErr)
+		}
+	}
+}
+
+func TestGraph(t *testing.T) {
+	statuses := []ServiceStatus{
+		{Name: "foo", State: StateReady},
+		{Name: "baz", Dependencies: []string{"foo", "bar"}, State: StateWaitingForDeps, WaitingFor: []string{"bar"}},
+		{Name: "bar", Dependencies: []string{"foo"}, State: StateStarting},
+	}
+
+	var ttests = []struct {
+		live bool
+		want string
+	}{
+		{
+			live: false,
+			want: `digraph microshift {
+  rankdir=BT;
+  node [shape=box];
+  "bar";
+  "baz";
+  "foo";
+  "bar" -> "foo";
+  "baz" -> "bar";
+  "baz" -> "foo";
+}
+`,
+		},
+		{
+			live: true,
+			want: `digraph microshift {
+  rankdir=BT;
+  node [shape=box];
+  "bar" [label="bar\nstarting", style=filled, fillcolor="lightblue"];
+  "baz" [label="baz\nwaiting-for-deps", style=filled, fillcolor="gold"];
+  "foo" [label="foo\nready", style=filled, fillcolor="palegreen"];
+  "bar" -> "foo";
+  "baz" -> "bar";
+  "baz" -> "foo";
+}
+`,
+		},
+	}
+
+	for _, tt := range ttests {
+		var got strings.Builder
+		if err := NewGraph(statuses, tt.live).WriteDOT(&got); err != nil {
+			t.Fatalf("failed to write graph: %v", err)
+		}
+		if got.String() != tt.want {
+			t.Errorf("got graph (live: %v):\n%s\nwant:\n%s", tt.live, got.String(), tt.want)
+		}
+	}
+}
+
+func TestRunDisabledServices(t *testing.T) {
+	var runs int32
+
+	var waitForContext = func(ctx context.Context, ready chan<- struct{}, stopped chan<- struct{}) error {
+		defer close(stopped)
+		atomic.AddInt32(&runs, 1)
+		close(ready)
+		<-ctx.Done()
+		return nil
+	}
+
+	var ttests = []struct {
+		disabled []string
+		wantErr  bool
+	}{
+		{[]string{"baz"}, false},
+		{[]string{"bar", "baz"}, false},
+		{[]string{"bar"}, true},
+		{[]string{"qux"}, true},
+	}
+
+	for _, tt := range ttests {
+		m := NewServiceManager()
+		m.AddService(NewGenericService("foo", nil, waitForContext))
+		m.AddService(NewGenericService("bar", []string{"foo"}, waitForContext))
+		m.AddService(NewGenericService("baz", []string{"bar"}, waitForContext))
+		m.DisableServices(tt.disabled...)
+
+		if err := m.Validate(); (err != nil) != tt.wantErr {
+			t.Errorf("Validate() with disabled services %v returned error %v, wantErr %v", tt.disabled, err, tt.wantErr)
+		}
+		if tt.wantErr {
+			continue
+		}
+
+		atomic.StoreInt32(&runs, 0)
+		ctx, cancel := context.WithCancel(context.Background())
+		ready, stopped := make(chan struct{}), make(chan struct{})
+		go m.Run(ctx, ready, stopped)
+
+		select {
+		case <-ready:
+		case <-time.After(time.Second * 5):
+			t.Fatalf("timeout waiting for %s to become ready", m.Name())
+		}
+		cancel()
+		<-stopped
+
+		if got, want := atomic.LoadInt32(&runs), int32(3-len(tt.disabled)); got != want {
+			t.Errorf("%d services were run with disabled services %v, want %d", got, tt.disabled, want)
+		}
+		for _, s := range m.Status() {
+			if (s.State == StateDisabled) != stringInSlice(s.Name, tt.disabled) {
+				t.Errorf("service %s is %s with disabled services %v", s.Name, s.State, tt.disabled)
+			}
+		}
+	}
+}
+
+func stringInSlice(s string, list []string) bool {
+	for _, x := range list {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
+
+func TestRunStopsInReverseOrder(t *testing.T) {
+	var mu sync.Mutex
+	stopOrder := []string{}
+
+	var recordStop = func(name string) func(ctx context.Context, ready chan<- struct{}, stopped chan<- struct{}) error {
+		return func(ctx context.Context, ready chan<- struct{}, stopped chan<- struct{}) error {
+			defer close(stopped)
+			close(ready)
+			<-ctx.Done()
+			// give services stopped concurrently a chance to record first
+			time.Sleep(10 * time.Millisecond)
+			mu.Lock()
+			stopOrder = append(stopOrder, name)
+			mu.Unlock()
+			return nil
+		}
+	}
+
+	m := NewServiceManager()
+	m.AddService(NewGenericService("baz", []string{"bar"}, recordStop("baz")))
+	m.AddService(NewGenericService("foo", nil, recordStop("foo")))
+	m.AddService(NewGenericService("bar", []string{"foo"}, recordStop("bar")))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ready, stopped := make(chan struct{}), make(chan struct{})
+	go m.Run(ctx, ready, stopped)
+
+	select {
+	case <-ready:
+	case <-time.After(time.Second * 5):
+		t.Fatalf("timeout waiting for %s to become ready", m.Name())
+	}
+	cancel()
+	<-stopped
+
+	if want := []string{"baz", "bar", "foo"}; !reflect.DeepEqual(stopOrder, want) {
+		t.Errorf("services stopped in order %v, want %v", stopOrder, want)
+	}
+}
+
+func TestRestartRequest(t *testing.T) {
+	m := NewServiceManager()
+	m.RequestRestart(RestartReasonClockChanged, "clock changed")
+	m.RequestRestart(RestartReasonIPAddressChanged, "IP address changed")
+
+	var request RestartRequest
+	select {
+	case request = <-m.RestartRequested():
+	default:
+		t.Fatalf("no restart request received")
+	}
+	if request.Reason != RestartReasonClockChanged || request.Message != "clock changed" {
+		t.Errorf("got restart request %+v, want the first one with reason %s", request, RestartReasonClockChanged)
+	}
+
+	path := filepath.Join(t.TempDir(), "restart-request.json")
+	if previous, err := ConsumeRestartRequest(path); previous != nil || err != nil {
+		t.Errorf("got restart request %+v (err: %v) without one being persisted", previous, err)
+	}
+	if err := WriteRestartRequest(path, request); err != nil {
+		t.Fatalf("failed to persist restart request: %v", err)
+	}
+	previous, err := ConsumeRestartRequest(path)
+	if err != nil {
+		t.Fatalf("failed to read persisted restart request: %v", err)
+	}
+	if previous == nil || previous.Reason != request.Reason || previous.Message != request.Message || !previous.Time.Equal(request.Time) {
+		t.Errorf("got persisted restart request %+v, want %+v", previous, request)
+	}
+	if previous, _ := ConsumeRestartRequest(path); previous != nil {
+		t.Errorf("restart request was not removed after reading it")
+	}
+}
+
+func TestRunStopTimeout(t *testing.T) {
+	hang := make(chan struct{})
+	defer close(hang)
+
+	var ignoreCancellation = func(ctx context.Context, ready chan<- struct{}, stopped chan<- struct{}) error {
+		defer close(stopped)
+		close(ready)
+		<-hang
+		return nil
+	}
+
+	var fooStopped int32
+	var waitForContext = func(ctx context.Context, ready chan<- struct{}, stopped chan<- struct{}) error {
+		defer close(stopped)
+		close(ready)
+		<-ctx.Done()
+		atomic.StoreInt32(&fooStopped, 1)
+		return nil
+	}
+
+	m := NewServiceManager()
+	m.AddService(NewGenericService("foo", nil, waitForContext))
+	m.AddService(NewGenericService("bar-hang", []string{"foo"}, ignoreCancellation))
+	m.SetStopTimeouts(map[string]time.Duration{"bar-hang": 50 * time.Millisecond})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ready, stopped := make(chan struct{}), make(chan struct{})
+	errs := make(chan error, 1)
+	go func() {
+		errs <- m.Run(ctx, ready, stopped)
+	}()
+
+	select {
+	case <-ready:
+	case <-time.After(time.Second * 5):
+		t.Fatalf("timeout waiting for %s to become ready", m.Name())
+	}
+	cancel()
+
+	select {
+	case err := <-errs:
+		if want := "services did not stop in time: bar-hang"; err == nil || err.Error() != want {
+			t.Errorf("got error %v from %s, want %q", err, m.Name(), want)
+		}
+	case <-time.After(time.Second * 5):
+		t.Fatalf("timeout waiting for %s to stop", m.Name())
+	}
+
+	if atomic.LoadInt32(&fooStopped) != 1 {
+		t.Errorf("foo was not stopped after bar-hang did not stop in time")
+	}
+	for _, s := range m.Status() {
+		if s.Name == "bar-hang" && (s.State != StateStopping || s.LastError != "bar-hang did not stop within 50ms") {
+			t.Errorf("got state %s with error %q for bar-hang", s.State, s.LastError)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package servicemanager
+
+import (
+	"time"
+)
+
+// RestartMode determines under which circumstances the ServiceManager restarts
+// a service after its Run function returned.
+type RestartMode string
+
+const (
+	// RestartNever stops MicroShift as soon as the service fails.
+	RestartNever RestartMode = "never"
+	// RestartOnFailure restarts the service if it returned an error or panicked.
+	RestartOnFailure RestartMode = "on-failure"
+	// RestartAlways restarts the service whenever it returns, unless MicroShift
+	// is being stopped.
+	RestartAlways RestartMode = "always"
+)
+
+const (
+	defaultRestartBackoff    = time.Second
+	defaultRestartMaxBackoff = time.Minute
+	defaultRestartResetAfter = 10 * time.Minute
+)
+
+// RestartPolicy describes how the ServiceManager reacts to a service exiting.
+type RestartPolicy struct {
+	Mode RestartMode
+	// MaxRestarts is the number of restarts the service is allowed before
+	// the ServiceManager gives up and stops MicroShift. Zero means unlimited.
+	MaxRestarts int
+	// Backoff is the delay before the first restart. It doubles with every
+	// subsequent restart, up to MaxBackoff.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// ResetAfter is the time a run of the service needs to have been ready
+	// for its restarts to be forgiven, resetting the restart count and the
+	// backoff, so that only failures in short succession use up MaxRestarts.
+	// It defaults to 10 minutes.
+	ResetAfter time.Duration
+	// SingleRun marks services that cannot be started twice in the same
+	// process, e.g. because they register global state. If a service they
+	// depend on fails after they were started, MicroShift is restarted
+	// instead of restarting them with it.
+	SingleRun bool
+}
+
+// RestartPolicyProvider is implemented by services that want to be restarted
+// by the ServiceManager instead of stopping MicroShift when they fail.
+// Services not implementing it get the RestartNever policy.
+type RestartPolicyProvider interface {
+	RestartPolicy() RestartPolicy
+}
+
+func restartPolicyFor(s Service) RestartPolicy {
+	p := RestartPolicy{Mode: RestartNever}
+	if provider, ok := s.(RestartPolicyProvider); ok {
+		p = provider.RestartPolicy()
+	}
+	if p.Mode == "" {
+		p.Mode = RestartNever
+	}
+	if p.Backoff <= 0 {
+		p.Backoff = defaultRestartBackoff
+	}
+	if p.MaxBackoff < p.Backoff {
+		p.MaxBackoff = defaultRestartMaxBackoff
+		if p.MaxBackoff < p.Backoff {
+			p.MaxBackoff = p.Backoff
+		}
+	}
+	if p.ResetAfter <= 0 {
+		p.ResetAfter = defaultRestartResetAfter
+	}
+	return p
+}
+
+// shouldRestart returns whether a service that exited with `err` needs to be
+// started again according to the policy.
+func (p RestartPolicy) shouldRestart(err error) bool {
+	switch p.Mode {
+	case RestartAlways:
+		return true
+	case RestartOnFailure:
+		return err != nil
+	default:
+		return false
+	}
+}
+
+// nextBackoff returns the delay to wait before the next restart.
+func (p RestartPolicy) nextBackoff(current time.Duration) time.Duration {
+	next := current * 2
+	if next > p.MaxBackoff {
+		return p.MaxBackoff
+	}
+	return next
+}
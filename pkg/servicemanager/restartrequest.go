@@ -0,0 +1,89 @@
+package servicemanager
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+// RestartReason is a machine-readable reason for restarting MicroShift.
+type RestartReason string
+
+const (
+	RestartReasonIPAddressChanged    RestartReason = "IPAddressChanged"
+	RestartReasonClockChanged        RestartReason = "ClockChanged"
+	RestartReasonCertificateRotation RestartReason = "CertificateRotation"
+	RestartReasonServiceFailed       RestartReason = "ServiceFailed"
+)
+
+// RestartRequest records why and when a restart of MicroShift was requested.
+type RestartRequest struct {
+	Reason  RestartReason `json:"reason"`
+	Message string        `json:"message"`
+	Time    time.Time     `json:"time"`
+}
+
+// RestartRequester is used by services to request MicroShift to be restarted,
+// e.g. when they detect a change to the system they cannot adapt to.
+type RestartRequester interface {
+	RequestRestart(reason RestartReason, message string)
+}
+
+// RequestRestart asks for MicroShift to be restarted. Only the first request
+// is kept, later ones are logged and dropped.
+func (m *ServiceManager) RequestRestart(reason RestartReason, message string) {
+	request := RestartRequest{Reason: reason, Message: message, Time: time.Now()}
+	select {
+	case m.restartRequests <- request:
+		klog.InfoS("Restart of MicroShift requested", "reason", reason, "message", message)
+	default:
+		klog.InfoS("Restart of MicroShift already requested, ignoring", "reason", reason, "message", message)
+	}
+}
+
+// RestartRequested returns a channel that receives the first restart request.
+// The owner of the ServiceManager is expected to cancel its context and start
+// MicroShift again.
+func (m *ServiceManager) RestartRequested() <-chan RestartRequest {
+	return m.restartRequests
+}
+
+// WriteRestartRequest persists the restart request at `path` so the next
+// instance of MicroShift can report why it was restarted.
+func WriteRestartRequest(path string, request RestartRequest) error {
+	data, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0600)
+}
+
+// ConsumeRestartRequest reads and removes the restart request persisted at
+// `path`. It returns nil if there is none, i.e. MicroShift was not restarted
+// on request.
+func ConsumeRestartRequest(path string) (*RestartRequest, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Remove(path); err != nil {
+		return nil, err
+	}
+
+	request := &RestartRequest{}
+	if err := json.Unmarshal(data, request); err != nil {
+		return nil, fmt.Errorf("failed to decode restart request %q: %w", path, err)
+	}
+	return request, nil
+}
@@ -0,0 +1,268 @@
+package servicemanager
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/openshift/microshift/pkg/util/sigchannel"
+)
+
+// serviceRunner keeps track of a single service while it is supervised by
+// the ServiceManager, across restarts.
+type serviceRunner struct {
+	service        Service
+	policy         RestartPolicy
+	startupTimeout time.Duration
+	stopTimeout    time.Duration
+
+	dependencies []*serviceRunner
+	dependents   []*serviceRunner
+
+	// firstReady is closed the first time the service signals readiness.
+	firstReady chan struct{}
+	// stopCtx is the parent context of every run of the service. It gets
+	// canceled by stop once all of the service's dependents have exited.
+	stopCtx context.Context
+	stop    context.CancelFunc
+	// exited is closed once the service is no longer supervised.
+	exited chan struct{}
+	// released is closed during shutdown once the service exited or did not
+	// stop within its stop timeout, allowing its dependencies to be stopped.
+	released chan struct{}
+
+	mu sync.Mutex
+	// ready is closed while the current run of the service is ready. It gets
+	// replaced by a fresh channel when the service is restarted.
+	ready chan struct{}
+	// run identifies the current run, so readiness signalled by a previous run
+	// of the service is ignored.
+	run     int
+	running bool
+	// readySince is when the current run of the service became ready, zero
+	// if it did not yet.
+	readySince time.Time
+	// started is set once the service was run for the first time.
+	started bool
+	cancel  context.CancelFunc
+	// dependencyRestart is set when the current run is stopped because one of
+	// the service's dependencies is being restarted.
+	dependencyRestart bool
+
+	lastStatus ServiceStatus
+}
+
+func newServiceRunner(service Service) *serviceRunner {
+	stopCtx, stop := context.WithCancel(context.Background())
+	return &serviceRunner{
+		service:    service,
+		policy:     restartPolicyFor(service),
+		firstReady: make(chan struct{}),
+		stopCtx:    stopCtx,
+		stop:       stop,
+		exited:     make(chan struct{}),
+		released:   make(chan struct{}),
+		ready:      make(chan struct{}),
+		lastStatus: ServiceStatus{
+			Name:  service.Name(),
+			State: StatePending,
+			Since: time.Now(),
+		},
+	}
+}
+
+func (r *serviceRunner) readyChannel() <-chan struct{} {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.ready
+}
+
+func (r *serviceRunner) isReady() bool {
+	return sigchannel.IsClosed(r.readyChannel())
+}
+
+func (r *serviceRunner) waitingForDependencies() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.lastStatus.State == StateWaitingForDeps
+}
+
+// waitForDependencies blocks until all of the service's dependencies are
+// ready. It returns false if the context got canceled before.
+func (r *serviceRunner) waitForDependencies(ctx context.Context) bool {
+	for {
+		depsReadyList := []<-chan struct{}{}
+		for _, dependency := range r.dependencies {
+			depsReadyList = append(depsReadyList, dependency.readyChannel())
+		}
+
+		select {
+		case <-sigchannel.And(depsReadyList):
+		case <-ctx.Done():
+			return false
+		}
+
+		// A dependency might have been restarted while waiting for the others.
+		allReady := true
+		for _, dependency := range r.dependencies {
+			if !dependency.isReady() {
+				allReady = false
+				break
+			}
+		}
+		if allReady {
+			return true
+		}
+	}
+}
+
+// start registers a new run of the service and returns its identifier.
+func (r *serviceRunner) start(cancel context.CancelFunc) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.run++
+	r.running = true
+	r.readySince = time.Time{}
+	r.started = true
+	r.cancel = cancel
+	r.dependencyRestart = false
+	r.setStateLocked(StateStarting)
+	return r.run
+}
+
+// finish marks the current run as finished and returns whether it was stopped
+// because of a dependency being restarted.
+func (r *serviceRunner) finish() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.running = false
+	r.cancel = nil
+	return r.dependencyRestart
+}
+
+// readyFor returns how long the current or last run of the service has been
+// ready, zero if it did not become ready.
+func (r *serviceRunner) readyFor() time.Duration {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.readySince.IsZero() {
+		return 0
+	}
+	return time.Since(r.readySince)
+}
+
+// setReady signals readiness of the service unless `run` has been superseded.
+func (r *serviceRunner) setReady(run int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if run != r.run || sigchannel.IsClosed(r.ready) {
+		return
+	}
+	close(r.ready)
+	r.readySince = time.Now()
+	r.setStateLocked(StateReady)
+	if !sigchannel.IsClosed(r.firstReady) {
+		close(r.firstReady)
+	}
+}
+
+// markNotReady invalidates the readiness of the service so that its dependents
+// wait for the next run to become ready.
+func (r *serviceRunner) markNotReady() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.markNotReadyLocked()
+}
+
+func (r *serviceRunner) markNotReadyLocked() {
+	r.run++
+	if sigchannel.IsClosed(r.ready) {
+		r.ready = make(chan struct{})
+	}
+}
+
+// stopForDependencyRestart marks the service as not ready and cancels its
+// current run. It returns false if the service was not running.
+func (r *serviceRunner) stopForDependencyRestart() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !r.running {
+		return false
+	}
+	r.markNotReadyLocked()
+	r.dependencyRestart = true
+	r.cancel()
+	r.lastStatus.Restarts++
+	r.setStateLocked(StateRestarting)
+	return true
+}
+
+// stopDependents stops all running services that transitively depend on the
+// service so that they get restarted once it is ready again.
+func (r *serviceRunner) stopDependents() {
+	for _, dependent := range r.dependents {
+		if dependent.stopForDependencyRestart() {
+			dependent.stopDependents()
+		}
+	}
+}
+
+// singleRunDependent returns the name of a started service that transitively
+// depends on the service and cannot be restarted with it, or "" if there is
+// none.
+func (r *serviceRunner) singleRunDependent() string {
+	for _, dependent := range r.dependents {
+		dependent.mu.Lock()
+		started := dependent.started
+		dependent.mu.Unlock()
+		if started && dependent.policy.SingleRun {
+			return dependent.service.Name()
+		}
+		if name := dependent.singleRunDependent(); name != "" {
+			return name
+		}
+	}
+	return ""
+}
+
+// stopAfterDependents stops the service once all services depending on it
+// have been released, so that services are stopped in reverse dependency
+// order. It returns an error if the service did not stop within its stop
+// timeout.
+func (r *serviceRunner) stopAfterDependents() error {
+	defer close(r.released)
+	for _, dependent := range r.dependents {
+		<-dependent.released
+	}
+
+	r.mu.Lock()
+	if r.running {
+		r.setStateLocked(StateStopping)
+	}
+	r.mu.Unlock()
+	r.stop()
+
+	var deadline <-chan time.Time
+	if r.stopTimeout > 0 {
+		timer := time.NewTimer(r.stopTimeout)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+
+	select {
+	case <-r.exited:
+		return nil
+	case <-deadline:
+		err := fmt.Errorf("%s did not stop within %s", r.service.Name(), r.stopTimeout)
+		r.mu.Lock()
+		r.lastStatus.LastError = err.Error()
+		r.mu.Unlock()
+		return err
+	}
+}
@@ -11,7 +11,8 @@ type GenericService struct {
 	name string
 	deps []string
 
-	run RunFunc
+	run           RunFunc
+	restartPolicy RestartPolicy
 }
 
 func NewGenericService(name string, dependencies []string, run RunFunc) *GenericService {
@@ -21,8 +22,15 @@ func NewGenericService(name string, dependencies []string, run RunFunc) *Generic
 		run:  run,
 	}
 }
-func (s *GenericService) Name() string           { return s.name }
-func (s *GenericService) Dependencies() []string { return s.deps }
+func (s *GenericService) Name() string                 { return s.name }
+func (s *GenericService) Dependencies() []string       { return s.deps }
+func (s *GenericService) RestartPolicy() RestartPolicy { return s.restartPolicy }
+
+// WithRestartPolicy sets the policy the ServiceManager applies when the service exits.
+func (s *GenericService) WithRestartPolicy(policy RestartPolicy) *GenericService {
+	s.restartPolicy = policy
+	return s
+}
 
 func (s *GenericService) Run(ctx context.Context, ready chan<- struct{}, stopped chan<- struct{}) error {
 	if s.run == nil {
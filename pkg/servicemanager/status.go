@@ -0,0 +1,116 @@
+package servicemanager
+
+import (
+	"time"
+)
+
+// ServiceState is the lifecycle state of a service supervised by the ServiceManager.
+type ServiceState string
+
+const (
+	StatePending        ServiceState = "pending"
+	StateWaitingForDeps ServiceState = "waiting-for-deps"
+	StateStarting       ServiceState = "starting"
+	StateReady          ServiceState = "ready"
+	StateFailed         ServiceState = "failed"
+	StateRestarting     ServiceState = "restarting"
+	StateStopping       ServiceState = "stopping"
+	StateStopped        ServiceState = "stopped"
+	StateDisabled       ServiceState = "disabled"
+)
+
+// ServiceStatus is a snapshot of the state of a single service.
+type ServiceStatus struct {
+	Name         string       `json:"name"`
+	Dependencies []string     `json:"dependencies,omitempty"`
+	State        ServiceState `json:"state"`
+	// Since is the time of the last state transition.
+	Since     time.Time  `json:"since"`
+	StartedAt *time.Time `json:"startedAt,omitempty"`
+	ReadyAt   *time.Time `json:"readyAt,omitempty"`
+	Restarts  int        `json:"restarts"`
+	LastError string     `json:"lastError,omitempty"`
+	// WaitingFor lists the dependencies that are not ready yet while the
+	// service is waiting for them.
+	WaitingFor []string `json:"waitingFor,omitempty"`
+}
+
+// Status returns the status of all services in the order they were added.
+// Services are reported as pending until the manager runs them, or as
+// disabled if they are not run at all.
+func (m *ServiceManager) Status() []ServiceStatus {
+	m.mu.Lock()
+	runners := m.runners
+	m.mu.Unlock()
+
+	statuses := make([]ServiceStatus, 0, len(m.services))
+	for _, service := range m.services {
+		if runner, ok := runners[service.Name()]; ok {
+			statuses = append(statuses, runner.status())
+			continue
+		}
+		state := StatePending
+		if m.isDisabled(service.Name()) {
+			state = StateDisabled
+		}
+		statuses = append(statuses, ServiceStatus{
+			Name:         service.Name(),
+			Dependencies: service.Dependencies(),
+			State:        state,
+			Since:        m.created,
+		})
+	}
+	return statuses
+}
+
+func (r *serviceRunner) status() ServiceStatus {
+	r.mu.Lock()
+	s := r.lastStatus
+	r.mu.Unlock()
+
+	s.Dependencies = append([]string{}, r.service.Dependencies()...)
+	if s.State == StateWaitingForDeps {
+		for _, dependency := range r.dependencies {
+			if !dependency.isReady() {
+				s.WaitingFor = append(s.WaitingFor, dependency.service.Name())
+			}
+		}
+	}
+	return s
+}
+
+// setStateLocked records a state transition of the service. It must be called
+// with r.mu held.
+func (r *serviceRunner) setStateLocked(state ServiceState) {
+	now := time.Now()
+	r.lastStatus.State = state
+	r.lastStatus.Since = now
+
+	switch state {
+	case StateStarting:
+		r.lastStatus.StartedAt = &now
+		r.lastStatus.ReadyAt = nil
+	case StateReady:
+		r.lastStatus.ReadyAt = &now
+	}
+}
+
+func (r *serviceRunner) setState(state ServiceState) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.setStateLocked(state)
+}
+
+// recordExit records the outcome of a run of the service.
+func (r *serviceRunner) recordExit(state ServiceState, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err != nil {
+		r.lastStatus.LastError = err.Error()
+	}
+	if state == StateRestarting {
+		r.lastStatus.Restarts++
+	}
+	r.setStateLocked(state)
+}
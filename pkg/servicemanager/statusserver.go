@@ -0,0 +1,97 @@
+package servicemanager
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+const (
+	// StatusSocketPath is the unix socket MicroShift exposes the status of its services on.
+	StatusSocketPath = "/run/microshift/status.sock"
+
+	statusPath = "/status"
+)
+
+// ServeStatus exposes the status of the services over a unix socket at
+// `socketPath` until the context gets canceled.
+func (m *ServiceManager) ServeStatus(ctx context.Context, socketPath string) error {
+	if err := os.MkdirAll(filepath.Dir(socketPath), 0700); err != nil {
+		return fmt.Errorf("failed to create directory for status socket: %w", err)
+	}
+	// remove a stale socket left behind by a previous instance
+	if err := os.Remove(socketPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to remove stale status socket %q: %w", socketPath, err)
+	}
+
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		return fmt.Errorf("failed to listen on status socket %q: %w", socketPath, err)
+	}
+	if err := os.Chmod(socketPath, 0600); err != nil {
+		listener.Close()
+		return fmt.Errorf("failed to restrict permissions of status socket %q: %w", socketPath, err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(statusPath, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(m.Status()); err != nil {
+			klog.Warningf("failed to write service status: %v", err)
+		}
+	})
+
+	server := &http.Server{Handler: mux, ReadHeaderTimeout: 5 * time.Second}
+	go func() {
+		<-ctx.Done()
+		server.Close()
+	}()
+
+	klog.Infof("Serving service status on %s", socketPath)
+	if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// GetStatus queries the status of the services of a running MicroShift
+// instance through the unix socket at `socketPath`.
+func GetStatus(socketPath string) ([]ServiceStatus, error) {
+	client := &http.Client{
+		Timeout: 10 * time.Second,
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", socketPath)
+			},
+		},
+	}
+
+	resp, err := client.Get("http://microshift" + statusPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query MicroShift status on %q, is MicroShift running? %w", socketPath, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to query MicroShift status: received http status %d", resp.StatusCode)
+	}
+
+	statuses := []ServiceStatus{}
+	if err := json.NewDecoder(resp.Body).Decode(&statuses); err != nil {
+		return nil, fmt.Errorf("failed to decode MicroShift status: %w", err)
+	}
+	return statuses, nil
+}
@@ -0,0 +1,120 @@
+package servicemanager
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// StartupTimeoutProvider is implemented by services that need to signal
+// readiness within a deadline after being started. The ServiceManager fails
+// services that don't, and applies their restart policy.
+type StartupTimeoutProvider interface {
+	StartupTimeout() time.Duration
+}
+
+// SetStartupTimeouts overrides the startup timeouts declared by the services,
+// keyed by service name. A zero duration disables the deadline.
+func (m *ServiceManager) SetStartupTimeouts(timeouts map[string]time.Duration) {
+	for name, timeout := range timeouts {
+		m.startupTimeouts[name] = timeout
+	}
+}
+
+// StopTimeoutProvider is implemented by services that need more or less time
+// than defaultStopTimeout to stop once they are canceled.
+type StopTimeoutProvider interface {
+	StopTimeout() time.Duration
+}
+
+// defaultStopTimeout is how long the ServiceManager waits for a service to
+// stop before giving up on it and stopping its dependencies.
+const defaultStopTimeout = 15 * time.Second
+
+// SetStopTimeouts overrides the stop timeouts of the services, keyed by
+// service name. A zero duration disables the deadline.
+func (m *ServiceManager) SetStopTimeouts(timeouts map[string]time.Duration) {
+	for name, timeout := range timeouts {
+		m.stopTimeouts[name] = timeout
+	}
+}
+
+func (m *ServiceManager) validateTimeouts() error {
+	if err := m.validateTimeoutMap("startup", m.startupTimeouts); err != nil {
+		return err
+	}
+	return m.validateTimeoutMap("stop", m.stopTimeouts)
+}
+
+func (m *ServiceManager) validateTimeoutMap(kind string, timeouts map[string]time.Duration) error {
+	for name, timeout := range timeouts {
+		if _, exists := m.serviceMap[name]; !exists {
+			return fmt.Errorf("%s timeout configured for unknown service '%s'", kind, name)
+		}
+		if timeout < 0 {
+			return fmt.Errorf("%s timeout of service '%s' must not be negative: %s", kind, name, timeout)
+		}
+	}
+	return nil
+}
+
+func (m *ServiceManager) startupTimeoutFor(s Service) time.Duration {
+	if timeout, ok := m.startupTimeouts[s.Name()]; ok {
+		return timeout
+	}
+	if provider, ok := s.(StartupTimeoutProvider); ok {
+		return provider.StartupTimeout()
+	}
+	return 0
+}
+
+func (m *ServiceManager) stopTimeoutFor(s Service) time.Duration {
+	if timeout, ok := m.stopTimeouts[s.Name()]; ok {
+		return timeout
+	}
+	if provider, ok := s.(StopTimeoutProvider); ok {
+		return provider.StopTimeout()
+	}
+	return defaultStopTimeout
+}
+
+// StartupTimeoutError is returned for services that did not signal readiness
+// within their startup timeout.
+type StartupTimeoutError struct {
+	Service string
+	Timeout time.Duration
+	// Blocked lists the services that were waiting for the service to become ready.
+	Blocked []string
+}
+
+func (e *StartupTimeoutError) Error() string {
+	msg := fmt.Sprintf("%s did not become ready within %s", e.Service, e.Timeout)
+	if len(e.Blocked) > 0 {
+		msg += fmt.Sprintf(", blocking %s", strings.Join(e.Blocked, ", "))
+	}
+	return msg
+}
+
+// blockedChains returns the dependency chains of all services that wait for
+// the service to become ready, e.g. "kube-apiserver -> kube-scheduler".
+func (r *serviceRunner) blockedChains() []string {
+	chains := []string{}
+	var walk func(runner *serviceRunner, path []string)
+	walk = func(runner *serviceRunner, path []string) {
+		path = append(path[:len(path):len(path)], runner.service.Name())
+		waiting := false
+		for _, dependent := range runner.dependents {
+			if dependent.waitingForDependencies() {
+				waiting = true
+				walk(dependent, path)
+			}
+		}
+		if !waiting && len(path) > 1 {
+			chains = append(chains, strings.Join(path, " -> "))
+		}
+	}
+	walk(r, nil)
+	sort.Strings(chains)
+	return chains
+}
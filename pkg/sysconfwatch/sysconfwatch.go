@@ -6,6 +6,7 @@ package sysconfwatch
 import (
 	"context"
 	"github.com/openshift/microshift/pkg/config"
+	"github.com/openshift/microshift/pkg/servicemanager"
 	"time"
 )
 
@@ -33,6 +34,6 @@ func (n *nonLinuxSysConfWatchController) Run(ctx context.Context, ready chan<- s
 	}
 }
 
-func NewSysConfWatchController(cfg *config.MicroshiftConfig) *nonLinuxSysConfWatchController {
+func NewSysConfWatchController(cfg *config.MicroshiftConfig, restarter servicemanager.RestartRequester) *nonLinuxSysConfWatchController {
 	return &nonLinuxSysConfWatchController{}
 }
@@ -18,11 +18,12 @@ package sysconfwatch
 
 import (
 	"context"
+	"fmt"
 	"math"
-	"os"
 	"time"
 
 	"github.com/openshift/microshift/pkg/config"
+	"github.com/openshift/microshift/pkg/servicemanager"
 	"github.com/openshift/microshift/pkg/util"
 	"golang.org/x/sys/unix"
 	"k8s.io/klog/v2"
@@ -32,31 +33,14 @@ const sysConfigCheckInterval = time.Second * 5
 const sysConfigAllowedTimeDrift = time.Second * 10
 
 type SysConfWatchController struct {
-	NodeIP  string
-	timerFd int
+	NodeIP    string
+	restarter servicemanager.RestartRequester
 }
 
-func NewSysConfWatchController(cfg *config.MicroshiftConfig) *SysConfWatchController {
-	// Create a realtime clock timer with asynchronous read support
-	fd, err := unix.TimerfdCreate(unix.CLOCK_REALTIME, unix.TFD_CLOEXEC|unix.TFD_NONBLOCK)
-	if err != nil {
-		klog.Fatalf("failed to create a realtime clock timer %v", err)
-	}
-
-	// Set the time interval into distant future
-	var ptime = &unix.ItimerSpec{
-		Interval: unix.Timespec{Sec: math.MaxInt64, Nsec: 0},
-		Value:    unix.Timespec{Sec: 0, Nsec: 0},
-	}
-	// Start the timer with cancelation if the clock is reset elsewhere
-	err = unix.TimerfdSettime(fd, unix.TFD_TIMER_ABSTIME|unix.TFD_TIMER_CANCEL_ON_SET, ptime, nil)
-	if err != nil {
-		klog.Fatalf("failed to start a realtime clock timer %v", err)
-	}
-
+func NewSysConfWatchController(cfg *config.MicroshiftConfig, restarter servicemanager.RestartRequester) *SysConfWatchController {
 	return &SysConfWatchController{
-		NodeIP:  cfg.NodeIP,
-		timerFd: fd,
+		NodeIP:    cfg.NodeIP,
+		restarter: restarter,
 	}
 }
 
@@ -81,8 +65,37 @@ func getSysMonTimes() (int64, int64) {
 	return stm.Sec, mtm.Sec
 }
 
+// newClockChangeTimer returns a realtime clock timer whose reads fail with
+// ECANCELED once the clock is reset elsewhere.
+func newClockChangeTimer() (int, error) {
+	// Create a realtime clock timer with asynchronous read support
+	fd, err := unix.TimerfdCreate(unix.CLOCK_REALTIME, unix.TFD_CLOEXEC|unix.TFD_NONBLOCK)
+	if err != nil {
+		return -1, fmt.Errorf("failed to create a realtime clock timer: %w", err)
+	}
+
+	// Set the time interval into distant future
+	var ptime = &unix.ItimerSpec{
+		Interval: unix.Timespec{Sec: math.MaxInt64, Nsec: 0},
+		Value:    unix.Timespec{Sec: 0, Nsec: 0},
+	}
+	// Start the timer with cancelation if the clock is reset elsewhere
+	err = unix.TimerfdSettime(fd, unix.TFD_TIMER_ABSTIME|unix.TFD_TIMER_CANCEL_ON_SET, ptime, nil)
+	if err != nil {
+		unix.Close(fd)
+		return -1, fmt.Errorf("failed to start a realtime clock timer: %w", err)
+	}
+	return fd, nil
+}
+
 func (c *SysConfWatchController) Run(ctx context.Context, ready chan<- struct{}, stopped chan<- struct{}) error {
 	defer close(stopped)
+	timerFd, err := newClockChangeTimer()
+	if err != nil {
+		return err
+	}
+	defer unix.Close(timerFd)
+
 	ticker := time.NewTicker(sysConfigCheckInterval)
 	defer ticker.Stop()
 
@@ -101,13 +114,14 @@ func (c *SysConfWatchController) Run(ctx context.Context, ready chan<- struct{},
 			currentIP, _ := util.GetHostIP()
 			if c.NodeIP != currentIP {
 				klog.Warningf("IP address has changed from %q to %q, restarting MicroShift", c.NodeIP, currentIP)
-				os.Exit(0)
+				c.restarter.RequestRestart(servicemanager.RestartReasonIPAddressChanged,
+					fmt.Sprintf("IP address changed from %q to %q", c.NodeIP, currentIP))
 				return nil
 			}
 
 			// Check the clock change by initiating an asynchronous read operation on the timer object
 			// When the clock is reset, the read operation returns with the ECANCELED error code
-			_, err := unix.Read(c.timerFd, buf)
+			_, err := unix.Read(timerFd, buf)
 			if err == unix.ECANCELED {
 				// Take a snapshot of the current system and monototic clocks
 				stimeCur, mtimeCur := getSysMonTimes()
@@ -126,7 +140,8 @@ func (c *SysConfWatchController) Run(ctx context.Context, ready chan<- struct{},
 					mtimeRef = mtimeCur
 				} else {
 					klog.Warningf("realtime clock change detected, time drifted %v seconds, restarting MicroShift", smtDiffDrift)
-					os.Exit(0)
+					c.restarter.RequestRestart(servicemanager.RestartReasonClockChanged,
+						fmt.Sprintf("realtime clock drifted %v seconds", smtDiffDrift))
 					return nil
 				}
 			}
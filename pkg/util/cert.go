@@ -16,17 +16,21 @@ limitations under the License.
 package util
 
 import (
-	"crypto/rand"
+	"crypto"
 	"crypto/rsa"
 	"crypto/x509"
 	"encoding/pem"
 	"fmt"
 	"io/ioutil"
+	"os"
 	"path/filepath"
 	"time"
 
 	"github.com/pkg/errors"
 	"k8s.io/client-go/util/keyutil"
+	"k8s.io/klog/v2"
+
+	"github.com/openshift/microshift/pkg/util/cryptomaterial"
 )
 
 const (
@@ -34,26 +38,38 @@ const (
 	defaultDuration     = defaultDurationDays * 24 * time.Hour
 	defaultHostname     = "localhost"
 
-	keySize = 2048
-
 	ValidityOneDay   = 24 * time.Hour
 	ValidityOneYear  = 365 * ValidityOneDay
 	ValidityTenYears = 10 * ValidityOneYear
 )
 
-// GenKeys generates and save rsa keys
-func GenKeys(dir, pubFilename, keyFilename string) error {
-	rsaKey, err := rsa.GenerateKey(rand.Reader, keySize)
+// EnsureKeys generates and saves a key pair with the algorithm, unless a key
+// pair with the algorithm already exists.
+func EnsureKeys(dir, pubFilename, keyFilename string, alg cryptomaterial.KeyAlgorithm) error {
+	if key, err := keyutil.PrivateKeyFromFile(filepath.Join(dir, keyFilename)); err == nil {
+		if _, err := os.Stat(filepath.Join(dir, pubFilename)); err == nil {
+			if signer, ok := key.(crypto.Signer); ok && cryptomaterial.HasKeyAlgorithm(signer.Public(), alg) {
+				return nil
+			}
+			klog.Infof("Key algorithm of %s changed to %s, regenerating it", filepath.Join(dir, keyFilename), alg)
+		}
+	}
+	return GenKeys(dir, pubFilename, keyFilename, alg)
+}
+
+// GenKeys generates and saves a key pair with the algorithm
+func GenKeys(dir, pubFilename, keyFilename string, alg cryptomaterial.KeyAlgorithm) error {
+	pubKey, key, err := cryptomaterial.GenerateKey(alg)
 	if err != nil {
-		return errors.Wrap(err, "error generating RSA private key")
+		return errors.Wrap(err, "error generating private key")
 	}
 
-	keyPEM, err := keyutil.MarshalPrivateKeyToPEM(rsaKey)
+	keyPEM, err := keyutil.MarshalPrivateKeyToPEM(key)
 	if err != nil {
 		return fmt.Errorf("failed to encode private key to PEM: %v", err)
 	}
 
-	pubPEM, err := PublicKeyToPem(&rsaKey.PublicKey)
+	pubPEM, err := PublicKeyToPem(pubKey)
 	if err != nil {
 		return err
 	}
@@ -70,15 +86,19 @@ func GenKeys(dir, pubFilename, keyFilename string) error {
 	return nil
 }
 
-// PublicKeyToPem converts an rsa.PublicKey object to pem string
-func PublicKeyToPem(key *rsa.PublicKey) ([]byte, error) {
+// PublicKeyToPem converts a public key object to pem string
+func PublicKeyToPem(key crypto.PublicKey) ([]byte, error) {
 	keyInBytes, err := x509.MarshalPKIXPublicKey(key)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to MarshalPKIXPublicKey")
 	}
+	blockType := "PUBLIC KEY"
+	if _, ok := key.(*rsa.PublicKey); ok {
+		blockType = "RSA PUBLIC KEY"
+	}
 	keyinPem := pem.EncodeToMemory(
 		&pem.Block{
-			Type:  "RSA PUBLIC KEY",
+			Type:  blockType,
 			Bytes: keyInBytes,
 		},
 	)
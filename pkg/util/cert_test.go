@@ -0,0 +1,39 @@
+package util
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/openshift/microshift/pkg/util/cryptomaterial"
+)
+
+// tests that existing keys are kept unless the algorithm changes
+func TestEnsureKeys(t *testing.T) {
+	dir := t.TempDir()
+	keyPath := filepath.Join(dir, "sa.key")
+
+	ensure := func(alg cryptomaterial.KeyAlgorithm) []byte {
+		t.Helper()
+		if err := EnsureKeys(dir, "sa.pub", "sa.key", alg); err != nil {
+			t.Fatal(err)
+		}
+		key, err := os.ReadFile(keyPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return key
+	}
+
+	rsaKey := ensure("")
+	if key := ensure(cryptomaterial.RSA2048); string(key) != string(rsaKey) {
+		t.Errorf("expected the existing key to be kept")
+	}
+	ecdsaKey := ensure(cryptomaterial.ECDSAP256)
+	if string(ecdsaKey) == string(rsaKey) {
+		t.Errorf("expected the key to be regenerated for a different algorithm")
+	}
+	if key := ensure(cryptomaterial.ECDSAP256); string(key) != string(ecdsaKey) {
+		t.Errorf("expected the existing key to be kept")
+	}
+}
@@ -35,6 +35,13 @@ func (cs *CertificateChains) GetSigner(signerPath ...string) *CertificateSigner
 	return currentSigner
 }
 
+// IsCustomSigner returns whether the path points to a signer that is a CA
+// supplied by the user. Custom signers are not regenerated by MicroShift.
+func (cs *CertificateChains) IsCustomSigner(signerPath ...string) bool {
+	signer := cs.GetSigner(signerPath...)
+	return signer != nil && signer.IsCustom()
+}
+
 func (cs *CertificateChains) GetCertKey(certPath ...string) ([]byte, []byte, error) {
 	if len(certPath) == 0 {
 		return nil, nil, fmt.Errorf("empty certificate path")
@@ -106,6 +113,18 @@ func (cs *CertificateChains) WalkChains(rootPath []string, fn CertWalkFunc) erro
 	}
 }
 
+// WhenToRotate returns when the certificate is due for rotation. Short-lived
+// certificates are rotated 4 months, long-lived ones 12 months before they
+// expire.
+func WhenToRotate(c *x509.Certificate) time.Time {
+	const month = 30 * time.Hour * 24
+
+	if cryptomaterial.IsCertShortLived(c) {
+		return c.NotAfter.Add(-4 * month)
+	}
+	return c.NotAfter.Add(-12 * month)
+}
+
 func WhenToRotateAtEarliest(cs *CertificateChains) ([]string, time.Time, error) {
 	var (
 		certPath     []string
@@ -113,13 +132,8 @@ func WhenToRotateAtEarliest(cs *CertificateChains) ([]string, time.Time, error)
 	)
 
 	err := cs.WalkChains(nil, func(currentPath []string, c x509.Certificate) error {
-		const month = 30 * time.Hour * 24
-
-		rotateAt := c.NotAfter.Add(-4 * month)
-		if !cryptomaterial.IsCertShortLived(&c) {
-			rotateAt = c.NotAfter.Add(-12 * month)
-		}
-		klog.Errorf("%v rotate at: %s", currentPath, rotateAt.String())
+		rotateAt := WhenToRotate(&c)
+		klog.V(2).Infof("%v rotate at: %s", currentPath, rotateAt.String())
 
 		if rotationDate.IsZero() {
 			rotationDate = rotateAt
@@ -224,3 +224,14 @@ func TestWhenToRotateAtEarliest(t *testing.T) {
 
 	require.True(t, time.Now().Add(4*30*24*time.Hour).Before(rotationTime) && time.Now().Add(7*30*24*time.Hour).After(rotationTime), "the rotate time is at %s", rotationTime.String())
 }
+
+func TestWhenToRotate(t *testing.T) {
+	const day = 24 * time.Hour
+	notBefore := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	shortLived := &x509.Certificate{NotBefore: notBefore, NotAfter: notBefore.Add(365 * day)}
+	require.Equal(t, notBefore.Add(245*day), WhenToRotate(shortLived))
+
+	longLived := &x509.Certificate{NotBefore: notBefore, NotAfter: notBefore.Add(10 * 365 * day)}
+	require.Equal(t, notBefore.Add(3290*day), WhenToRotate(longLived))
+}
@@ -1,13 +1,24 @@
 package certchains
 
 import (
+	"bytes"
+	"crypto/x509"
 	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/openshift/library-go/pkg/crypto"
+	"github.com/openshift/microshift/pkg/util/cryptomaterial"
 )
 
 type CertificateChainsBuilder interface {
 	WithSigners(signers ...CertificateSignerBuilder) CertificateChainsBuilder
 	WithCABundle(bundlePath string, signerNames ...[]string) CertificateChainsBuilder
+	WithKeyAlgorithm(alg cryptomaterial.KeyAlgorithm) CertificateChainsBuilder
+	WithKeyStore(keyStore cryptomaterial.KeyStore, signerNames ...string) CertificateChainsBuilder
 	Complete() (*CertificateChains, error)
+	Load() (*CertificateChains, error)
 }
 
 type certificateChains struct {
@@ -16,6 +27,12 @@ type certificateChains struct {
 	// fileBundles maps fileName -> signers, where fileName is the filename of a CA bundle
 	// where PEM certificates should be stored
 	fileBundles map[string][][]string
+
+	// keyAlgorithm is used by the signers that do not set one
+	keyAlgorithm cryptomaterial.KeyAlgorithm
+
+	// keyStores maps signerName -> the key store holding its key
+	keyStores map[string]cryptomaterial.KeyStore
 }
 
 func NewCertificateChains(signers ...CertificateSignerBuilder) CertificateChainsBuilder {
@@ -23,6 +40,7 @@ func NewCertificateChains(signers ...CertificateSignerBuilder) CertificateChains
 		signers: signers,
 
 		fileBundles: make(map[string][][]string),
+		keyStores:   make(map[string]cryptomaterial.KeyStore),
 	}
 }
 
@@ -36,7 +54,37 @@ func (cs *certificateChains) WithCABundle(bundlePath string, signerNames ...[]st
 	return cs
 }
 
+// WithKeyAlgorithm sets the key algorithm of the signers that do not set one.
+func (cs *certificateChains) WithKeyAlgorithm(alg cryptomaterial.KeyAlgorithm) CertificateChainsBuilder {
+	cs.keyAlgorithm = alg
+	return cs
+}
+
+// WithKeyStore keeps the keys of the root signers with the names in the key
+// store, a nil key store keeps them in files.
+func (cs *certificateChains) WithKeyStore(keyStore cryptomaterial.KeyStore, signerNames ...string) CertificateChainsBuilder {
+	if keyStore == nil {
+		return cs
+	}
+	for _, name := range signerNames {
+		cs.keyStores[name] = keyStore
+	}
+	return cs
+}
+
+// Complete loads the certificate chains, generates the certificates that are
+// missing or stale and writes the CA bundles.
 func (cs *certificateChains) Complete() (*CertificateChains, error) {
+	return cs.complete(false)
+}
+
+// Load loads the certificate chains without generating or writing any
+// certificates, keys or CA bundles. It fails if a certificate is missing.
+func (cs *certificateChains) Load() (*CertificateChains, error) {
+	return cs.complete(true)
+}
+
+func (cs *certificateChains) complete(readOnly bool) (*CertificateChains, error) {
 	completeChains := &CertificateChains{
 		signers: make(map[string]*CertificateSigner),
 	}
@@ -47,25 +95,75 @@ func (cs *certificateChains) Complete() (*CertificateChains, error) {
 			return nil, fmt.Errorf("signer name clash: %s", signer.Name())
 		}
 
-		completedSigner, err := signer.Complete()
-		if err != nil {
+		if signer.KeyAlgorithm() == "" {
+			signer = signer.WithKeyAlgorithm(cs.keyAlgorithm)
+		}
+		if keyStore, ok := cs.keyStores[signer.Name()]; ok {
+			signer = signer.WithKeyStore(keyStore)
+		}
+
+		var completedSigner *CertificateSigner
+		var err error
+		if readOnly {
+			if completedSigner, err = signer.Load(); err != nil {
+				return nil, fmt.Errorf("failed to load signer %q: %w", signer.Name(), err)
+			}
+		} else if completedSigner, err = signer.Complete(); err != nil {
 			return nil, fmt.Errorf("failed to complete signer %q: %w", signer.Name(), err)
 		}
 		completeChains.signers[completedSigner.signerName] = completedSigner
 	}
 
+	for signerName := range cs.keyStores {
+		if _, ok := completeChains.signers[signerName]; !ok {
+			return nil, NewSignerNotFound(signerName)
+		}
+	}
+	if readOnly {
+		return completeChains, nil
+	}
+
+	// the bundles are written from scratch so that they do not keep trusting
+	// CAs that were replaced, e.g. by a custom CA
 	for bundle, signers := range cs.fileBundles {
+		certs := []*x509.Certificate{}
+		signerObjs := []*CertificateSigner{}
 		for _, s := range signers {
 			signerObj := completeChains.GetSigner(s...)
 			if signerObj == nil {
-				return nil, NewSignerNotFound(signerObj.signerName)
+				return nil, NewSignerNotFound(strings.Join(s, "/"))
 			}
+			certs = append(certs, signerObj.signerConfig.Config.Certs[0])
+			signerObjs = append(signerObjs, signerObj)
+		}
 
-			if err := signerObj.AddToBundles(bundle); err != nil {
-				return nil, fmt.Errorf("failed adding the signer %q to CA bundle %q: %v", signerObj.signerName, bundle, err)
-			}
+		if err := writeCABundle(bundle, certs); err != nil {
+			return nil, fmt.Errorf("failed to write CA bundle %q: %v", bundle, err)
+		}
+		for _, signerObj := range signerObjs {
+			signerObj.caBundlePaths.Insert(bundle)
 		}
 	}
 
 	return completeChains, nil
 }
+
+// writeCABundle writes the certificates to the bundle unless it already
+// contains exactly them.
+func writeCABundle(bundlePath string, certs []*x509.Certificate) error {
+	bundlePEM, err := crypto.EncodeCertificates(certs...)
+	if err != nil {
+		return err
+	}
+
+	if existing, err := os.ReadFile(bundlePath); err == nil && bytes.Equal(existing, bundlePEM) {
+		return nil
+	} else if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(bundlePath), os.FileMode(0755)); err != nil {
+		return err
+	}
+	return os.WriteFile(bundlePath, bundlePEM, 0644)
+}
@@ -3,10 +3,12 @@ package certchains
 import (
 	"crypto/x509"
 	"encoding/pem"
+	"os"
 	"path/filepath"
 	"reflect"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 
@@ -144,3 +146,113 @@ func pemToCert(t *testing.T, certPEM []byte) *x509.Certificate {
 func breakTestCertPath(testPath string) []string {
 	return strings.Split(testPath, "/")
 }
+
+// tests that switching a signer to a custom CA re-signs its certificates and
+// replaces the generated CA in the bundles
+func Test_certificateChains_CompleteCustomCA(t *testing.T) {
+	tmpDir := t.TempDir()
+	customCertPath := filepath.Join(tmpDir, "custom", "ca.crt")
+	customKeyPath := filepath.Join(tmpDir, "custom", "ca.key")
+	customCA, err := crypto.MakeSelfSignedCA(customCertPath, customKeyPath, "", "custom-ca", 365)
+	require.NoError(t, err)
+
+	bundlePath := filepath.Join(tmpDir, "bundle.crt")
+	newChains := func(custom bool) *CertificateChains {
+		signer := NewCertificateSigner("signer", filepath.Join(tmpDir, "signer"), 365).
+			WithClientCertificates(&ClientCertificateSigningRequestInfo{
+				CSRMeta:  CSRMeta{Name: "client", ValidityDays: 30},
+				UserInfo: &user.DefaultInfo{Name: "client"},
+			})
+		if custom {
+			signer = signer.WithCustomCA(customCertPath, customKeyPath)
+		}
+		chains, err := NewCertificateChains(signer).WithCABundle(bundlePath, []string{"signer"}).Complete()
+		require.NoError(t, err)
+		return chains
+	}
+
+	generated := newChains(false)
+	require.False(t, generated.IsCustomSigner("signer"))
+
+	custom := newChains(true)
+	require.True(t, custom.IsCustomSigner("signer"))
+	certPEM, _, err := custom.GetCertKey("signer", "client")
+	require.NoError(t, err)
+	certs, err := crypto.CertsFromPEM(certPEM)
+	require.NoError(t, err)
+	require.NoError(t, certs[0].CheckSignatureFrom(customCA.Config.Certs[0]), "the certificate was not signed by the custom CA")
+
+	bundlePEM, err := os.ReadFile(bundlePath)
+	require.NoError(t, err)
+	bundleCerts, err := crypto.CertsFromPEM(bundlePEM)
+	require.NoError(t, err)
+	require.Len(t, bundleCerts, 1)
+	require.True(t, bundleCerts[0].Equal(customCA.Config.Certs[0]))
+
+	// the custom CA itself is never regenerated
+	require.NoError(t, custom.Regenerate("signer"))
+	require.True(t, custom.GetSigner("signer").signerConfig.Config.Certs[0].Equal(customCA.Config.Certs[0]))
+}
+
+// tests that loading the chains reads the certificates Complete generated
+// without writing anything
+func Test_certificateChains_Load(t *testing.T) {
+	tmpDir := t.TempDir()
+	bundlePath := filepath.Join(tmpDir, "bundle.crt")
+	newChains := func() CertificateChainsBuilder {
+		return NewCertificateChains(
+			NewCertificateSigner("root", filepath.Join(tmpDir, "root"), 10).
+				WithSubCAs(
+					NewCertificateSigner("sub", filepath.Join(tmpDir, "root", "sub"), 5).
+						WithPeerCertificiates(&PeerCertificateSigningRequestInfo{
+							CSRMeta:   CSRMeta{Name: "peer", ValidityDays: 1},
+							UserInfo:  &user.DefaultInfo{Name: "peer"},
+							Hostnames: []string{"peer.local"},
+						}),
+				).
+				WithClientCertificates(&ClientCertificateSigningRequestInfo{
+					CSRMeta:  CSRMeta{Name: "client", ValidityDays: 1},
+					UserInfo: &user.DefaultInfo{Name: "client"},
+				}).
+				WithServingCertificates(&ServingCertificateSigningRequestInfo{
+					CSRMeta:   CSRMeta{Name: "server", ValidityDays: 1},
+					Hostnames: []string{"server.local"},
+				}),
+		).WithCABundle(bundlePath, []string{"root"})
+	}
+	walk := func(cs *CertificateChains) map[string]string {
+		certs := map[string]string{}
+		require.NoError(t, cs.WalkChains(nil, func(certPath []string, c x509.Certificate) error {
+			certs[strings.Join(certPath, "/")] = c.SerialNumber.String()
+			return nil
+		}))
+		return certs
+	}
+
+	_, err := newChains().Load()
+	require.Error(t, err, "loading missing certificates must fail")
+	_, err = os.Stat(filepath.Join(tmpDir, "root"))
+	require.True(t, os.IsNotExist(err), "loading missing certificates must not generate them")
+
+	completed, err := newChains().Complete()
+	require.NoError(t, err)
+	require.NoError(t, os.Remove(bundlePath))
+	files := map[string]time.Time{}
+	require.NoError(t, filepath.Walk(tmpDir, func(path string, info os.FileInfo, err error) error {
+		files[path] = info.ModTime()
+		return err
+	}))
+
+	loaded, err := newChains().Load()
+	require.NoError(t, err)
+	require.Equal(t, walk(completed), walk(loaded))
+	_, _, err = loaded.GetCertKey("root", "sub", "peer")
+	require.NoError(t, err)
+
+	_, err = os.Stat(bundlePath)
+	require.True(t, os.IsNotExist(err), "loading the chains must not write CA bundles")
+	require.NoError(t, filepath.Walk(tmpDir, func(path string, info os.FileInfo, err error) error {
+		require.Equal(t, files[path], info.ModTime(), "loading the chains modified %s", path)
+		return err
+	}))
+}
@@ -0,0 +1,405 @@
+package certchains
+
+import (
+	stdcrypto "crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"os"
+	"path/filepath"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/klog/v2"
+
+	"github.com/openshift/library-go/pkg/crypto"
+	"github.com/openshift/microshift/pkg/util/cryptomaterial"
+)
+
+// The functions below follow their library-go counterparts, which only
+// generate 2048-bit RSA keys and only sign with RSA keys.
+
+// ensureCA loads the CA from the files or creates a self-signed one with a
+// key of the given algorithm.
+func ensureCA(certFile, keyFile, serialFile, name string, expireDays int, alg cryptomaterial.KeyAlgorithm) (*crypto.CA, error) {
+	if ca, err := crypto.GetCA(certFile, keyFile, serialFile); err == nil {
+		return ca, nil
+	}
+
+	klog.V(2).Infof("Generating new %s CA for %s cert, and key in %s, %s", alg, name, certFile, keyFile)
+	publicKey, privateKey, err := cryptomaterial.GenerateKey(alg)
+	if err != nil {
+		return nil, err
+	}
+	caConfig, err := makeCAConfig(name, time.Duration(expireDays)*24*time.Hour, nil, publicKey, privateKey)
+	if err != nil {
+		return nil, err
+	}
+	return writeCA(caConfig, certFile, keyFile, serialFile)
+}
+
+// ensureKeyStoreCA loads the CA from the certificate file and its key from
+// the key store, where the key is labeled with the CA's name, or creates a
+// self-signed one with a key generated in the key store if there is no
+// certificate yet or the CA kept its key in a file so far. The key never
+// touches the filesystem, the key file of a previous CA is removed.
+func ensureKeyStoreCA(keyStore cryptomaterial.KeyStore, certFile, keyFile, serialFile, name string, expireDays int, alg cryptomaterial.KeyAlgorithm) (*crypto.CA, error) {
+	if ca, err := getKeyStoreCA(keyStore, certFile, keyFile, serialFile, name); err != nil {
+		return nil, err
+	} else if ca != nil {
+		return ca, nil
+	}
+
+	klog.V(2).Infof("Generating new %s CA for %s cert in %s, and key in the key store", alg, name, certFile)
+	if err := os.Remove(keyFile); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	publicKey, privateKey, err := keyStore.GenerateKey(name, alg)
+	if err != nil {
+		return nil, err
+	}
+	caConfig, err := makeCAConfig(name, time.Duration(expireDays)*24*time.Hour, nil, publicKey, privateKey)
+	if err != nil {
+		return nil, err
+	}
+	return writeCA(caConfig, certFile, "", serialFile)
+}
+
+// comparablePublicKey is implemented by the public keys of the standard
+// library.
+type comparablePublicKey interface {
+	Equal(stdcrypto.PublicKey) bool
+}
+
+// getKeyStoreCA returns nil if the CA needs to be generated: its certificate
+// is missing, its key is still in keyFile, or the key with the CA's name does
+// not belong to the certificate. It fails if the key store has no key for an
+// existing certificate, e.g. because the wrong token is configured, rather
+// than replacing the CA and every certificate it issued.
+func getKeyStoreCA(keyStore cryptomaterial.KeyStore, certFile, keyFile, serialFile, name string) (*crypto.CA, error) {
+	certPEM, err := os.ReadFile(certFile)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	certs, err := crypto.CertsFromPEM(certPEM)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse CA certificate %s: %w", certFile, err)
+	}
+
+	key, err := keyStore.FindKey(name)
+	if err != nil {
+		return nil, err
+	}
+	if key == nil {
+		if _, err := os.Stat(keyFile); err == nil {
+			klog.Infof("Moving the key of CA %s from %s to the key store", name, keyFile)
+			return nil, nil
+		}
+		return nil, fmt.Errorf("key %q of CA certificate %s not found in the key store, remove the certificate to generate a new CA", name, certFile)
+	}
+	if publicKey, ok := key.Public().(comparablePublicKey); !ok || !publicKey.Equal(certs[0].PublicKey) {
+		klog.Infof("Key of CA %s does not match its certificate %s, regenerating it", name, certFile)
+		return nil, nil
+	}
+
+	serialGenerator, err := crypto.NewSerialFileGenerator(serialFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load serial file of CA %s: %w", name, err)
+	}
+	return &crypto.CA{
+		Config:          &crypto.TLSCertificateConfig{Certs: certs, Key: key},
+		SerialGenerator: serialGenerator,
+	}, nil
+}
+
+// ensureRootCA loads or creates the self-signed CA of a signer in its
+// directory, with its key in the key store if there is one.
+func ensureRootCA(signerDir, name string, expireDays int, alg cryptomaterial.KeyAlgorithm, keyStore cryptomaterial.KeyStore) (*crypto.CA, error) {
+	if keyStore != nil {
+		return ensureKeyStoreCA(
+			keyStore,
+			cryptomaterial.CACertPath(signerDir),
+			cryptomaterial.CAKeyPath(signerDir),
+			cryptomaterial.CASerialsPath(signerDir),
+			name,
+			expireDays,
+			alg,
+		)
+	}
+	return ensureCA(
+		cryptomaterial.CACertPath(signerDir),
+		cryptomaterial.CAKeyPath(signerDir),
+		cryptomaterial.CASerialsPath(signerDir),
+		name,
+		expireDays,
+		alg,
+	)
+}
+
+// loadRootCA loads the self-signed CA of a signer from its directory, with
+// its key from the key store if there is one.
+func loadRootCA(signerDir, name string, keyStore cryptomaterial.KeyStore) (*crypto.CA, error) {
+	if keyStore == nil {
+		return crypto.GetCA(
+			cryptomaterial.CACertPath(signerDir),
+			cryptomaterial.CAKeyPath(signerDir),
+			cryptomaterial.CASerialsPath(signerDir),
+		)
+	}
+
+	ca, err := getKeyStoreCA(
+		keyStore,
+		cryptomaterial.CACertPath(signerDir),
+		cryptomaterial.CAKeyPath(signerDir),
+		cryptomaterial.CASerialsPath(signerDir),
+		name,
+	)
+	if err == nil && ca == nil {
+		err = fmt.Errorf("no CA with a key in the key store found in %s", signerDir)
+	}
+	return ca, err
+}
+
+// ensureSubCA loads the sub-CA from the files or creates one signed by `ca`.
+func ensureSubCA(ca *crypto.CA, certFile, keyFile, serialFile, name string, expireDays int, alg cryptomaterial.KeyAlgorithm) (*crypto.CA, error) {
+	if subCA, err := crypto.GetCA(certFile, keyFile, serialFile); err == nil {
+		return subCA, nil
+	}
+
+	klog.V(4).Infof("Generating %s sub-CA certificate in %s, key in %s, serial in %s", alg, certFile, keyFile, serialFile)
+	publicKey, privateKey, err := cryptomaterial.GenerateKey(alg)
+	if err != nil {
+		return nil, err
+	}
+	subCAConfig, err := makeCAConfig(name, time.Duration(expireDays)*24*time.Hour, ca, publicKey, privateKey)
+	if err != nil {
+		return nil, err
+	}
+	return writeCA(subCAConfig, certFile, keyFile, serialFile)
+}
+
+// writeCA writes the CA's certificate, and its key unless keyFile is empty.
+func writeCA(caConfig *crypto.TLSCertificateConfig, certFile, keyFile, serialFile string) (*crypto.CA, error) {
+	if len(keyFile) > 0 {
+		if err := caConfig.WriteCertConfigFile(certFile, keyFile); err != nil {
+			return nil, err
+		}
+	} else if err := writeCertificates(certFile, caConfig.Certs...); err != nil {
+		return nil, err
+	}
+
+	var serialGenerator crypto.SerialGenerator = &crypto.RandomSerialGenerator{}
+	if len(serialFile) > 0 {
+		// create / overwrite the serial file with a zero padded hex value (ending in a newline to have a valid file)
+		if err := os.WriteFile(serialFile, []byte("00\n"), 0644); err != nil {
+			return nil, err
+		}
+
+		var err error
+		serialGenerator, err = crypto.NewSerialFileGenerator(serialFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &crypto.CA{
+		Config:          caConfig,
+		SerialGenerator: serialGenerator,
+	}, nil
+}
+
+// makeCAConfig creates a CA with the key signed by `issuer`, or a self-signed
+// one if it is nil.
+func makeCAConfig(name string, lifetime time.Duration, issuer *crypto.CA, publicKey stdcrypto.PublicKey, privateKey stdcrypto.Signer) (*crypto.TLSCertificateConfig, error) {
+	template := &x509.Certificate{
+		Subject: pkix.Name{CommonName: name},
+
+		NotBefore: time.Now().Add(-1 * time.Second),
+		NotAfter:  time.Now().Add(lifetime),
+
+		// Specify a random serial number to avoid the same issuer+serial
+		// number referring to different certs in a chain of trust if the
+		// signing certificate is ever rotated.
+		SerialNumber: big.NewInt(randomSerialNumber()),
+
+		KeyUsage:              keyUsage(publicKey) | x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	if issuer == nil {
+		cert, err := createCertificate(template, template, publicKey, privateKey)
+		if err != nil {
+			return nil, err
+		}
+		return &crypto.TLSCertificateConfig{Certs: []*x509.Certificate{cert}, Key: privateKey}, nil
+	}
+
+	cert, err := signCertificate(issuer, template, publicKey)
+	if err != nil {
+		return nil, err
+	}
+	return &crypto.TLSCertificateConfig{
+		Certs: append([]*x509.Certificate{cert}, issuer.Config.Certs...),
+		Key:   privateKey,
+	}, nil
+}
+
+// ensureServerCert loads the serving certificate from the files unless it is
+// missing some of the hostnames, and creates one otherwise.
+func ensureServerCert(ca *crypto.CA, certFile, keyFile string, hostnames sets.String, expireDays int, alg cryptomaterial.KeyAlgorithm) (*crypto.TLSCertificateConfig, error) {
+	if certConfig, err := crypto.GetServerCert(certFile, keyFile, hostnames); err == nil {
+		return certConfig, nil
+	}
+
+	klog.V(4).Infof("Generating server certificate in %s, key in %s", certFile, keyFile)
+	certConfig, err := makeServerCert(ca, hostnames, time.Duration(expireDays)*24*time.Hour, alg)
+	if err != nil {
+		return nil, err
+	}
+	if err := certConfig.WriteCertConfigFile(certFile, keyFile); err != nil {
+		return nil, err
+	}
+	return certConfig, nil
+}
+
+// makeServerCert creates a serving certificate for the hostnames, the
+// functions may modify the certificate template before it is signed.
+func makeServerCert(ca *crypto.CA, hostnames sets.String, lifetime time.Duration, alg cryptomaterial.KeyAlgorithm, fns ...crypto.CertificateExtensionFunc) (*crypto.TLSCertificateConfig, error) {
+	publicKey, privateKey, err := cryptomaterial.GenerateKey(alg)
+	if err != nil {
+		return nil, err
+	}
+
+	template := &x509.Certificate{
+		Subject: pkix.Name{CommonName: hostnames.List()[0]},
+
+		NotBefore:    time.Now().Add(-1 * time.Second),
+		NotAfter:     time.Now().Add(lifetime),
+		SerialNumber: big.NewInt(1),
+
+		KeyUsage:              keyUsage(publicKey),
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+	}
+	template.IPAddresses, template.DNSNames = crypto.IPAddressesDNSNames(hostnames.List())
+	for _, fn := range fns {
+		if err := fn(template); err != nil {
+			return nil, err
+		}
+	}
+
+	cert, err := signCertificate(ca, template, publicKey)
+	if err != nil {
+		return nil, err
+	}
+	return &crypto.TLSCertificateConfig{
+		Certs: append([]*x509.Certificate{cert}, ca.Config.Certs...),
+		Key:   privateKey,
+	}, nil
+}
+
+// ensureClientCertificate loads the client certificate from the files or
+// creates one for the user.
+func ensureClientCertificate(ca *crypto.CA, certFile, keyFile string, u user.Info, expireDays int, alg cryptomaterial.KeyAlgorithm) (*crypto.TLSCertificateConfig, error) {
+	if certConfig, err := crypto.GetTLSCertificateConfig(certFile, keyFile); err == nil {
+		return certConfig, nil
+	}
+
+	klog.V(4).Infof("Generating client cert in %s and key in %s", certFile, keyFile)
+	certConfig, err := makeClientCertificate(ca, u, time.Duration(expireDays)*24*time.Hour, alg)
+	if err != nil {
+		return nil, err
+	}
+	if err := certConfig.WriteCertConfigFile(certFile, keyFile); err != nil {
+		return nil, err
+	}
+	return certConfig, nil
+}
+
+func makeClientCertificate(ca *crypto.CA, u user.Info, lifetime time.Duration, alg cryptomaterial.KeyAlgorithm) (*crypto.TLSCertificateConfig, error) {
+	publicKey, privateKey, err := cryptomaterial.GenerateKey(alg)
+	if err != nil {
+		return nil, err
+	}
+
+	template := &x509.Certificate{
+		Subject: userToSubject(u),
+
+		NotBefore:    time.Now().Add(-1 * time.Second),
+		NotAfter:     time.Now().Add(lifetime),
+		SerialNumber: big.NewInt(1),
+
+		KeyUsage:              keyUsage(publicKey),
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+	}
+
+	cert, err := signCertificate(ca, template, publicKey)
+	if err != nil {
+		return nil, err
+	}
+	return &crypto.TLSCertificateConfig{Certs: []*x509.Certificate{cert}, Key: privateKey}, nil
+}
+
+// keyUsage returns the key usage of certificates for the key, key
+// encipherment only applies to RSA keys.
+func keyUsage(publicKey interface{}) x509.KeyUsage {
+	if _, ok := publicKey.(*rsa.PublicKey); ok {
+		return x509.KeyUsageKeyEncipherment | x509.KeyUsageDigitalSignature
+	}
+	return x509.KeyUsageDigitalSignature
+}
+
+// signCertificate signs the template with the CA, using the next serial
+// number of the CA. The signature algorithm follows from the CA's key.
+func signCertificate(ca *crypto.CA, template *x509.Certificate, publicKey interface{}) (*x509.Certificate, error) {
+	serial, err := ca.SerialGenerator.Next(template)
+	if err != nil {
+		return nil, err
+	}
+	template.SerialNumber = big.NewInt(serial)
+	return createCertificate(template, ca.Config.Certs[0], publicKey, ca.Config.Key)
+}
+
+func createCertificate(template, parent *x509.Certificate, publicKey, signerKey interface{}) (*x509.Certificate, error) {
+	derBytes, err := x509.CreateCertificate(rand.Reader, template, parent, publicKey, signerKey)
+	if err != nil {
+		return nil, err
+	}
+	certs, err := x509.ParseCertificates(derBytes)
+	if err != nil {
+		return nil, err
+	}
+	if len(certs) != 1 {
+		return nil, errors.New("expected a single certificate")
+	}
+	return certs[0], nil
+}
+
+func writeCertificates(certFile string, certs ...*x509.Certificate) error {
+	certPEM, err := crypto.EncodeCertificates(certs...)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(certFile), os.FileMode(0755)); err != nil {
+		return err
+	}
+	return os.WriteFile(certFile, certPEM, os.FileMode(0644))
+}
+
+func randomSerialNumber() int64 {
+	r, err := rand.Int(rand.Reader, big.NewInt(math.MaxInt64-1))
+	if err != nil {
+		panic(err)
+	}
+	return r.Int64() + 1
+}
@@ -13,6 +13,7 @@ type SignerInfo interface {
 	Name() string
 	Directory() string
 	ValidityDays() int
+	KeyAlgorithm() cryptomaterial.KeyAlgorithm
 }
 
 type CertificateSignerBuilder interface {
@@ -24,7 +25,11 @@ type CertificateSignerBuilder interface {
 	WithServingCertificates(signInfos ...*ServingCertificateSigningRequestInfo) CertificateSignerBuilder
 	WithPeerCertificiates(signInfos ...*PeerCertificateSigningRequestInfo) CertificateSignerBuilder
 	WithCABundlePaths(bundlePath ...string) CertificateSignerBuilder
+	WithCustomCA(certPath, keyPath string) CertificateSignerBuilder
+	WithKeyAlgorithm(alg cryptomaterial.KeyAlgorithm) CertificateSignerBuilder
+	WithKeyStore(keyStore cryptomaterial.KeyStore) CertificateSignerBuilder
 	Complete() (*CertificateSigner, error)
+	Load() (*CertificateSigner, error)
 }
 
 type certificateSigner struct {
@@ -40,6 +45,18 @@ type certificateSigner struct {
 
 	// locations of bundles where this signer appears
 	caBundlePaths []string
+
+	// customCertPath and customKeyPath point to a CA supplied by the user
+	// to be used instead of a generated one
+	customCertPath string
+	customKeyPath  string
+
+	// keyAlgorithm of the signer's key and the keys of the certificates it
+	// signs, sub-CAs without an algorithm inherit it
+	keyAlgorithm cryptomaterial.KeyAlgorithm
+
+	// keyStore holds the key of a generated root CA instead of a file
+	keyStore cryptomaterial.KeyStore
 }
 
 // NewCertificateSigner returns a builder object for a certificate chain for the given signer
@@ -54,6 +71,9 @@ func NewCertificateSigner(signerName, signerDir string, validityDays int) Certif
 func (s *certificateSigner) Name() string      { return s.signerName }
 func (s *certificateSigner) Directory() string { return s.signerDir }
 func (s *certificateSigner) ValidityDays() int { return s.signerValidityDays }
+func (s *certificateSigner) KeyAlgorithm() cryptomaterial.KeyAlgorithm {
+	return s.keyAlgorithm
+}
 
 // WithSignerConfig uses the provided configuration in `config` to sign its
 // direct certificates.
@@ -68,6 +88,32 @@ func (s *certificateSigner) WithCABundlePaths(bundlePaths ...string) Certificate
 	return s
 }
 
+// WithCustomCA uses the CA certificate and key at the given paths instead of
+// generating a self-signed CA. The certificate file may contain the chain up
+// to the root CA after the CA certificate. Custom CAs are never regenerated.
+func (s *certificateSigner) WithCustomCA(certPath, keyPath string) CertificateSignerBuilder {
+	s.customCertPath = certPath
+	s.customKeyPath = keyPath
+	return s
+}
+
+// WithKeyAlgorithm generates the keys of the signer and the certificates it
+// signs with the algorithm instead of the default one. Existing keys with a
+// different algorithm are regenerated.
+func (s *certificateSigner) WithKeyAlgorithm(alg cryptomaterial.KeyAlgorithm) CertificateSignerBuilder {
+	s.keyAlgorithm = alg
+	return s
+}
+
+// WithKeyStore keeps the key of the signer in the key store instead of a file.
+// It only applies to generated root CAs, sub-CAs and custom CAs keep their
+// keys in files. Switching between a file and the key store regenerates the
+// CA.
+func (s *certificateSigner) WithKeyStore(keyStore cryptomaterial.KeyStore) CertificateSignerBuilder {
+	s.keyStore = keyStore
+	return s
+}
+
 func (s *certificateSigner) WithClientCertificates(signInfos ...*ClientCertificateSigningRequestInfo) CertificateSignerBuilder {
 	for _, signInfo := range signInfos {
 		s.certificatesToSign = append(s.certificatesToSign, signInfo)
@@ -94,19 +140,44 @@ func (s *certificateSigner) WithSubCAs(subCAsInfo ...CertificateSignerBuilder) C
 	return s
 }
 
+// Complete loads the signer and the certificates it signs, and generates the
+// ones that are missing or stale.
 func (s *certificateSigner) Complete() (*CertificateSigner, error) {
+	return s.complete(false)
+}
+
+// Load loads the signer and the certificates it signs without generating or
+// writing any of them. It fails if one of them is missing.
+func (s *certificateSigner) Load() (*CertificateSigner, error) {
+	return s.complete(true)
+}
+
+func (s *certificateSigner) complete(readOnly bool) (*CertificateSigner, error) {
 	// in case this is a sub-ca, it's already going to have the signer-config populated
 	signerConfig := s.signerConfig
-	if signerConfig == nil {
+	if signerConfig == nil && len(s.customCertPath) > 0 {
+		var err error
+		signerConfig, err = crypto.GetCA(s.customCertPath, s.customKeyPath, "")
+		if err != nil {
+			return nil, fmt.Errorf("failed to load custom %s CA certificate: %w", s.signerName, err)
+		}
+		if !signerConfig.Config.Certs[0].IsCA {
+			return nil, fmt.Errorf("custom %s CA certificate %s is not a CA", s.signerName, s.customCertPath)
+		}
+	} else if signerConfig == nil && readOnly {
 		var err error
-		signerConfig, _, err = crypto.EnsureCA(
-			cryptomaterial.CACertPath(s.signerDir),
-			cryptomaterial.CAKeyPath(s.signerDir),
-			cryptomaterial.CASerialsPath(s.signerDir),
-			s.signerName,
-			s.signerValidityDays,
-		)
+		signerConfig, err = loadRootCA(s.signerDir, s.signerName, s.keyStore)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load %s CA certificate: %w", s.signerName, err)
+		}
+	} else if signerConfig == nil {
+		// the certificates it signed are in the signer's directory as well
+		if err := removeIfStale(s.signerDir, cryptomaterial.CACertPath(s.signerDir), nil, s.keyAlgorithm); err != nil {
+			return nil, err
+		}
 
+		var err error
+		signerConfig, err = ensureRootCA(s.signerDir, s.signerName, s.signerValidityDays, s.keyAlgorithm, s.keyStore)
 		if err != nil {
 			return nil, fmt.Errorf("failed to generate %s CA certificate: %w", s.signerName, err)
 		}
@@ -117,6 +188,10 @@ func (s *certificateSigner) Complete() (*CertificateSigner, error) {
 		signerDir:          s.signerDir,
 		signerValidityDays: s.signerValidityDays,
 		signerConfig:       signerConfig,
+		customCertPath:     s.customCertPath,
+		customKeyPath:      s.customKeyPath,
+		keyAlgorithm:       s.keyAlgorithm,
+		keyStore:           s.keyStore,
 
 		subCAs:             make(map[string]*CertificateSigner),
 		signedCertificates: make(map[string]*signedCertificateInfo),
@@ -124,6 +199,20 @@ func (s *certificateSigner) Complete() (*CertificateSigner, error) {
 		caBundlePaths: sets.NewString(),
 	}
 
+	if readOnly {
+		for _, subCA := range s.subCAs {
+			if err := signerCompleted.loadSubCA(subCA); err != nil {
+				return nil, err
+			}
+		}
+		for _, si := range s.certificatesToSign {
+			if err := signerCompleted.loadCertificate(si); err != nil {
+				return nil, err
+			}
+		}
+		return signerCompleted, nil
+	}
+
 	for _, subCA := range s.subCAs {
 		subCA := subCA
 		if err := signerCompleted.SignSubCA(subCA); err != nil {
@@ -54,6 +54,10 @@ type CertificateSigner struct {
 	signerConfig       *crypto.CA
 	signerDir          string
 	signerValidityDays int
+	customCertPath     string
+	customKeyPath      string
+	keyAlgorithm       cryptomaterial.KeyAlgorithm
+	keyStore           cryptomaterial.KeyStore
 
 	subCAs             map[string]*CertificateSigner
 	signedCertificates map[string]*signedCertificateInfo
@@ -71,10 +75,18 @@ func (s *CertificateSigner) GetSignerCertPEM() ([]byte, error) {
 	return certPem, err
 }
 
+// IsCustom returns whether the signer is a CA supplied by the user.
+func (s *CertificateSigner) IsCustom() bool {
+	return len(s.customCertPath) > 0
+}
+
 func (s *CertificateSigner) Regenerate(certPath ...string) error {
 	switch len(certPath) {
 	case 0: // renew ourselves and all our sub-certs
-		if len(s.signerConfig.Config.Certs) == 1 {
+		if s.IsCustom() {
+			// the user is in charge of custom CAs, only renew the sub-certs
+			klog.Infof("Not regenerating custom CA %q, only the certificates it signed", s.signerName)
+		} else if len(s.signerConfig.Config.Certs) == 1 {
 			// this is a root CA, not an intermediary, regen the TLS config
 			if err := s.regenerateSelf(); err != nil {
 				return fmt.Errorf("failed to regenerate CA %q: %v", s.signerName, err)
@@ -114,14 +126,7 @@ func (s *CertificateSigner) regenerateSelf() error {
 		return fmt.Errorf("failed to regenerate CA %q: %v", s.signerName, err)
 	}
 
-	signerConfig, _, err := crypto.EnsureCA(
-		cryptomaterial.CACertPath(s.signerDir),
-		cryptomaterial.CAKeyPath(s.signerDir),
-		cryptomaterial.CASerialsPath(s.signerDir),
-		s.signerName,
-		s.signerValidityDays,
-	)
-
+	signerConfig, err := ensureRootCA(s.signerDir, s.signerName, s.signerValidityDays, s.keyAlgorithm, s.keyStore)
 	if err != nil {
 		return fmt.Errorf("failed to regenerate %s CA certificate: %w", s.signerName, err)
 	}
@@ -231,7 +236,9 @@ func (s *CertificateSigner) AddToBundles(bundlePaths ...string) error {
 }
 
 func (s *CertificateSigner) toBuilder() CertificateSignerBuilder {
-	signer := NewCertificateSigner(s.signerName, s.signerDir, s.signerValidityDays)
+	signer := NewCertificateSigner(s.signerName, s.signerDir, s.signerValidityDays).
+		WithKeyAlgorithm(s.keyAlgorithm).
+		WithKeyStore(s.keyStore)
 
 	for _, subCA := range s.subCAs {
 		signer = signer.WithSubCAs(subCA.toBuilder())
@@ -251,6 +258,9 @@ func (s *CertificateSigner) toBuilder() CertificateSignerBuilder {
 	}
 
 	signer = signer.WithCABundlePaths(s.caBundlePaths.List()...)
+	if s.IsCustom() {
+		signer = signer.WithCustomCA(s.customCertPath, s.customKeyPath)
+	}
 
 	return signer
 }
@@ -271,14 +281,22 @@ func (s *CertificateSigner) SignCertificate(csrInfo CSRInfo) error {
 func (s *CertificateSigner) SignSubCA(subSignerInfo CertificateSignerBuilder) error {
 	subSignerName := subSignerInfo.Name()
 	subSignerDir := subSignerInfo.Directory()
+	if subSignerInfo.KeyAlgorithm() == "" {
+		subSignerInfo = subSignerInfo.WithKeyAlgorithm(s.keyAlgorithm)
+	}
 
-	subCA, _, err := libraryGoEnsureSubCA(
+	if err := removeIfStale(subSignerDir, cryptomaterial.CABundlePath(subSignerDir), s.signerConfig.Config.Certs[0], subSignerInfo.KeyAlgorithm()); err != nil {
+		return err
+	}
+
+	subCA, err := ensureSubCA(
 		s.signerConfig,
 		cryptomaterial.CABundlePath(subSignerDir),
 		cryptomaterial.CAKeyPath(subSignerDir),
 		cryptomaterial.CASerialsPath(subSignerDir),
 		subSignerName,
 		subSignerInfo.ValidityDays(),
+		subSignerInfo.KeyAlgorithm(),
 	)
 	if err != nil {
 		return fmt.Errorf("failed to generate sub-CA %q: %w", subSignerName, err)
@@ -309,14 +327,106 @@ func (s *CertificateSigner) SignSubCA(subSignerInfo CertificateSignerBuilder) er
 	return nil
 }
 
+// loadSubCA loads the sub-CA and the certificates it signed from its
+// directory.
+func (s *CertificateSigner) loadSubCA(subSignerInfo CertificateSignerBuilder) error {
+	subSignerDir := subSignerInfo.Directory()
+	if subSignerInfo.KeyAlgorithm() == "" {
+		subSignerInfo = subSignerInfo.WithKeyAlgorithm(s.keyAlgorithm)
+	}
+
+	subCA, err := crypto.GetCA(
+		cryptomaterial.CABundlePath(subSignerDir),
+		cryptomaterial.CAKeyPath(subSignerDir),
+		cryptomaterial.CASerialsPath(subSignerDir),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to load sub-CA %q: %w", subSignerInfo.Name(), err)
+	}
+
+	subCertSigner, err := subSignerInfo.
+		WithSignerConfig(subCA).
+		Load()
+	if err != nil {
+		return err
+	}
+
+	s.subCAs[subCertSigner.signerName] = subCertSigner
+	return nil
+}
+
+// loadCertificate loads a certificate the signer signed from its directory.
+func (s *CertificateSigner) loadCertificate(csrInfo CSRInfo) error {
+	name := csrInfo.GetMeta().Name
+	certDir := filepath.Join(s.signerDir, name)
+
+	var certPath, keyPath string
+	switch csrInfo.(type) {
+	case *ClientCertificateSigningRequestInfo:
+		certPath, keyPath = cryptomaterial.ClientCertPath(certDir), cryptomaterial.ClientKeyPath(certDir)
+	case *ServingCertificateSigningRequestInfo:
+		certPath, keyPath = cryptomaterial.ServingCertPath(certDir), cryptomaterial.ServingKeyPath(certDir)
+	case *PeerCertificateSigningRequestInfo:
+		certPath, keyPath = cryptomaterial.PeerCertPath(certDir), cryptomaterial.PeerKeyPath(certDir)
+	default:
+		return fmt.Errorf("unknown CSR info type: %T", csrInfo)
+	}
+
+	tlsConfig, err := crypto.GetTLSCertificateConfig(certPath, keyPath)
+	if err != nil {
+		return fmt.Errorf("failed to load certificate %q: %w", name, err)
+	}
+
+	s.signedCertificates[name] = &signedCertificateInfo{
+		CSRInfo:   csrInfo,
+		tlsConfig: tlsConfig,
+	}
+	return nil
+}
+
+// removeIfStale removes the directory if the certificate in it was not
+// signed by `issuer`, e.g. because the signer was replaced by a custom CA, or
+// its key does not have the algorithm, so that the certificate gets generated
+// again. The signature is not checked without an issuer.
+func removeIfStale(dir, certPath string, issuer *x509.Certificate, alg cryptomaterial.KeyAlgorithm) error {
+	certPEM, err := os.ReadFile(certPath)
+	if err != nil {
+		// missing or unreadable certificates are regenerated anyway
+		return nil
+	}
+	certs, err := crypto.CertsFromPEM(certPEM)
+	if err != nil {
+		return nil
+	}
+
+	switch {
+	case issuer != nil && certs[0].CheckSignatureFrom(issuer) != nil:
+		klog.Infof("Certificate %s was not signed by %q, regenerating it", certPath, issuer.Subject.CommonName)
+	case !cryptomaterial.HasKeyAlgorithm(certs[0].PublicKey, alg):
+		klog.Infof("Key algorithm of certificate %s changed to %s, regenerating it", certPath, alg)
+	default:
+		return nil
+	}
+
+	if err := os.RemoveAll(dir); err != nil {
+		return fmt.Errorf("failed to remove dir %q: %v", dir, err)
+	}
+	return nil
+}
+
 func (s *CertificateSigner) SignClientCertificate(signInfo *ClientCertificateSigningRequestInfo) error {
 	certDir := filepath.Join(s.signerDir, signInfo.Name)
+	if err := removeIfStale(certDir, cryptomaterial.ClientCertPath(certDir), s.signerConfig.Config.Certs[0], s.keyAlgorithm); err != nil {
+		return err
+	}
 
-	tlsConfig, _, err := s.signerConfig.EnsureClientCertificate(
+	tlsConfig, err := ensureClientCertificate(
+		s.signerConfig,
 		cryptomaterial.ClientCertPath(certDir),
 		cryptomaterial.ClientKeyPath(certDir),
 		signInfo.UserInfo,
 		signInfo.ValidityDays,
+		s.keyAlgorithm,
 	)
 
 	if err != nil {
@@ -332,12 +442,17 @@ func (s *CertificateSigner) SignClientCertificate(signInfo *ClientCertificateSig
 
 func (s *CertificateSigner) SignServingCertificate(signInfo *ServingCertificateSigningRequestInfo) error {
 	certDir := filepath.Join(s.signerDir, signInfo.Name)
+	if err := removeIfStale(certDir, cryptomaterial.ServingCertPath(certDir), s.signerConfig.Config.Certs[0], s.keyAlgorithm); err != nil {
+		return err
+	}
 
-	tlsConfig, _, err := s.signerConfig.EnsureServerCert(
+	tlsConfig, err := ensureServerCert(
+		s.signerConfig,
 		cryptomaterial.ServingCertPath(certDir),
 		cryptomaterial.ServingKeyPath(certDir),
 		sets.NewString(signInfo.Hostnames...),
 		signInfo.ValidityDays,
+		s.keyAlgorithm,
 	)
 
 	if err != nil {
@@ -353,19 +468,28 @@ func (s *CertificateSigner) SignServingCertificate(signInfo *ServingCertificateS
 
 func (s *CertificateSigner) SignPeerCertificate(signInfo *PeerCertificateSigningRequestInfo) error {
 	certDir := filepath.Join(s.signerDir, signInfo.Name)
+	if err := removeIfStale(certDir, cryptomaterial.PeerCertPath(certDir), s.signerConfig.Config.Certs[0], s.keyAlgorithm); err != nil {
+		return err
+	}
 
 	hostnameSet := sets.NewString(signInfo.Hostnames...)
-	if _, err := crypto.GetServerCert(
+	if tlsConfig, err := crypto.GetServerCert(
 		cryptomaterial.PeerCertPath(certDir),
 		cryptomaterial.PeerKeyPath(certDir),
 		hostnameSet,
 	); err == nil {
+		s.signedCertificates[signInfo.Name] = &signedCertificateInfo{
+			CSRInfo:   signInfo,
+			tlsConfig: tlsConfig,
+		}
 		return nil
 	}
 
-	tlsConfig, err := s.signerConfig.MakeServerCertForDuration(
+	tlsConfig, err := makeServerCert(
+		s.signerConfig,
 		hostnameSet,
 		time.Duration(signInfo.ValidityDays)*24*time.Hour,
+		s.keyAlgorithm,
 		func(certTemplate *x509.Certificate) error {
 			certTemplate.Subject = userToSubject(signInfo.UserInfo)
 			certTemplate.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth, x509.ExtKeyUsageServerAuth}
@@ -392,6 +516,22 @@ func (s *CertificateSigner) SignPeerCertificate(signInfo *PeerCertificateSigning
 	return nil
 }
 
+// MakeClientCertificate signs a client certificate for the user that is
+// valid for `validity`. The certificate is not tracked by the signer, so it
+// is neither stored nor rotated.
+func (s *CertificateSigner) MakeClientCertificate(u user.Info, validity time.Duration) ([]byte, []byte, error) {
+	if notAfter := time.Now().Add(validity); notAfter.After(s.signerConfig.Config.Certs[0].NotAfter) {
+		return nil, nil, fmt.Errorf("the certificate would expire after its signer %q, which expires at %s",
+			s.signerName, s.signerConfig.Config.Certs[0].NotAfter.Format(time.RFC3339))
+	}
+
+	tlsConfig, err := makeClientCertificate(s.signerConfig, u, validity, s.keyAlgorithm)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to generate client certificate for %q: %w", u.GetName(), err)
+	}
+	return tlsConfig.GetPEMBytes()
+}
+
 func (s *CertificateSigner) GetCertNames() []string {
 	return signedCertificateInfoMapKeysOrdered(s.signedCertificates)
 }
@@ -434,51 +574,6 @@ func signedCertificateInfoMapKeysOrdered(stringMap map[string]*signedCertificate
 	return keys
 }
 
-// libraryGoEnsureSubCA comes from lib-go 4.12, use (ca *CA) EnsureSubCA from there once we get the updated lib-go
-func libraryGoEnsureSubCA(ca *crypto.CA, certFile, keyFile, serialFile, name string, expireDays int) (*crypto.CA, bool, error) {
-	if subCA, err := crypto.GetCA(certFile, keyFile, serialFile); err == nil {
-		return subCA, false, err
-	}
-	subCA, err := libraryGoMakeAndWriteSubCA(ca, certFile, keyFile, serialFile, name, expireDays)
-	return subCA, true, err
-}
-
-// lilibraryGoMakeAndWriteSubCA comes from lib-go 4.12, use (ca *CA) MakeAndWriteSubCA from there once we get the updated lib-go
-func libraryGoMakeAndWriteSubCA(ca *crypto.CA, certFile, keyFile, serialFile, name string, expireDays int) (*crypto.CA, error) {
-	klog.V(4).Infof("Generating sub-CA certificate in %s, key in %s, serial in %s", certFile, keyFile, serialFile)
-
-	subCAConfig, err := crypto.MakeCAConfigForDuration(name, time.Duration(expireDays)*time.Hour*24, ca)
-	if err != nil {
-		return nil, err
-	}
-
-	if err := subCAConfig.WriteCertConfigFile(certFile, keyFile); err != nil {
-		return nil, err
-	}
-
-	var serialGenerator crypto.SerialGenerator
-	if len(serialFile) > 0 {
-		// create / overwrite the serial file with a zero padded hex value (ending in a newline to have a valid file)
-		if err := os.WriteFile(serialFile, []byte("00\n"), 0644); err != nil {
-			return nil, err
-		}
-
-		serialGenerator, err = crypto.NewSerialFileGenerator(serialFile)
-		if err != nil {
-			return nil, err
-		}
-	} else {
-		serialGenerator = &crypto.RandomSerialGenerator{}
-	}
-
-	subCA := &crypto.CA{
-		Config:          subCAConfig,
-		SerialGenerator: serialGenerator,
-	}
-
-	return subCA, nil
-}
-
 type sortedForDER []string
 
 func (s sortedForDER) Len() int {
@@ -2,6 +2,7 @@ package certchains
 
 import (
 	"bytes"
+	"crypto"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -210,3 +211,165 @@ func mustCompleteSigner(t *testing.T, s CertificateSignerBuilder) *CertificateSi
 	require.NoError(t, err)
 	return ret
 }
+
+// tests that peer certificates that already exist on disk are loaded
+func TestCertificateSigner_SignPeerCertificateExisting(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	newSigner := func() CertificateSignerBuilder {
+		return NewCertificateSigner("test-signer", tmpDir, 365).
+			WithPeerCertificiates(&PeerCertificateSigningRequestInfo{
+				CSRMeta:   CSRMeta{Name: "test-peer", ValidityDays: 30},
+				UserInfo:  &user.DefaultInfo{Name: "test-peer"},
+				Hostnames: []string{"localhost"},
+			})
+	}
+
+	created := mustCompleteSigner(t, newSigner())
+	createdCert, _, err := created.GetCertKey("test-peer")
+	require.NoError(t, err)
+
+	loaded := mustCompleteSigner(t, newSigner())
+	require.Equal(t, []string{"test-peer"}, loaded.GetCertNames())
+	loadedCert, _, err := loaded.GetCertKey("test-peer")
+	require.NoError(t, err)
+	require.Equal(t, createdCert, loadedCert)
+}
+
+// tests that the keys of the signer, its sub-CAs and certificates have the
+// configured algorithm and are regenerated when it changes
+func TestCertificateSigner_KeyAlgorithm(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	newSigner := func(alg cryptomaterial.KeyAlgorithm) *CertificateSigner {
+		return mustCompleteSigner(t, NewCertificateSigner("test-signer", tmpDir, 365).
+			WithKeyAlgorithm(alg).
+			WithSubCAs(NewCertificateSigner("test-subca", filepath.Join(tmpDir, "test-subca"), 365).
+				WithClientCertificates(&ClientCertificateSigningRequestInfo{
+					CSRMeta:  CSRMeta{Name: "test-subca-client", ValidityDays: 30},
+					UserInfo: &user.DefaultInfo{Name: "test-subca-client"},
+				})).
+			WithServingCertificates(&ServingCertificateSigningRequestInfo{
+				CSRMeta:   CSRMeta{Name: "test-server", ValidityDays: 30},
+				Hostnames: []string{"localhost"},
+			}).
+			WithPeerCertificiates(&PeerCertificateSigningRequestInfo{
+				CSRMeta:   CSRMeta{Name: "test-peer", ValidityDays: 30},
+				UserInfo:  &user.DefaultInfo{Name: "test-peer"},
+				Hostnames: []string{"localhost"},
+			}))
+	}
+
+	requireKeyAlgorithm := func(s *CertificateSigner, alg cryptomaterial.KeyAlgorithm) {
+		signerCert := s.signerConfig.Config.Certs[0]
+		require.Equal(t, alg, cryptomaterial.KeyAlgorithmOf(signerCert.PublicKey), "signer %s", s.signerName)
+		for _, name := range s.GetCertNames() {
+			cert := s.signedCertificates[name].tlsConfig.Certs[0]
+			require.Equal(t, alg, cryptomaterial.KeyAlgorithmOf(cert.PublicKey), "certificate %s", name)
+			require.NoError(t, cert.CheckSignatureFrom(signerCert), "certificate %s", name)
+		}
+	}
+
+	signer := newSigner(cryptomaterial.ECDSAP384)
+	requireKeyAlgorithm(signer, cryptomaterial.ECDSAP384)
+	requireKeyAlgorithm(signer.GetSubCA("test-subca"), cryptomaterial.ECDSAP384)
+
+	signer = newSigner(cryptomaterial.RSA3072)
+	requireKeyAlgorithm(signer, cryptomaterial.RSA3072)
+	requireKeyAlgorithm(signer.GetSubCA("test-subca"), cryptomaterial.RSA3072)
+
+	// the default algorithm is RSA-2048
+	signer = newSigner("")
+	requireKeyAlgorithm(signer, cryptomaterial.RSA2048)
+}
+
+// memoryKeyStore keeps keys in memory, standing in for a PKCS#11 token.
+type memoryKeyStore map[string]crypto.Signer
+
+func (ks memoryKeyStore) FindKey(label string) (crypto.Signer, error) {
+	return ks[label], nil
+}
+
+func (ks memoryKeyStore) GenerateKey(label string, alg cryptomaterial.KeyAlgorithm) (crypto.PublicKey, crypto.Signer, error) {
+	publicKey, privateKey, err := cryptomaterial.GenerateKey(alg)
+	if err != nil {
+		return nil, nil, err
+	}
+	ks[label] = privateKey
+	return publicKey, privateKey, nil
+}
+
+// tests that the key of a signer with a key store is only kept in the key
+// store and that a signer whose key is missing from it is not regenerated
+func TestCertificateSigner_KeyStore(t *testing.T) {
+	tmpDir := t.TempDir()
+	keyStore := memoryKeyStore{}
+
+	newSignerBuilder := func(keyStore cryptomaterial.KeyStore) CertificateSignerBuilder {
+		signer := NewCertificateSigner("test-signer", tmpDir, 365).
+			WithClientCertificates(&ClientCertificateSigningRequestInfo{
+				CSRMeta:  CSRMeta{Name: "test-client", ValidityDays: 30},
+				UserInfo: &user.DefaultInfo{Name: "test-client"},
+			})
+		if keyStore != nil {
+			signer = signer.WithKeyStore(keyStore)
+		}
+		return signer
+	}
+	newSigner := func(keyStore cryptomaterial.KeyStore) *CertificateSigner {
+		return mustCompleteSigner(t, newSignerBuilder(keyStore))
+	}
+
+	requireSignedBy := func(s *CertificateSigner) {
+		cert := s.signedCertificates["test-client"].tlsConfig.Certs[0]
+		require.NoError(t, cert.CheckSignatureFrom(s.signerConfig.Config.Certs[0]))
+	}
+
+	// a signer with a key file is moved to the key store
+	fileSigner := newSigner(nil)
+	require.FileExists(t, cryptomaterial.CAKeyPath(tmpDir))
+
+	signer := newSigner(keyStore)
+	require.NoFileExists(t, cryptomaterial.CAKeyPath(tmpDir))
+	require.Contains(t, keyStore, "test-signer")
+	require.NotEqual(t, fileSigner.signerConfig.Config.Certs[0].Raw, signer.signerConfig.Config.Certs[0].Raw)
+	requireSignedBy(signer)
+
+	// the CA is reused while its key is in the key store
+	sameSigner := newSigner(keyStore)
+	require.Equal(t, signer.signerConfig.Config.Certs[0].Raw, sameSigner.signerConfig.Config.Certs[0].Raw)
+
+	// a corrupt serial file is reported instead of regenerating the CA
+	serial, err := os.ReadFile(cryptomaterial.CASerialsPath(tmpDir))
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(cryptomaterial.CASerialsPath(tmpDir), []byte("not a serial\n"), 0644))
+	_, err = newSignerBuilder(keyStore).Complete()
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "failed to load serial file")
+	require.NoError(t, os.WriteFile(cryptomaterial.CASerialsPath(tmpDir), serial, 0644))
+
+	// a key missing from the key store, e.g. on the wrong token, is reported
+	// instead of regenerating the CA
+	key := keyStore["test-signer"]
+	delete(keyStore, "test-signer")
+	_, err = newSignerBuilder(keyStore).Complete()
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "not found in the key store")
+	keyStore["test-signer"] = key
+	sameSigner = newSigner(keyStore)
+	require.Equal(t, signer.signerConfig.Config.Certs[0].Raw, sameSigner.signerConfig.Config.Certs[0].Raw)
+
+	// the CA is regenerated once its certificate is removed
+	delete(keyStore, "test-signer")
+	require.NoError(t, os.Remove(cryptomaterial.CACertPath(tmpDir)))
+	newKeySigner := newSigner(keyStore)
+	require.NotEqual(t, signer.signerConfig.Config.Certs[0].Raw, newKeySigner.signerConfig.Config.Certs[0].Raw)
+	requireSignedBy(newKeySigner)
+
+	// rotating the CA generates a new key in the key store
+	oldKey := keyStore["test-signer"]
+	require.NoError(t, newKeySigner.Regenerate())
+	require.NotEqual(t, oldKey, keyStore["test-signer"])
+	require.NoFileExists(t, cryptomaterial.CAKeyPath(tmpDir))
+	requireSignedBy(newKeySigner)
+}
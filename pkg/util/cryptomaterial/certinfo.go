@@ -74,6 +74,12 @@ func AdminKubeconfigClientCertDir(certsDir string) string {
 	return filepath.Join(AdminKubeconfigSignerDir(certsDir), "admin-kubeconfig-client")
 }
 
+// UserClientSignerDir returns path to the signer that signs the client
+// certificates of the kubeconfigs created for users
+func UserClientSignerDir(certsDir string) string {
+	return filepath.Join(certsDir, "user-client-signer")
+}
+
 // KubeletCSRSignerSignerCertDir returns path to the signer that signs kubelet CSRs
 // and the signer that signs CSRs of the CSR API
 func KubeletCSRSignerSignerCertDir(certsDir string) string {
@@ -0,0 +1,107 @@
+package cryptomaterial
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"fmt"
+)
+
+// KeyAlgorithm is the algorithm and size of a generated private key.
+type KeyAlgorithm string
+
+const (
+	RSA2048   KeyAlgorithm = "RSA-2048"
+	RSA3072   KeyAlgorithm = "RSA-3072"
+	RSA4096   KeyAlgorithm = "RSA-4096"
+	ECDSAP256 KeyAlgorithm = "ECDSA-P256"
+	ECDSAP384 KeyAlgorithm = "ECDSA-P384"
+
+	DefaultKeyAlgorithm = RSA2048
+)
+
+// KeyAlgorithms are the supported key algorithms.
+var KeyAlgorithms = []string{string(RSA2048), string(RSA3072), string(RSA4096), string(ECDSAP256), string(ECDSAP384)}
+
+// GenerateKey generates a private key with the algorithm, the default
+// algorithm if it is empty.
+func GenerateKey(alg KeyAlgorithm) (crypto.PublicKey, crypto.Signer, error) {
+	bits, curve, err := keyParameters(alg)
+	if err != nil {
+		return nil, nil, err
+	}
+	if curve != nil {
+		return generateECDSAKey(curve)
+	}
+	return generateRSAKey(bits)
+}
+
+// keyParameters returns the size of RSA keys or the curve of ECDSA keys of
+// the algorithm, the default algorithm if it is empty.
+func keyParameters(alg KeyAlgorithm) (int, elliptic.Curve, error) {
+	switch alg {
+	case RSA2048, "":
+		return 2048, nil, nil
+	case RSA3072:
+		return 3072, nil, nil
+	case RSA4096:
+		return 4096, nil, nil
+	case ECDSAP256:
+		return 0, elliptic.P256(), nil
+	case ECDSAP384:
+		return 0, elliptic.P384(), nil
+	default:
+		return 0, nil, fmt.Errorf("unsupported key algorithm %q", alg)
+	}
+}
+
+func generateRSAKey(bits int) (crypto.PublicKey, crypto.Signer, error) {
+	key, err := rsa.GenerateKey(rand.Reader, bits)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &key.PublicKey, key, nil
+}
+
+func generateECDSAKey(curve elliptic.Curve) (crypto.PublicKey, crypto.Signer, error) {
+	key, err := ecdsa.GenerateKey(curve, rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &key.PublicKey, key, nil
+}
+
+// KeyAlgorithmOf returns the algorithm of the public key, or an empty string
+// if it is not one of the supported algorithms.
+func KeyAlgorithmOf(pub crypto.PublicKey) KeyAlgorithm {
+	switch pub := pub.(type) {
+	case *rsa.PublicKey:
+		switch pub.N.BitLen() {
+		case 2048:
+			return RSA2048
+		case 3072:
+			return RSA3072
+		case 4096:
+			return RSA4096
+		}
+	case *ecdsa.PublicKey:
+		switch pub.Curve {
+		case elliptic.P256():
+			return ECDSAP256
+		case elliptic.P384():
+			return ECDSAP384
+		}
+	}
+	return ""
+}
+
+// HasKeyAlgorithm returns whether the public key has the algorithm, the
+// default algorithm if it is empty.
+func HasKeyAlgorithm(pub crypto.PublicKey, alg KeyAlgorithm) bool {
+	if alg == "" {
+		alg = DefaultKeyAlgorithm
+	}
+	return KeyAlgorithmOf(pub) == alg
+}
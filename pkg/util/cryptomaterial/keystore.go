@@ -0,0 +1,15 @@
+package cryptomaterial
+
+import (
+	"crypto"
+)
+
+// KeyStore keeps private keys outside of the filesystem, e.g. on a PKCS#11
+// token, and signs with them without exposing them.
+type KeyStore interface {
+	// FindKey returns the key with the label, or nil if there is none.
+	FindKey(label string) (crypto.Signer, error)
+	// GenerateKey generates a key with the algorithm and label, replacing
+	// the keys that already have the label.
+	GenerateKey(label string, alg KeyAlgorithm) (crypto.PublicKey, crypto.Signer, error)
+}
@@ -0,0 +1,81 @@
+//go:build cgo
+// +build cgo
+
+package cryptomaterial
+
+import (
+	"crypto"
+	"fmt"
+
+	"github.com/ThalesIgnite/crypto11"
+)
+
+// PKCS11KeyStore keeps keys on a PKCS#11 token. The private keys are
+// generated on the token and cannot be extracted from it.
+type PKCS11KeyStore struct {
+	ctx *crypto11.Context
+}
+
+var _ KeyStore = &PKCS11KeyStore{}
+
+// NewPKCS11KeyStore logs into the token with the label using the PKCS#11
+// module at modulePath. The keys it returns can only be used until it is
+// closed.
+func NewPKCS11KeyStore(modulePath, tokenLabel, pin string) (*PKCS11KeyStore, error) {
+	ctx, err := crypto11.Configure(&crypto11.Config{
+		Path:       modulePath,
+		TokenLabel: tokenLabel,
+		Pin:        pin,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open PKCS#11 token %q with module %s: %w", tokenLabel, modulePath, err)
+	}
+	return &PKCS11KeyStore{ctx: ctx}, nil
+}
+
+// FindKey returns the key pair whose ID and label are the label.
+func (s *PKCS11KeyStore) FindKey(label string) (crypto.Signer, error) {
+	key, err := s.ctx.FindKeyPair([]byte(label), []byte(label))
+	if err != nil {
+		return nil, fmt.Errorf("failed to find PKCS#11 key %q: %w", label, err)
+	}
+	if key == nil {
+		return nil, nil
+	}
+	return key, nil
+}
+
+// GenerateKey generates a key pair on the token whose ID and label are the
+// label, after deleting the key pairs with the label.
+func (s *PKCS11KeyStore) GenerateKey(label string, alg KeyAlgorithm) (crypto.PublicKey, crypto.Signer, error) {
+	bits, curve, err := keyParameters(alg)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	keys, err := s.ctx.FindKeyPairs([]byte(label), []byte(label))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to find PKCS#11 key %q: %w", label, err)
+	}
+	for _, key := range keys {
+		if err := key.Delete(); err != nil {
+			return nil, nil, fmt.Errorf("failed to delete PKCS#11 key %q: %w", label, err)
+		}
+	}
+
+	var key crypto11.Signer
+	if curve != nil {
+		key, err = s.ctx.GenerateECDSAKeyPairWithLabel([]byte(label), []byte(label), curve)
+	} else {
+		key, err = s.ctx.GenerateRSAKeyPairWithLabel([]byte(label), []byte(label), bits)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to generate PKCS#11 key %q: %w", label, err)
+	}
+	return key.Public(), key, nil
+}
+
+// Close logs out of the token.
+func (s *PKCS11KeyStore) Close() error {
+	return s.ctx.Close()
+}
@@ -0,0 +1,33 @@
+//go:build !cgo
+// +build !cgo
+
+package cryptomaterial
+
+import (
+	"crypto"
+	"errors"
+)
+
+// PKCS11KeyStore is not available without cgo, which is required to load
+// PKCS#11 modules.
+type PKCS11KeyStore struct{}
+
+var _ KeyStore = &PKCS11KeyStore{}
+
+var errPKCS11Unsupported = errors.New("PKCS#11 is not supported by this build of MicroShift, it must be built with CGO_ENABLED=1")
+
+func NewPKCS11KeyStore(modulePath, tokenLabel, pin string) (*PKCS11KeyStore, error) {
+	return nil, errPKCS11Unsupported
+}
+
+func (s *PKCS11KeyStore) FindKey(label string) (crypto.Signer, error) {
+	return nil, errPKCS11Unsupported
+}
+
+func (s *PKCS11KeyStore) GenerateKey(label string, alg KeyAlgorithm) (crypto.PublicKey, crypto.Signer, error) {
+	return nil, nil, errPKCS11Unsupported
+}
+
+func (s *PKCS11KeyStore) Close() error {
+	return nil
+}
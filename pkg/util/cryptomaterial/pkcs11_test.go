@@ -0,0 +1,77 @@
+//go:build cgo
+// +build cgo
+
+package cryptomaterial
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"os"
+	"testing"
+)
+
+// TestPKCS11KeyStore needs an initialized token, e.g. for SoftHSM:
+//
+//	softhsm2-util --init-token --free --label microshift-test --pin 1234 --so-pin 1234
+//	PKCS11_MODULE=/usr/lib64/pkcs11/libsofthsm2.so PKCS11_TOKEN_LABEL=microshift-test PKCS11_PIN=1234 go test ./pkg/util/cryptomaterial/
+func TestPKCS11KeyStore(t *testing.T) {
+	modulePath := os.Getenv("PKCS11_MODULE")
+	if modulePath == "" {
+		t.Skip("PKCS11_MODULE is not set")
+	}
+
+	keyStore, err := NewPKCS11KeyStore(modulePath, os.Getenv("PKCS11_TOKEN_LABEL"), os.Getenv("PKCS11_PIN"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer keyStore.Close()
+
+	for _, alg := range []KeyAlgorithm{RSA2048, ECDSAP256} {
+		label := "microshift-test-" + string(alg)
+		publicKey, _, err := keyStore.GenerateKey(label, alg)
+		if err != nil {
+			t.Fatalf("%s: %v", alg, err)
+		}
+		if !HasKeyAlgorithm(publicKey, alg) {
+			t.Errorf("%s: generated a %s key", alg, KeyAlgorithmOf(publicKey))
+		}
+
+		found, err := keyStore.FindKey(label)
+		if err != nil || found == nil {
+			t.Fatalf("%s: generated key not found: %v", alg, err)
+		}
+
+		// the key signs through the token
+		digest := sha256.Sum256([]byte("microshift"))
+		signature, err := found.Sign(rand.Reader, digest[:], crypto.SHA256)
+		if err != nil {
+			t.Fatalf("%s: %v", alg, err)
+		}
+		sigAlg := x509.SHA256WithRSA
+		if alg == ECDSAP256 {
+			sigAlg = x509.ECDSAWithSHA256
+		}
+		cert := &x509.Certificate{PublicKey: publicKey}
+		if err := cert.CheckSignature(sigAlg, []byte("microshift"), signature); err != nil {
+			t.Errorf("%s: %v", alg, err)
+		}
+
+		// generating a key again replaces it
+		newPublicKey, _, err := keyStore.GenerateKey(label, alg)
+		if err != nil {
+			t.Fatalf("%s: %v", alg, err)
+		}
+		found, err = keyStore.FindKey(label)
+		if err != nil || found == nil {
+			t.Fatalf("%s: replaced key not found: %v", alg, err)
+		}
+		if publicKey.(interface{ Equal(crypto.PublicKey) bool }).Equal(found.Public()) {
+			t.Errorf("%s: expected the key to be replaced", alg)
+		}
+		if !newPublicKey.(interface{ Equal(crypto.PublicKey) bool }).Equal(found.Public()) {
+			t.Errorf("%s: expected the new key to be found", alg)
+		}
+	}
+}
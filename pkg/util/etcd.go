@@ -0,0 +1,50 @@
+package util
+
+import (
+	"crypto/tls"
+	"time"
+
+	"go.etcd.io/etcd/client/pkg/v3/transport"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.uber.org/zap"
+
+	"github.com/openshift/microshift/pkg/util/cryptomaterial"
+)
+
+const (
+	// EtcdEndpoint is the URL MicroShift's etcd serves clients at on the
+	// node.
+	EtcdEndpoint = "https://127.0.0.1:2379"
+	// EtcdMetricsEndpoint is the URL MicroShift's etcd serves its /metrics
+	// and /health at.
+	EtcdMetricsEndpoint = "https://127.0.0.1:2381"
+)
+
+// EtcdClientTLSConfig returns the TLS config of clients of MicroShift's etcd
+// that authenticate with the API server's etcd client certificate in
+// certsDir.
+func EtcdClientTLSConfig(certsDir string) (*tls.Config, error) {
+	clientCertDir := cryptomaterial.EtcdAPIServerClientCertDir(certsDir)
+	tlsInfo := transport.TLSInfo{
+		CertFile:      cryptomaterial.ClientCertPath(clientCertDir),
+		KeyFile:       cryptomaterial.ClientKeyPath(clientCertDir),
+		TrustedCAFile: cryptomaterial.CACertPath(cryptomaterial.EtcdSignerDir(certsDir)),
+	}
+	return tlsInfo.ClientConfig()
+}
+
+// NewEtcdClient returns a client of MicroShift's etcd that authenticates
+// with the API server's etcd client certificate in certsDir.
+func NewEtcdClient(certsDir string) (*clientv3.Client, error) {
+	tlsConfig, err := EtcdClientTLSConfig(certsDir)
+	if err != nil {
+		return nil, err
+	}
+
+	return clientv3.New(clientv3.Config{
+		Endpoints:   []string{EtcdEndpoint},
+		TLS:         tlsConfig,
+		DialTimeout: 10 * time.Second,
+		Logger:      zap.NewNop(),
+	})
+}
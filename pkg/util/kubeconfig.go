@@ -29,6 +29,50 @@ func KubeConfigWithClientCerts(
 	clientCertPEM []byte,
 	clientKeyPEM []byte,
 ) error {
+	msUser := clientcmdapi.NewAuthInfo()
+	msUser.ClientCertificateData = clientCertPEM
+	msUser.ClientKeyData = clientKeyPEM
+
+	return writeKubeConfig(path, clusterURL, clusterTrustBundle, msUser)
+}
+
+// KubeConfigWithClientCertFiles creates a kubeconfig authenticating with the
+// client cert/key files at a location provided by `path`. Clients reload the
+// files when they change, so the certificate can be rotated in place.
+func KubeConfigWithClientCertFiles(
+	path string,
+	clusterURL string,
+	clusterTrustBundle []byte,
+	clientCertPath string,
+	clientKeyPath string,
+) error {
+	msUser := clientcmdapi.NewAuthInfo()
+	msUser.ClientCertificate = clientCertPath
+	msUser.ClientKey = clientKeyPath
+
+	return writeKubeConfig(path, clusterURL, clusterTrustBundle, msUser)
+}
+
+// KubeConfigWithClientCertsData returns a kubeconfig authenticating with
+// client cert/key, e.g. for printing it.
+func KubeConfigWithClientCertsData(
+	clusterURL string,
+	clusterTrustBundle []byte,
+	clientCertPEM []byte,
+	clientKeyPEM []byte,
+) ([]byte, error) {
+	msUser := clientcmdapi.NewAuthInfo()
+	msUser.ClientCertificateData = clientCertPEM
+	msUser.ClientKeyData = clientKeyPEM
+
+	return clientcmd.Write(newKubeConfig(clusterURL, clusterTrustBundle, msUser))
+}
+
+func writeKubeConfig(path string, clusterURL string, clusterTrustBundle []byte, msUser *clientcmdapi.AuthInfo) error {
+	return clientcmd.WriteToFile(newKubeConfig(clusterURL, clusterTrustBundle, msUser), path)
+}
+
+func newKubeConfig(clusterURL string, clusterTrustBundle []byte, msUser *clientcmdapi.AuthInfo) clientcmdapi.Config {
 	const microshiftName = "microshift"
 
 	cluster := clientcmdapi.NewCluster()
@@ -40,16 +84,10 @@ func KubeConfigWithClientCerts(
 	msContext.Namespace = "default"
 	msContext.AuthInfo = "user"
 
-	msUser := clientcmdapi.NewAuthInfo()
-	msUser.ClientCertificateData = clientCertPEM
-	msUser.ClientKeyData = clientKeyPEM
-
-	kubeConfig := clientcmdapi.Config{
+	return clientcmdapi.Config{
 		CurrentContext: microshiftName,
 		Clusters:       map[string]*clientcmdapi.Cluster{microshiftName: cluster},
 		Contexts:       map[string]*clientcmdapi.Context{microshiftName: msContext},
 		AuthInfos:      map[string]*clientcmdapi.AuthInfo{"user": msUser},
 	}
-
-	return clientcmd.WriteToFile(kubeConfig, path)
 }
@@ -16,6 +16,7 @@ limitations under the License.
 package util
 
 import (
+	"context"
 	"crypto/tls"
 	"fmt"
 	tcpnet "net"
@@ -73,10 +74,13 @@ func getOVNGatewayIP() (string, error) {
 	return "", fmt.Errorf("failed to get ovn gateway IP address")
 }
 
-func RetryInsecureHttpsGet(url string) int {
+// RetryInsecureHttpsGet polls `url` until it returns any HTTP status code or
+// the context gets canceled, in which case 0 is returned. Deadlines are
+// expected to be enforced by the caller through the context.
+func RetryInsecureHttpsGet(ctx context.Context, url string) int {
 
 	status := 0
-	err := wait.Poll(5*time.Second, 120*time.Second, func() (bool, error) {
+	err := wait.PollUntilWithContext(ctx, 5*time.Second, func(ctx context.Context) (bool, error) {
 		http.DefaultTransport.(*http.Transport).TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
 		resp, err := http.Get(url)
 		if err == nil {
@@ -86,16 +90,18 @@ func RetryInsecureHttpsGet(url string) int {
 		return false, nil
 	})
 
-	if err != nil && err == wait.ErrWaitTimeout {
-		klog.Warningf("Endpoint is not returning any status code")
+	if err != nil {
+		klog.Warningf("Endpoint %s is not returning any status code", url)
 	}
 
 	return status
 }
 
-func RetryTCPConnection(host string, port string) bool {
+// RetryTCPConnection polls the TCP endpoint until a connection can be
+// established or the context gets canceled.
+func RetryTCPConnection(ctx context.Context, host string, port string) bool {
 	status := false
-	err := wait.Poll(5*time.Second, 120*time.Second, func() (bool, error) {
+	err := wait.PollUntilWithContext(ctx, 5*time.Second, func(ctx context.Context) (bool, error) {
 		timeout := 30 * time.Second
 		_, err := tcpnet.DialTimeout("tcp", tcpnet.JoinHostPort(host, port), timeout)
 
@@ -105,8 +111,8 @@ func RetryTCPConnection(host string, port string) bool {
 		}
 		return false, nil
 	})
-	if err != nil && err == wait.ErrWaitTimeout {
-		klog.Warningf("Endpoint is not returning any status code")
+	if err != nil {
+		klog.Warningf("Endpoint %s is not accepting connections", tcpnet.JoinHostPort(host, port))
 	}
 	return status
 }